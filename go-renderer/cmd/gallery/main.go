@@ -0,0 +1,175 @@
+// Command gallery renders every CML file in the test corpus into an examples
+// directory and reports how long each took to parse and render. It exits
+// non-zero when any file fails, so it doubles as an integration check and a
+// quick benchmark for renderer changes:
+//
+//	go run ./cmd/gallery -out ../examples/gallery testdata
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+	"github.com/md/chart-markup-language/go-renderer/pkg/render"
+)
+
+// result is the outcome of rendering one corpus file
+type result struct {
+	name     string
+	bars     int
+	drawings int
+	parse    time.Duration // Fastest of the runs
+	render   time.Duration // Fastest of the runs
+	size     int64
+	err      error
+}
+
+func main() {
+	outDir := flag.String("out", filepath.Join("..", "examples", "gallery"), "directory to write the rendered PNGs and index to")
+	width := flag.Int("width", 800, "image width in pixels")
+	height := flag.Int("height", 600, "image height in pixels")
+	runs := flag.Int("runs", 1, "parse and render each file this many times, reporting the fastest run")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: gallery [flags] [dir or file.cml]... (default: testdata)\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"testdata"}
+	}
+	files, err := corpusFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no .cml files in %s\n", strings.Join(paths, ", "))
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *outDir, err)
+		os.Exit(1)
+	}
+
+	results := make([]result, len(files))
+	for i, file := range files {
+		results[i] = renderFile(file, *outDir, *width, *height, max(*runs, 1))
+	}
+
+	if err := writeIndex(filepath.Join(*outDir, "README.md"), results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing gallery index: %v\n", err)
+		os.Exit(1)
+	}
+	if failed := printResults(results); failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d files failed\n", failed, len(results))
+		os.Exit(1)
+	}
+	fmt.Printf("Rendered %d files to %s\n", len(results), *outDir)
+}
+
+// corpusFiles expands directories into the .cml files directly inside them, in name order
+func corpusFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.cml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// renderFile parses and renders one file runs times, writing the last render
+// to outDir as NAME.png
+func renderFile(file, outDir string, width, height, runs int) result {
+	res := result{name: strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	output := filepath.Join(outDir, res.name+".png")
+
+	parser := cml.NewCMLParser()
+	parser.BaseDir = filepath.Dir(file)
+	renderer := render.NewCMLRenderer(width, height)
+	defer renderer.Close()
+
+	for run := 0; run < runs; run++ {
+		started := time.Now()
+		chart, err := parser.Parse(string(content))
+		if err != nil {
+			res.err = fmt.Errorf("parse: %v", err)
+			return res
+		}
+		parsed := time.Now()
+		if err := renderer.Render(chart, output); err != nil {
+			res.err = fmt.Errorf("render: %v", err)
+			return res
+		}
+		rendered := time.Now()
+
+		if run == 0 || parsed.Sub(started) < res.parse {
+			res.parse = parsed.Sub(started)
+		}
+		if run == 0 || rendered.Sub(parsed) < res.render {
+			res.render = rendered.Sub(parsed)
+		}
+		res.bars, res.drawings = len(chart.Bars), len(chart.Drawings)
+	}
+
+	if info, err := os.Stat(output); err == nil {
+		res.size = info.Size()
+	}
+	return res
+}
+
+// printResults prints a table of timings and failures, returning the number of failures
+func printResults(results []result) int {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "file\tbars\tdrawings\tparse\trender\tbytes\t")
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\t\t\t\t\tFAIL: %v\t\n", res.name, res.err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%v\t%v\t%d\t\n", res.name, res.bars, res.drawings,
+			res.parse.Round(time.Microsecond), res.render.Round(time.Microsecond), res.size)
+	}
+	w.Flush()
+	return failed
+}
+
+// writeIndex writes a Markdown page showing every rendered image
+func writeIndex(path string, results []result) error {
+	var b strings.Builder
+	b.WriteString("# CML Gallery\n\nRendered from the test corpus by `go run ./cmd/gallery`.\n")
+	for _, res := range results {
+		if res.err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n%d bars, %d drawings\n\n![%s](%s.png)\n", res.name, res.bars, res.drawings, res.name, res.name)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
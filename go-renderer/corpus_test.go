@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+	"github.com/md/chart-markup-language/go-renderer/pkg/render"
+)
+
+// corpusCharts returns the names of the testdata corpus files, each of
+// which must parse and render
+func corpusCharts(tb testing.TB) []string {
+	tb.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.cml"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if len(files) == 0 {
+		tb.Fatal("no .cml files in testdata")
+	}
+	return files
+}
+
+// parseCorpusChart parses one corpus file
+func parseCorpusChart(tb testing.TB, file string) *cml.Chart {
+	tb.Helper()
+	content, err := os.ReadFile(file)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	parser := cml.NewCMLParser()
+	parser.BaseDir = filepath.Dir(file)
+	chart, err := parser.ParseReader(bytes.NewReader(content))
+	if err != nil {
+		tb.Fatalf("parsing %s: %v", file, err)
+	}
+	return chart
+}
+
+func TestCorpus(t *testing.T) {
+	for _, file := range corpusCharts(t) {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".cml"), func(t *testing.T) {
+			chart := parseCorpusChart(t, file)
+			if len(chart.Bars) == 0 {
+				t.Fatal("no bars")
+			}
+			if len(chart.Warnings) > 0 {
+				t.Errorf("warnings: %v", chart.Warnings)
+			}
+
+			renderer := render.NewCMLRenderer(800, 600)
+			defer renderer.Close()
+			var out bytes.Buffer
+			if err := renderer.RenderTo(chart, &out, "png"); err != nil {
+				t.Fatalf("rendering: %v", err)
+			}
+			config, err := png.DecodeConfig(&out)
+			if err != nil {
+				t.Fatalf("decoding the render: %v", err)
+			}
+			if config.Width != 800 || config.Height != 600 {
+				t.Errorf("rendered %dx%d, want 800x600", config.Width, config.Height)
+			}
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, file := range corpusCharts(b) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(strings.TrimSuffix(filepath.Base(file), ".cml"), func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				parser := cml.NewCMLParser()
+				parser.BaseDir = filepath.Dir(file)
+				if _, err := parser.ParseReader(bytes.NewReader(content)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	for _, file := range corpusCharts(b) {
+		chart := parseCorpusChart(b, file)
+		b.Run(strings.TrimSuffix(filepath.Base(file), ".cml"), func(b *testing.B) {
+			renderer := render.NewCMLRenderer(800, 600)
+			defer renderer.Close()
+			var out bytes.Buffer
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				out.Reset()
+				if err := renderer.RenderTo(chart, &out, "png"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package main
+
+import "os"
+
+// lockFile does nothing on platforms without flock or LockFileEx, where
+// concurrent manifest updates aren't serialized
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing, like lockFile
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockfileExclusiveLock is LOCKFILE_EXCLUSIVE_LOCK
+const lockfileExclusiveLock = 0x2
+
+// lockFile blocks until it holds an exclusive lock on the file's first byte
+func lockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	ok, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases a lock taken by lockFile
+func unlockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	ok, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
@@ -4,7 +4,11 @@ go 1.21
 
 require (
 	github.com/fogleman/gg v1.3.0
+	github.com/klauspost/compress v1.17.4
 	golang.org/x/image v0.15.0
 )
 
-require github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
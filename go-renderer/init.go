@@ -0,0 +1,64 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// starterChart is the commented chart init writes: sample bars with volume,
+// one of each drawing, a few indicators, and common settings
+//
+//go:embed starter.cml
+var starterChart []byte
+
+// runInit writes the starter chart to a file, chart.cml unless given, for
+// new users to render and edit. An existing file is kept unless --force.
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := flags.Bool("force", false, "overwrite the file if it already exists")
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer init [--force] [output.cml]")
+		fmt.Println("")
+		fmt.Println("Flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	outputFile := "chart.cml"
+	if flags.NArg() == 1 {
+		outputFile = flags.Arg(0)
+	}
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(outputFile, mode, 0644)
+	if os.IsExist(err) {
+		fmt.Printf("Error: %s already exists (use --force to overwrite it)\n", outputFile)
+		os.Exit(exitUsage)
+	}
+	if err == nil {
+		_, err = file.Write(starterChart)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error writing file %s: %v\n", outputFile, err)
+		os.Exit(exitRender)
+	}
+
+	fmt.Printf("Starter chart written to %s\n", outputFile)
+	fmt.Printf("Render it with: cml-renderer %s %s\n", outputFile, defaultOutputName(outputFile))
+}
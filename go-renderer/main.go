@@ -1,9 +1,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+	"github.com/md/chart-markup-language/go-renderer/pkg/render"
 )
 
 // Version information set at build time
@@ -14,54 +26,882 @@ var (
 )
 
 func main() {
-	fmt.Printf("DEBUG: Main function started\n")
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: cml-renderer <input.cml> [output.png]")
-		fmt.Println("Example: cml-renderer example.cml chart.png")
-		fmt.Println("")
-		fmt.Printf("Version: %s\n", Version)
-		fmt.Printf("Build Time: %s\n", BuildTime)
-		fmt.Printf("Git Ref: %s\n", GitRef)
-		os.Exit(1)
-	}
-
 	// Handle version flag
-	if os.Args[1] == "--version" || os.Args[1] == "-v" {
+	if len(os.Args) > 1 && (os.Args[1] == "--version" || os.Args[1] == "-v") {
 		fmt.Printf("cml-renderer version %s\n", Version)
 		fmt.Printf("Build Time: %s\n", BuildTime)
 		fmt.Printf("Git Ref: %s\n", GitRef)
 		os.Exit(0)
 	}
 
-	inputFile := os.Args[1]
+	// Alternate visualizations are subcommands
+	if len(os.Args) > 1 && os.Args[1] == "calendar" {
+		runCalendar(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eventstudy" {
+		runEventStudy(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		runCheck(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		runConvert(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "publish" {
+		runPublish(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInit(os.Args[2:])
+		return
+	}
+
+	// Flag errors exit with exitUsage rather than the flag package's default
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+
+	lenient := flag.Bool("lenient", false, "skip malformed bar and drawing lines instead of failing")
+	maxBars := flag.Int("max-bars", cml.DefaultParseLimits.MaxBars, "maximum number of bars (0 for no limit)")
+	maxDrawings := flag.Int("max-drawings", cml.DefaultParseLimits.MaxDrawings, "maximum number of drawings (0 for no limit)")
+	maxLineLength := flag.Int("max-line-length", cml.DefaultParseLimits.MaxLineLength, "maximum length of a line in characters (0 for no limit)")
+	maxFileSize := flag.Int64("max-file-size", cml.DefaultParseLimits.MaxFileSize, "maximum decompressed input size in bytes (0 for no limit)")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the render to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file after rendering")
+	pngCompression := flag.String("png-compression", "", "PNG compression level: default, none, fast, or best (overrides settings)")
+	pngColors := flag.Int("png-colors", 0, "quantize the PNG to this many palette colors, 2-256 (overrides settings)")
+	imageMapFile := flag.String("image-map", "", "write element bounding boxes for click-through tooltips to this file (.html for an HTML <map>, otherwise JSON)")
+	statsFile := flag.String("stats", "", "write chart statistics (returns, volatility, drawdown, indicator values) as JSON to this file")
+	altText := flag.Bool("alt-text", false, "also write a plain-English description of the chart for alt text next to the output (chart.png gets chart.txt)")
+	thumbnail := flag.String("thumbnail", "", "also write a simplified WIDTHxHEIGHT thumbnail (e.g. 320x180) next to the output")
+	pngInterlace := flag.Bool("png-interlace", false, "write an Adam7-interlaced PNG (overrides settings)")
+	dataFile := flag.String("data", "", "expand the input as a Go text/template with this JSON data file before parsing")
+	themeName := flag.String("theme", "", "color theme: light, dark, or a JSON theme file (overrides the theme setting)")
+	printMode := flag.Bool("print", false, "render for black and white printing: hollow and filled candles, dashed lines, a thicker frame (same as print-mode: true)")
+	strict := flag.Bool("strict", false, fmt.Sprintf("exit with code %d when the parse produced warnings (use with --lenient)", exitWarnings))
+	summaryFile := flag.String("summary", "", "write a JSON run summary (status, exit code, warning counts by type) to this file, or - for stdout")
+	width := flag.Int("width", 0, "canvas width in pixels (default: from the chart's size setting, or 800)")
+	height := flag.Int("height", 0, "canvas height in pixels (default: from the chart's size setting, or 600)")
+	scale := flag.Float64("scale", 1, "draw this many image pixels per canvas pixel, e.g. 2 for sharp output on high-density displays")
+	sizesList := flag.String("sizes", "", "render several sizes from one parse, e.g. 800x600,1600x1200,320x180; each output is named like chart-800x600.png")
+	manifestFile := flag.String("manifest", "", "add this render (input, output, size, duration, warnings, SHA-256) to a JSON manifest shared by a batch of runs")
+	outDir := flag.String("out", "", "write the outputs into this directory, or upload them to s3://bucket/prefix/ or gs://bucket/prefix/ (named after the input unless an output is given)")
+	contentType := flag.String("content-type", "", "Content-Type of images uploaded with --out (default: from the file extension)")
+	webhook := flag.String("webhook", "", "POST a JSON completion notification (id, status, outputs, duration, warnings) to this URL when the run finishes")
+	cacheControl := flag.String("cache-control", "", "Cache-Control header of files uploaded with --out, e.g. public, max-age=300")
+	flag.Usage = usage
+
+	// Flags may come before, between, or after the input and output files
+	var files []string
+	for args := os.Args[1:]; ; args = flag.Args()[1:] {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				os.Exit(exitOK)
+			}
+			os.Exit(exitUsage)
+		}
+		if flag.NArg() == 0 {
+			break
+		}
+		files = append(files, flag.Arg(0))
+	}
+
+	if len(files) < 1 {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	inputFile := files[0]
 	outputFile := "output.png"
 
-	if len(os.Args) > 2 {
-		outputFile = os.Args[2]
+	if len(files) > 1 {
+		outputFile = files[1]
+	}
+	started := time.Now()
+	run := &cliRun{summaryFile: *summaryFile, summary: RunSummary{Input: inputFile, Output: outputFile}, webhook: *webhook, started: started}
+	if len(files) > 2 {
+		run.fail(exitUsage, "Error: unexpected argument %s (give one input and at most one output file)", files[2])
+	}
+
+	// --out puts the outputs in a directory, or streams them to object
+	// storage, where they are named by the base name of the output
+	var store *objectStore
+	if *outDir != "" {
+		if len(files) < 2 {
+			outputFile = defaultOutputName(inputFile)
+		}
+		if isObjectStoreURL(*outDir) {
+			var err error
+			if store, err = newObjectStore(*outDir); err != nil {
+				run.fail(exitUsage, "Error: --out: %v", err)
+			}
+			outputFile = filepath.Base(outputFile)
+		} else {
+			if err := os.MkdirAll(*outDir, 0755); err != nil {
+				run.fail(exitRender, "Error creating output directory: %v", err)
+			}
+			outputFile = filepath.Join(*outDir, filepath.Base(outputFile))
+		}
 	}
 
-	// Read the CML file
-	content, err := ioutil.ReadFile(inputFile)
+	// location is where an output ends up: its path, or its URL once uploaded
+	location := func(path string) string {
+		if store != nil {
+			return store.url(filepath.Base(path))
+		}
+		return path
+	}
+	run.summary.Output = location(outputFile)
+
+	var thumbWidth, thumbHeight int
+	if *thumbnail != "" {
+		var err error
+		thumbWidth, thumbHeight, err = parseSize(*thumbnail)
+		if err != nil {
+			run.fail(exitUsage, "Error: --thumbnail: %v", err)
+		}
+	}
+
+	if *width < 0 || *height < 0 {
+		run.fail(exitUsage, "Error: --width and --height must be positive")
+	}
+	if *width > cml.MaxCanvasSize || *height > cml.MaxCanvasSize {
+		run.fail(exitUsage, "Error: --width and --height can be at most %d", cml.MaxCanvasSize)
+	}
+	if *scale <= 0 {
+		run.fail(exitUsage, "Error: --scale must be positive")
+	}
+	if *thumbnail != "" {
+		if err := checkScaledSize(render.Size{Width: thumbWidth, Height: thumbHeight}, *scale); err != nil {
+			run.fail(exitUsage, "Error: --thumbnail: %v", err)
+		}
+	}
+	var theme *cml.Theme
+	if *themeName != "" {
+		if builtin, ok := cml.Themes[*themeName]; ok {
+			theme = &builtin
+		} else if loaded, err := cml.LoadTheme(*themeName); err == nil {
+			theme = &loaded
+		} else {
+			run.fail(exitUsage, "Error: --theme: %v", err)
+		}
+	}
+
+	// Each size gets its own output file
+	var sizes []render.Size
+	outputs := []string{outputFile}
+	if *sizesList != "" {
+		var err error
+		sizes, err = parseSizes(*sizesList)
+		if err != nil {
+			run.fail(exitUsage, "Error: --sizes: %v", err)
+		}
+		if *imageMapFile != "" {
+			run.fail(exitUsage, "Error: --image-map can't be combined with --sizes")
+		}
+		if *width != 0 || *height != 0 {
+			run.fail(exitUsage, "Error: --width and --height can't be combined with --sizes")
+		}
+		outputs = make([]string, len(sizes))
+		for i, size := range sizes {
+			outputs[i] = sizedPath(outputFile, size)
+		}
+	}
+
+	// Start profiling before any parsing so the whole run is captured
+	stopProfiling, err := startProfiling(*cpuProfile, *memProfile)
 	if err != nil {
-		fmt.Printf("Error reading file %s: %v\n", inputFile, err)
-		os.Exit(1)
+		run.fail(exitRender, "Error starting profiler: %v", err)
 	}
 
-	// Parse the CML content
-	parser := NewCMLParser()
-	chart, err := parser.Parse(string(content))
+	// Open the CML file
+	file, err := os.Open(inputFile)
 	if err != nil {
-		fmt.Printf("Error parsing CML: %v\n", err)
-		os.Exit(1)
+		run.fail(exitParse, "Error reading file %s: %v", inputFile, err)
+	}
+	defer file.Close()
+
+	// Parse the CML content (gzip/zstd input is detected automatically)
+	parser := cml.NewCMLParser()
+	parser.BaseDir = filepath.Dir(inputFile)
+	parser.Lenient = *lenient
+	parser.Limits = cml.ParseLimits{
+		MaxBars:       *maxBars,
+		MaxDrawings:   *maxDrawings,
+		MaxLineLength: *maxLineLength,
+		MaxFileSize:   *maxFileSize,
+	}
+	if *dataFile != "" {
+		parser.TemplateData, err = cml.LoadTemplateData(*dataFile)
+		if err != nil {
+			run.fail(exitParse, "Error reading data file %s: %v", *dataFile, err)
+		}
+	}
+	chart, err := parser.ParseReader(file)
+	if err != nil {
+		run.fail(exitParse, "%s", parseErrorMessage(err))
+	}
+
+	// Report lines skipped by a lenient parse
+	for _, warning := range chart.Warnings {
+		run.printf("Warning: %s\n", warning)
+	}
+	run.setWarnings(chart.Warnings)
+
+	// Apply PNG encoding flags on top of the chart's settings
+	pngOptions := chart.GetPNGOptions()
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "png-compression":
+			pngOptions.Compression = *pngCompression
+		case "png-colors":
+			pngOptions.Colors = *pngColors
+		case "png-interlace":
+			pngOptions.Interlace = *pngInterlace
+		}
+	})
+	if pngOptions.Colors != 0 && (pngOptions.Colors < 2 || pngOptions.Colors > 256) {
+		run.fail(exitUsage, "Error: --png-colors must be between 2 and 256")
+	}
+
+	if *sizesList == "" {
+		sizes = []render.Size{canvasSize(chart, *width, *height)}
+	}
+	for _, size := range sizes {
+		if err := checkScaledSize(size, *scale); err != nil {
+			run.fail(exitUsage, "Error: canvas %v", err)
+		}
 	}
 
 	// Render the chart
-	renderer := NewCMLRenderer(800, 600)
-	err = renderer.Render(chart, outputFile)
+	renderer := render.NewScaledCMLRenderer(sizes[0].Width, sizes[0].Height, *scale)
+	renderer.PNGOptions = &pngOptions
+	renderer.PrintMode = *printMode
+	renderer.Theme = theme
+	renderer.CollectRegions = *imageMapFile != ""
+	hashes := make([]string, len(outputs))
+	switch {
+	case store != nil && *sizesList != "":
+		err = renderer.RenderSizesTo(chart, sizes, func(i int, encode func(w io.Writer) error) error {
+			hash, err := store.upload(outputs[i], *contentType, *cacheControl, encode)
+			hashes[i] = hash
+			return err
+		})
+	case store != nil:
+		hashes[0], err = store.upload(outputFile, *contentType, *cacheControl, func(w io.Writer) error {
+			return renderer.RenderTo(chart, w, "png")
+		})
+	case *sizesList != "":
+		err = renderer.RenderSizes(chart, sizes, outputs)
+	default:
+		err = renderer.Render(chart, outputFile)
+	}
+	renderer.Close()
+	if err != nil {
+		run.fail(exitRender, "Error rendering chart: %v", err)
+	}
+	duration := time.Since(started)
+
+	// Write the image map sidecar
+	if *imageMapFile != "" {
+		if err := writeImageMap(renderer.ImageMap(location(outputFile)), *imageMapFile); err != nil {
+			run.fail(exitRender, "Error writing image map: %v", err)
+		}
+	}
+
+	// Write the alt text alongside the main image
+	if *altText {
+		textFile := altTextPath(outputFile)
+		text := cml.Describe(chart) + "\n"
+		if store != nil {
+			_, err = store.upload(textFile, "", *cacheControl, func(w io.Writer) error {
+				_, err := io.WriteString(w, text)
+				return err
+			})
+		} else {
+			err = os.WriteFile(textFile, []byte(text), 0644)
+		}
+		if err != nil {
+			run.fail(exitRender, "Error writing alt text: %v", err)
+		}
+		run.printf("Alt text written to %s\n", location(textFile))
+	}
+
+	// Render the thumbnail alongside the main image
+	if *thumbnail != "" {
+		thumbFile := thumbnailPath(outputFile)
+		thumbRenderer := render.NewScaledCMLRenderer(thumbWidth, thumbHeight, *scale)
+		thumbRenderer.PNGOptions = &pngOptions
+		thumbRenderer.PrintMode = *printMode
+		thumbRenderer.Theme = theme
+		if store != nil {
+			_, err = store.upload(thumbFile, "", *cacheControl, func(w io.Writer) error {
+				return thumbRenderer.RenderThumbnailTo(chart, w)
+			})
+		} else {
+			err = thumbRenderer.RenderThumbnail(chart, thumbFile)
+		}
+		thumbRenderer.Close()
+		if err != nil {
+			run.fail(exitRender, "Error rendering thumbnail: %v", err)
+		}
+		run.printf("Thumbnail rendered to %s\n", location(thumbFile))
+	}
+
+	// Write statistics for report generators
+	if *statsFile != "" {
+		if err := writeStats(chart, *statsFile); err != nil {
+			run.fail(exitRender, "Error writing statistics: %v", err)
+		}
+	}
+
+	// Record the renders in the batch manifest
+	for i := 0; *manifestFile != "" && i < len(outputs); i++ {
+		imageSize := sizes[i].Scaled(*scale)
+		hash := hashes[i]
+		if hash == "" {
+			if hash, err = fileSHA256(outputs[i]); err != nil {
+				run.fail(exitRender, "Error hashing output: %v", err)
+			}
+		}
+		err = UpdateManifest(*manifestFile, ManifestEntry{
+			Input:      inputFile,
+			Output:     location(outputs[i]),
+			Width:      imageSize.Width,
+			Height:     imageSize.Height,
+			DurationMS: float64(duration.Microseconds()) / 1000,
+			Warnings:   chart.Warnings,
+			SHA256:     hash,
+		})
+		if err != nil {
+			run.fail(exitRender, "Error writing manifest: %v", err)
+		}
+	}
+
+	if err := stopProfiling(); err != nil {
+		run.fail(exitRender, "Error writing profile: %v", err)
+	}
+
+	published := make([]string, len(outputs))
+	for i, output := range outputs {
+		published[i] = location(output)
+	}
+	run.published = published
+	run.printf("Chart rendered successfully to %s\n", strings.Join(published, ", "))
+	if *strict && len(chart.Warnings) > 0 {
+		run.printf("Error: %d warning(s) with --strict\n", len(chart.Warnings))
+		run.exit(exitWarnings)
+	}
+	run.exit(exitOK)
+}
+
+// runCalendar renders a chart's daily returns as a calendar heatmap
+func runCalendar(args []string) {
+	flags := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	lenient := flags.Bool("lenient", false, "skip malformed bar and drawing lines instead of failing")
+	size := flags.String("size", "", "canvas WIDTHxHEIGHT (default 800 wide, tall enough for every year)")
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer calendar [flags] <input.cml> [output.png]")
+		fmt.Println("")
+		fmt.Println("Flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	inputFile := flags.Arg(0)
+	outputFile := "calendar.png"
+	if flags.NArg() > 1 {
+		outputFile = flags.Arg(1)
+	}
+
+	file, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+		os.Exit(exitParse)
+	}
+	defer file.Close()
+
+	parser := cml.NewCMLParser()
+	parser.BaseDir = filepath.Dir(inputFile)
+	parser.Lenient = *lenient
+	chart, err := parser.ParseReader(file)
+	if err != nil {
+		fmt.Println(parseErrorMessage(err))
+		os.Exit(exitParse)
+	}
+	for _, warning := range chart.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	width, height := 800, 0
+	if *size != "" {
+		width, height, err = parseSize(*size)
+		if err != nil {
+			fmt.Printf("Error: --size: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	} else {
+		height = render.CalendarHeight(chart, width)
+	}
+
+	renderer := render.NewCMLRenderer(width, height)
+	err = renderer.RenderCalendar(chart, outputFile)
+	renderer.Close()
+	if err != nil {
+		fmt.Printf("Error rendering calendar: %v\n", err)
+		os.Exit(exitRender)
+	}
+
+	fmt.Printf("Calendar rendered successfully to %s\n", outputFile)
+}
+
+// runEventStudy renders the average price path around a list of events
+func runEventStudy(args []string) {
+	flags := flag.NewFlagSet("eventstudy", flag.ContinueOnError)
+	lenient := flags.Bool("lenient", false, "skip malformed bar and drawing lines instead of failing")
+	events := flags.String("events", "", "comma-separated event datetimes, e.g. \"2025/01/15 09:30, 2025/04/15 09:30\"")
+	eventsFile := flags.String("events-file", "", "file of event datetimes, one per line (# starts a comment)")
+	window := flags.Int("window", 10, "bars to show before and after each event")
+	band := flags.String("band", "10,90", "LOWER,UPPER percentiles of the band around the mean path")
+	size := flags.String("size", "800x600", "canvas WIDTHxHEIGHT")
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer eventstudy [flags] <input.cml> [output.png]")
+		fmt.Println("")
+		fmt.Println("Flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	inputFile := flags.Arg(0)
+	outputFile := "eventstudy.png"
+	if flags.NArg() > 1 {
+		outputFile = flags.Arg(1)
+	}
+
+	width, height, err := parseSize(*size)
+	if err != nil {
+		fmt.Printf("Error: --size: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	lower, upper, err := parseBand(*band)
+	if err != nil {
+		fmt.Printf("Error: --band: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// Events come from the flag, the file, or both
+	parser := cml.NewCMLParser()
+	lines := strings.Split(*events, ",")
+	if *eventsFile != "" {
+		content, err := os.ReadFile(*eventsFile)
+		if err != nil {
+			fmt.Printf("Error reading events file %s: %v\n", *eventsFile, err)
+			os.Exit(exitParse)
+		}
+		lines = append(lines, strings.Split(string(content), "\n")...)
+	}
+	var eventTimes []time.Time
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, err := parser.ParseDateTime(line)
+		if err != nil {
+			fmt.Printf("Error parsing event: %v\n", err)
+			os.Exit(exitParse)
+		}
+		eventTimes = append(eventTimes, t)
+	}
+	if len(eventTimes) == 0 {
+		fmt.Println("Error: no events given (use --events or --events-file)")
+		os.Exit(exitUsage)
+	}
+
+	file, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+		os.Exit(exitParse)
+	}
+	defer file.Close()
+
+	parser.BaseDir = filepath.Dir(inputFile)
+	parser.Lenient = *lenient
+	chart, err := parser.ParseReader(file)
+	if err != nil {
+		fmt.Println(parseErrorMessage(err))
+		os.Exit(exitParse)
+	}
+	for _, warning := range chart.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	// Back-adjust per the adjust setting, so splits are not read as moves
+	chart = render.AdjustChart(chart)
+	study, err := render.ComputeEventStudy(chart.Bars, eventTimes, *window, lower, upper)
+	for _, skipped := range study.Skipped {
+		fmt.Printf("Warning: skipped event %s: fewer than %d bars on one side\n", skipped.Format("2006/01/02 15:04"), *window)
+	}
 	if err != nil {
-		fmt.Printf("Error rendering chart: %v\n", err)
-		os.Exit(1)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitParse)
 	}
 
-	fmt.Printf("Chart rendered successfully to %s\n", outputFile)
+	renderer := render.NewCMLRenderer(width, height)
+	err = renderer.RenderEventStudy(chart, study, outputFile)
+	renderer.Close()
+	if err != nil {
+		fmt.Printf("Error rendering event study: %v\n", err)
+		os.Exit(exitRender)
+	}
+
+	fmt.Printf("Event study of %d events rendered successfully to %s\n", len(study.Events), outputFile)
+}
+
+// runReport renders several charts into a PDF of small multiples
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	lenient := flags.Bool("lenient", false, "skip malformed bar and drawing lines instead of failing")
+	outputFile := flags.String("out", "report.pdf", "PDF file to write")
+	title := flags.String("title", "Chart report", "title at the top of every page")
+	columns := flags.Int("columns", 2, "charts across each page")
+	rows := flags.Int("rows", 2, "charts down each page")
+	table := flags.Bool("table", false, "add a summary table of each chart's statistics after the charts")
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer report [flags] <input.cml>... --out report.pdf")
+		fmt.Println("")
+		fmt.Println("Flags:")
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before, between, or after the input files
+	var inputFiles []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				os.Exit(exitOK)
+			}
+			os.Exit(exitUsage)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		inputFiles = append(inputFiles, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if len(inputFiles) == 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	if *columns < 1 || *rows < 1 {
+		fmt.Println("Error: --columns and --rows must be at least 1")
+		os.Exit(exitUsage)
+	}
+
+	charts := make([]render.ReportChart, 0, len(inputFiles))
+	for _, inputFile := range inputFiles {
+		file, err := os.Open(inputFile)
+		if err != nil {
+			fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+			os.Exit(exitParse)
+		}
+		parser := cml.NewCMLParser()
+		parser.BaseDir = filepath.Dir(inputFile)
+		parser.Lenient = *lenient
+		chart, err := parser.ParseReader(file)
+		file.Close()
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", inputFile, err)
+			os.Exit(exitParse)
+		}
+		for _, warning := range chart.Warnings {
+			fmt.Printf("Warning: %s: %s\n", inputFile, warning)
+		}
+
+		// The summary table names charts by title, or by file name without one
+		name := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+		for _, entry := range chart.Meta {
+			if title, ok := entry.Value.(string); ok && entry.Key == "title" && title != "" {
+				name = title
+			}
+		}
+		charts = append(charts, render.ReportChart{Name: name, Chart: chart})
+	}
+
+	file, err := os.Create(*outputFile)
+	if err != nil {
+		fmt.Printf("Error creating %s: %v\n", *outputFile, err)
+		os.Exit(exitRender)
+	}
+	err = render.WriteReport(file, charts, render.ReportOptions{Title: *title, Columns: *columns, Rows: *rows, Table: *table})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Printf("Error writing report: %v\n", err)
+		os.Exit(exitRender)
+	}
+
+	fmt.Printf("Report of %d charts written successfully to %s\n", len(charts), *outputFile)
+}
+
+// runCheck parses CML files without rendering them and lists every problem
+// in each, one per line as file:line:column: message
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer check <input.cml>...")
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	code := exitOK
+	for _, inputFile := range flags.Args() {
+		file, err := os.Open(inputFile)
+		if err != nil {
+			fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+			code = exitParse
+			continue
+		}
+		parser := cml.NewCMLParser()
+		parser.BaseDir = filepath.Dir(inputFile)
+		chart, err := parser.ParseAllReader(file)
+		file.Close()
+
+		var parseErrs cml.ParseErrors
+		var parseErr *cml.ParseError
+		switch {
+		case errors.As(err, &parseErrs):
+		case errors.As(err, &parseErr):
+			parseErrs = cml.ParseErrors{parseErr}
+		case err != nil:
+			parseErrs = cml.ParseErrors{{Msg: err.Error()}}
+		}
+		for _, parseErr := range parseErrs {
+			if parseErr.Line == 0 {
+				fmt.Printf("%s: %s\n", inputFile, parseErr.Msg)
+			} else {
+				fmt.Printf("%s:%d:%d: %s\n", inputFile, parseErr.Line, parseErr.Col, parseErr.Msg)
+			}
+			code = exitParse
+		}
+		if chart != nil {
+			for _, warning := range chart.Warnings {
+				fmt.Printf("Warning: %s: %s\n", inputFile, warning)
+			}
+		}
+	}
+	os.Exit(code)
+}
+
+// runConvert converts a chart between CML and JSON, each way picked by the
+// file extensions
+func runConvert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer convert <input.cml|input.json> <output.json|output.cml>")
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	inputFile, outputFile := flags.Arg(0), flags.Arg(1)
+
+	var chart *cml.Chart
+	if isJSONFile(inputFile) {
+		content, err := os.ReadFile(inputFile)
+		if err != nil {
+			fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+			os.Exit(exitParse)
+		}
+		chart = &cml.Chart{}
+		if err := json.Unmarshal(content, chart); err != nil {
+			fmt.Printf("Error parsing JSON: %v\n", err)
+			os.Exit(exitParse)
+		}
+	} else {
+		file, err := os.Open(inputFile)
+		if err != nil {
+			fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+			os.Exit(exitParse)
+		}
+		parser := cml.NewCMLParser()
+		parser.BaseDir = filepath.Dir(inputFile)
+		chart, err = parser.ParseReader(file)
+		file.Close()
+		if err != nil {
+			fmt.Println(parseErrorMessage(err))
+			os.Exit(exitParse)
+		}
+	}
+
+	var output []byte
+	var err error
+	if isJSONFile(outputFile) {
+		output, err = json.MarshalIndent(chart, "", "  ")
+		output = append(output, '\n')
+	} else {
+		var text string
+		text, err = cml.Marshal(chart)
+		output = []byte(text)
+	}
+	if err != nil {
+		fmt.Printf("Error converting chart: %v\n", err)
+		os.Exit(exitRender)
+	}
+	if err := os.WriteFile(outputFile, output, 0644); err != nil {
+		fmt.Printf("Error writing file %s: %v\n", outputFile, err)
+		os.Exit(exitRender)
+	}
+
+	fmt.Printf("Chart converted successfully to %s\n", outputFile)
+}
+
+// isJSONFile reports whether a file name has a .json extension
+func isJSONFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json")
+}
+
+// parseBand parses a percentile band like "10,90"
+func parseBand(band string) (float64, float64, error) {
+	parts := strings.Split(band, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid band %q (want LOWER,UPPER)", band)
+	}
+	lower, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	upper, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lower < 0 || upper > 100 || lower >= upper {
+		return 0, 0, fmt.Errorf("invalid band %q (want percentiles with 0 <= LOWER < UPPER <= 100)", band)
+	}
+	return lower, upper, nil
+}
+
+// writeImageMap writes an image map as HTML or JSON depending on the file extension
+func writeImageMap(imageMap render.ImageMap, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".html" || ext == ".htm" {
+		err = imageMap.WriteHTML(file)
+	} else {
+		err = imageMap.WriteJSON(file)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeStats writes the chart's statistics to a JSON file
+func writeStats(chart *cml.Chart, path string) error {
+	data, err := json.MarshalIndent(render.Analyze(chart), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// startProfiling starts CPU profiling when cpuFile is set; the returned stop
+// function ends it and writes a heap profile when memFile is set
+func startProfiling(cpuFile, memFile string) (func() error, error) {
+	var cpuOut *os.File
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cpuOut = f
+	}
+
+	return func() error {
+		if cpuOut != nil {
+			pprof.StopCPUProfile()
+			if err := cpuOut.Close(); err != nil {
+				return err
+			}
+		}
+
+		if memFile != "" {
+			f, err := os.Create(memFile)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			runtime.GC() // Get up-to-date allocation statistics
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// usage prints command line help
+func usage() {
+	fmt.Println("Usage: cml-renderer [flags] <input.cml> [output.png]")
+	fmt.Println("       cml-renderer calendar [flags] <input.cml> [output.png]")
+	fmt.Println("       cml-renderer eventstudy --events DATETIMES [flags] <input.cml> [output.png]")
+	fmt.Println("       cml-renderer report [flags] <input.cml>... --out report.pdf")
+	fmt.Println("       cml-renderer check <input.cml>...")
+	fmt.Println("       cml-renderer convert <input.cml|input.json> <output.json|output.cml>")
+	fmt.Println("       cml-renderer publish (--slack-channel CHANNEL | --discord-channel ID) [flags] <input.cml>")
+	fmt.Println("       cml-renderer init [--force] [output.cml]")
+	fmt.Println("Example: cml-renderer example.cml chart.png")
+	fmt.Println("")
+	fmt.Println("Flags:")
+	flag.PrintDefaults()
+	fmt.Println("")
+	fmt.Printf("Version: %s\n", Version)
+	fmt.Printf("Build Time: %s\n", BuildTime)
+	fmt.Printf("Git Ref: %s\n", GitRef)
 }
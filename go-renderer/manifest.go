@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Manifest lists the charts rendered by a batch of runs, so publishing steps
+// can check every chart was rendered and spot the ones that changed
+type Manifest struct {
+	Charts []ManifestEntry `json:"charts"`
+}
+
+// ManifestEntry describes one rendered chart
+type ManifestEntry struct {
+	Input      string   `json:"input"`
+	Output     string   `json:"output"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	DurationMS float64  `json:"duration_ms"` // Parse and render time
+	Warnings   []string `json:"warnings"`
+	SHA256     string   `json:"sha256"` // Hex digest of the output file
+}
+
+// UpdateManifest adds an entry to the manifest file, replacing any entry for
+// the same output, and creates the file if needed. Entries are kept sorted by
+// output path so the file is stable across runs, and the file is replaced
+// atomically so a failed write never leaves a truncated manifest. Runs
+// updating the same manifest at once take turns, holding an exclusive lock
+// on a .lock file beside it, so none loses another's entry.
+func UpdateManifest(path string, entry ManifestEntry) error {
+	unlock, err := lockManifest(path)
+	if err != nil {
+		return fmt.Errorf("locking manifest %s: %v", path, err)
+	}
+	defer unlock()
+
+	// The new manifest keeps the old one's permissions; CreateTemp's 0600
+	// would hide it from other users
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	var manifest Manifest
+	content, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return fmt.Errorf("invalid manifest %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if entry.Warnings == nil {
+		entry.Warnings = []string{}
+	}
+	kept := manifest.Charts[:0]
+	for _, existing := range manifest.Charts {
+		if existing.Output != entry.Output {
+			kept = append(kept, existing)
+		}
+	}
+	manifest.Charts = append(kept, entry)
+	sort.Slice(manifest.Charts, func(i, j int) bool { return manifest.Charts[i].Output < manifest.Charts[j].Output })
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".manifest-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(append(data, '\n')); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// lockManifest waits for an exclusive lock on the manifest's lock file and
+// returns a function that releases it. The manifest itself can't be locked,
+// since each update replaces it with a new file.
+func lockManifest(path string) (func(), error) {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// fileSHA256 returns the hex SHA-256 digest of a file's contents
+func fileSHA256(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// objectStore is a bucket and key prefix in S3 (or an S3-compatible store)
+// or Google Cloud Storage that renders are uploaded to
+type objectStore struct {
+	scheme string // "s3" or "gs"
+	bucket string
+	prefix string // Key prefix, ending in "/" unless empty
+
+	// S3 credentials and region, from the standard AWS environment variables
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+
+	// GCS OAuth access token, e.g. from gcloud auth print-access-token
+	gcsToken string
+}
+
+// isObjectStoreURL reports whether an --out location is an s3:// or gs:// URL
+// rather than a local directory
+func isObjectStoreURL(location string) bool {
+	return strings.HasPrefix(location, "s3://") || strings.HasPrefix(location, "gs://")
+}
+
+// newObjectStore parses an s3://bucket/prefix/ or gs://bucket/prefix/ URL and
+// reads the credentials for it from the environment, so a missing credential
+// fails before anything is rendered
+func newObjectStore(location string) (*objectStore, error) {
+	scheme, rest, _ := strings.Cut(location, "://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket in %s", location)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	store := &objectStore{scheme: scheme, bucket: bucket, prefix: prefix}
+
+	switch scheme {
+	case "s3":
+		store.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		store.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		store.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		store.region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+		if store.region == "" {
+			store.region = "us-east-1"
+		}
+		if store.accessKey == "" || store.secretKey == "" {
+			return nil, fmt.Errorf("uploading to %s needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", location)
+		}
+	case "gs":
+		store.gcsToken = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+		if store.gcsToken == "" && os.Getenv("STORAGE_EMULATOR_HOST") == "" {
+			return nil, fmt.Errorf("uploading to %s needs GOOGLE_OAUTH_ACCESS_TOKEN (e.g. from gcloud auth print-access-token)", location)
+		}
+	}
+	return store, nil
+}
+
+// url returns the s3:// or gs:// URL of an uploaded file
+func (s *objectStore) url(name string) string {
+	return s.scheme + "://" + s.bucket + "/" + s.prefix + name
+}
+
+// s3PartSize is the size of the parts a render too big for one S3 PUT is
+// uploaded in, the smallest S3 allows
+const s3PartSize = 5 << 20
+
+// upload streams what write produces to the store under the given name and
+// returns its hex SHA-256. The content type defaults to one from the name's
+// extension.
+func (s *objectStore) upload(name, contentType, cacheControl string, write func(w io.Writer) error) (string, error) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	if cacheControl != "" {
+		header.Set("Cache-Control", cacheControl)
+	}
+
+	// Encode in the background into a pipe that the request body reads from
+	body, w := io.Pipe()
+	hash := sha256.New()
+	written := make(chan error, 1)
+	go func() {
+		buffered := bufio.NewWriter(io.MultiWriter(w, hash))
+		err := write(buffered)
+		if err == nil {
+			err = buffered.Flush()
+		}
+		w.CloseWithError(err)
+		written <- err
+	}()
+
+	var err error
+	switch s.scheme {
+	case "s3":
+		err = s.putS3(s.prefix+name, body, header)
+	default:
+		err = s.putGCS(s.prefix+name, body, header)
+	}
+	// A failed upload stops reading; closing the pipe unblocks the encoder
+	body.CloseWithError(err)
+	if writeErr := <-written; writeErr != nil && writeErr != io.ErrClosedPipe {
+		return "", writeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("uploading %s: %v", s.url(name), err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// putGCS uploads an object of unknown length in one chunked PUT
+func (s *objectStore) putGCS(key string, body io.Reader, header http.Header) error {
+	req, err := s.gcsRequest(key, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	_, err = send(req)
+	return err
+}
+
+// putS3 uploads an object of unknown length. S3 needs the length of every
+// PUT up front, so the body is read a part at a time: one that fits in a
+// single part is sent with a plain PUT, a larger one as a multipart upload.
+func (s *objectStore) putS3(key string, body io.Reader, header http.Header) error {
+	part := make([]byte, s3PartSize)
+	n, err := io.ReadFull(body, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_, err = s.sendS3(http.MethodPut, key, nil, part[:n], header)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.sendS3(http.MethodPost, key, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return err
+	}
+	var started struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp.body, &started); err != nil || started.UploadID == "" {
+		return fmt.Errorf("starting multipart upload: no upload ID in %q", resp.body)
+	}
+	upload := url.Values{"uploadId": {started.UploadID}}
+
+	if err := s.putS3Parts(key, started.UploadID, body, part); err != nil {
+		s.sendS3(http.MethodDelete, key, upload, nil, nil)
+		return err
+	}
+	return nil
+}
+
+// putS3Parts uploads the parts of a multipart upload, starting with the one
+// already read into part, then completes it
+func (s *objectStore) putS3Parts(key, uploadID string, body io.Reader, part []byte) error {
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	var completed struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}
+
+	n, last := len(part), false
+	for number := 1; n > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.sendS3(http.MethodPut, key, query, part[:n], nil)
+		if err != nil {
+			return err
+		}
+		completed.Parts = append(completed.Parts, completedPart{number, resp.header.Get("ETag")})
+		if last {
+			break
+		}
+
+		n, err = io.ReadFull(body, part)
+		last = err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return err
+		}
+	}
+
+	request, err := xml.Marshal(completed)
+	if err != nil {
+		return err
+	}
+	resp, err := s.sendS3(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, request, nil)
+	if err != nil {
+		return err
+	}
+	// S3 can report a failed completion in the body of a 200 response
+	if bytes.Contains(resp.body, []byte("<Error>")) {
+		return fmt.Errorf("completing multipart upload: %s", strings.TrimSpace(string(resp.body)))
+	}
+	return nil
+}
+
+// sendS3 signs and sends a request for an object with the given query and
+// extra headers
+func (s *objectStore) sendS3(method, key string, query url.Values, body []byte, header http.Header) (response, error) {
+	req, err := s.s3Request(method, key, query, body, time.Now())
+	if err != nil {
+		return response{}, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	signS3(req, s.accessKey, s.secretKey, s.region)
+	return send(req)
+}
+
+// response is the part of an HTTP response the uploads look at
+type response struct {
+	header http.Header
+	body   []byte
+}
+
+// send sends a request, failing on a non-2xx status
+func send(req *http.Request) (response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return response{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return response{}, err
+	}
+	if resp.StatusCode/100 != 2 {
+		message := body
+		if len(message) > 1024 {
+			message = message[:1024]
+		}
+		return response{}, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return response{header: resp.Header, body: body}, nil
+}
+
+// s3Request builds an unsigned request for an object: path-style to the
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL endpoint of an S3-compatible
+// store, if set, or else to the bucket's virtual-hosted AWS endpoint
+func (s *objectStore) s3Request(method, key string, query url.Values, body []byte, now time.Time) (*http.Request, error) {
+	target := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escapeKey(key))
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		target = strings.TrimSuffix(endpoint, "/") + "/" + s.bucket + "/" + escapeKey(key)
+	}
+	if len(query) > 0 {
+		// Encode sorts the keys, as the canonical request needs
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	req.Header.Set("X-Amz-Date", now.UTC().Format("20060102T150405Z"))
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	return req, nil
+}
+
+// gcsRequest builds a PUT of an object through the GCS XML API, or the
+// STORAGE_EMULATOR_HOST emulator if set
+func (s *objectStore) gcsRequest(key string, body io.Reader) (*http.Request, error) {
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = host
+		if !strings.Contains(host, "://") {
+			endpoint = "http://" + host
+		}
+	}
+	req, err := http.NewRequest(http.MethodPut, strings.TrimSuffix(endpoint, "/")+"/"+s.bucket+"/"+escapeKey(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if s.gcsToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.gcsToken)
+	}
+	return req, nil
+}
+
+// signS3 signs a request with AWS Signature Version 4, covering the host and
+// every header already set. The request must carry its X-Amz-Content-Sha256
+// and X-Amz-Date headers.
+func signS3(req *http.Request, accessKey, secretKey, region string) {
+	amzDate := req.Header.Get("X-Amz-Date")
+	date := amzDate[:8]
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + req.URL.RawQuery + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical.WriteString("\n" + signedHeaders + "\n" + req.Header.Get("X-Amz-Content-Sha256"))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapeKey percent-encodes an object key for a URL path, leaving slashes
+// and the characters SigV4 leaves unreserved
+func escapeKey(key string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			escaped.WriteByte(c)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package cml
+
+import "sync"
+
+// Annotator produces extra drawings for a parsed chart, e.g. signals loaded
+// from a database, so integrators can add markup without rewriting CML files
+type Annotator func(*Chart) []Drawing
+
+// annotators holds the registered annotators in registration order
+var (
+	annotatorsMu sync.RWMutex
+	annotators   []Annotator
+)
+
+// RegisterAnnotator adds an annotator that runs after every successful parse,
+// before the chart is rendered. Annotators run in registration order and their
+// drawings are appended after the chart's own.
+func RegisterAnnotator(annotator Annotator) {
+	annotatorsMu.Lock()
+	defer annotatorsMu.Unlock()
+	annotators = append(annotators, annotator)
+}
+
+// applyAnnotators appends the drawings from every registered annotator to the chart
+func applyAnnotators(chart *Chart) {
+	annotatorsMu.RLock()
+	registered := annotators
+	annotatorsMu.RUnlock()
+
+	for _, annotator := range registered {
+		chart.Drawings = append(chart.Drawings, annotator(chart)...)
+	}
+}
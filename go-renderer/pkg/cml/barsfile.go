@@ -0,0 +1,95 @@
+package cml
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Binary bar files store bars in a compact fixed-width layout so very large
+// histories can be memory-mapped instead of parsed as text. All values are
+// little-endian:
+//
+//	magic   [4]byte  "CMLB"
+//	version uint32   1
+//	count   uint64   number of records
+//	records count x { unixNano int64, open, high, low, close float64 }
+const (
+	binaryBarsMagic      = "CMLB"
+	binaryBarsVersion    = 1
+	binaryBarsHeaderSize = 16
+	binaryBarsRecordSize = 40
+)
+
+// ReadBinaryBars reads bars from a binary bar file
+func ReadBinaryBars(path string) ([]Bar, error) {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return decodeBinaryBars(data)
+}
+
+// decodeBinaryBars decodes the contents of a binary bar file
+func decodeBinaryBars(data []byte) ([]Bar, error) {
+	if len(data) < binaryBarsHeaderSize || string(data[:4]) != binaryBarsMagic {
+		return nil, fmt.Errorf("not a binary bar file")
+	}
+
+	version := binary.LittleEndian.Uint32(data[4:8])
+	if version != binaryBarsVersion {
+		return nil, fmt.Errorf("unsupported binary bar file version: %d", version)
+	}
+
+	count := binary.LittleEndian.Uint64(data[8:16])
+	records := data[binaryBarsHeaderSize:]
+	if uint64(len(records))/binaryBarsRecordSize < count {
+		return nil, fmt.Errorf("binary bar file truncated: expected %d bars", count)
+	}
+
+	bars := make([]Bar, count)
+	for i := range bars {
+		record := records[i*binaryBarsRecordSize : (i+1)*binaryBarsRecordSize]
+		bars[i] = Bar{
+			DateTime: time.Unix(0, int64(binary.LittleEndian.Uint64(record[0:8]))).UTC(),
+			Open:     math.Float64frombits(binary.LittleEndian.Uint64(record[8:16])),
+			High:     math.Float64frombits(binary.LittleEndian.Uint64(record[16:24])),
+			Low:      math.Float64frombits(binary.LittleEndian.Uint64(record[24:32])),
+			Close:    math.Float64frombits(binary.LittleEndian.Uint64(record[32:40])),
+		}
+	}
+
+	return bars, nil
+}
+
+// WriteBinaryBars writes bars in the binary bar file format
+func WriteBinaryBars(w io.Writer, bars []Bar) error {
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, binaryBarsHeaderSize)
+	copy(header, binaryBarsMagic)
+	binary.LittleEndian.PutUint32(header[4:8], binaryBarsVersion)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(len(bars)))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]byte, binaryBarsRecordSize)
+	for _, bar := range bars {
+		binary.LittleEndian.PutUint64(record[0:8], uint64(bar.DateTime.UnixNano()))
+		binary.LittleEndian.PutUint64(record[8:16], math.Float64bits(bar.Open))
+		binary.LittleEndian.PutUint64(record[16:24], math.Float64bits(bar.High))
+		binary.LittleEndian.PutUint64(record[24:32], math.Float64bits(bar.Low))
+		binary.LittleEndian.PutUint64(record[32:40], math.Float64bits(bar.Close))
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,237 @@
+package cml
+
+// Clone returns a deep copy of the chart, so a pipeline can change the copy
+// without touching the original
+func (c *Chart) Clone() *Chart {
+	clone := &Chart{
+		Meta:             append([]MetaEntry(nil), c.Meta...),
+		Settings:         make([]SettingsEntry, len(c.Settings)),
+		Bars:             cloneBars(c.Bars),
+		Drawings:         make([]Drawing, len(c.Drawings)),
+		Indicators:       make([]Indicator, len(c.Indicators)),
+		Series:           make([]Series, len(c.Series)),
+		Positions:        append([]Position(nil), c.Positions...),
+		Ticks:            append([]Tick(nil), c.Ticks...),
+		CorporateActions: append([]CorporateAction(nil), c.CorporateActions...),
+		Contracts:        make([]Contract, len(c.Contracts)),
+		Fan:              cloneFan(c.Fan),
+		Warnings:         append([]string(nil), c.Warnings...),
+		fileBars:         c.fileBars,
+		barDrawings:      make([]Drawing, len(c.barDrawings)),
+	}
+	for i, entry := range c.Settings {
+		clone.Settings[i] = SettingsEntry{Key: entry.Key, Value: cloneSettingValue(entry.Value)}
+	}
+	for i, drawing := range c.Drawings {
+		clone.Drawings[i] = cloneDrawing(drawing)
+	}
+	for i, drawing := range c.barDrawings {
+		clone.barDrawings[i] = cloneDrawing(drawing)
+	}
+	for i, indicator := range c.Indicators {
+		clone.Indicators[i] = Indicator{Name: indicator.Name, Parameters: cloneStyles(indicator.Parameters)}
+	}
+	for i, series := range c.Series {
+		clone.Series[i] = cloneSeries(series)
+	}
+	for i, contract := range c.Contracts {
+		contract.Bars = cloneBars(contract.Bars)
+		clone.Contracts[i] = contract
+	}
+	return clone
+}
+
+// Merge composes a chart from a base chart, usually holding the bars, and an
+// overlay, usually an annotation file. The overlay's drawings, indicators,
+// positions, and corporate actions are added to the base's; its series
+// replace base series of the same name and are otherwise added; its fan, if
+// any, replaces the base's; its meta and settings entries override base
+// entries with the same key. Bars, ticks, and
+// contracts come from the base, or from the overlay when the base has no bars.
+// Neither input is modified.
+func Merge(base, overlay *Chart) *Chart {
+	merged := base.Clone()
+	added := overlay.Clone()
+
+	if len(merged.Bars) == 0 {
+		merged.Bars = added.Bars
+		merged.Ticks = added.Ticks
+		merged.Contracts = added.Contracts
+		merged.fileBars = added.fileBars
+	}
+
+	for _, entry := range added.Meta {
+		merged.Meta = append(removeMeta(merged.Meta, entry.Key), entry)
+	}
+	for _, entry := range added.Settings {
+		merged.Settings = append(removeSetting(merged.Settings, entry.Key), entry)
+	}
+
+	merged.Drawings = append(merged.Drawings, added.Drawings...)
+	merged.barDrawings = append(merged.barDrawings, added.barDrawings...)
+	merged.Indicators = append(merged.Indicators, added.Indicators...)
+	merged.Positions = append(merged.Positions, added.Positions...)
+	merged.CorporateActions = append(merged.CorporateActions, added.CorporateActions...)
+	merged.Warnings = append(merged.Warnings, added.Warnings...)
+
+	if len(added.Fan.Percentiles) > 0 {
+		merged.Fan = added.Fan
+	}
+
+	for _, series := range added.Series {
+		replaced := false
+		for i := range merged.Series {
+			if merged.Series[i].Name == series.Name {
+				merged.Series[i] = series
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged.Series = append(merged.Series, series)
+		}
+	}
+	return merged
+}
+
+// removeMeta returns the meta entries without those with the given key
+func removeMeta(entries []MetaEntry, key string) []MetaEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Key != key {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// removeSetting returns the settings entries without those with the given key
+func removeSetting(entries []SettingsEntry, key string) []SettingsEntry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Key != key {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// cloneBars copies bars along with their price levels
+func cloneBars(bars []Bar) []Bar {
+	if bars == nil {
+		return nil
+	}
+	clone := make([]Bar, len(bars))
+	for i, bar := range bars {
+		bar.Levels = append([]PriceLevel(nil), bar.Levels...)
+		clone[i] = bar
+	}
+	return clone
+}
+
+// cloneSeries copies a series' parameters and points
+func cloneSeries(series Series) Series {
+	return Series{
+		Name:       series.Name,
+		Parameters: cloneStyles(series.Parameters),
+		Points:     append([]SeriesPoint(nil), series.Points...),
+	}
+}
+
+// cloneFan copies a fan's percentiles and points
+func cloneFan(fan Fan) Fan {
+	clone := Fan{Percentiles: append([]float64(nil), fan.Percentiles...)}
+	if fan.Points != nil {
+		clone.Points = make([]FanPoint, len(fan.Points))
+		for i, point := range fan.Points {
+			clone.Points[i] = FanPoint{DateTime: point.DateTime, Values: append([]float64(nil), point.Values...)}
+		}
+	}
+	return clone
+}
+
+// cloneStyles copies a style or parameter map; the values themselves are
+// strings and numbers, so a shallow copy is enough
+func cloneStyles(styles map[string]interface{}) map[string]interface{} {
+	if styles == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(styles))
+	for key, value := range styles {
+		clone[key] = value
+	}
+	return clone
+}
+
+// cloneSettingValue copies setting values that hold slices or maps
+func cloneSettingValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []string:
+		return append([]string(nil), v...)
+	case BarTagsConfig:
+		colors := make(map[string]string, len(v.Colors))
+		for tag, color := range v.Colors {
+			colors[tag] = color
+		}
+		return BarTagsConfig{Colors: colors, Order: append([]string(nil), v.Order...)}
+	}
+	return value
+}
+
+// cloneDrawing copies a drawing's styles, points, and group members
+func cloneDrawing(drawing Drawing) Drawing {
+	switch d := drawing.(type) {
+	case Rectangle:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Line:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case ContinuousLine:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Triangle:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Circle:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Note:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Curve:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Ray:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Trendline:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case HighlightBar:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case RRBox:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Cone:
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Path:
+		d.Points = append([]PathPoint(nil), d.Points...)
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Trail:
+		d.Points = append([]PathPoint(nil), d.Points...)
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	case Group:
+		members := make([]Drawing, len(d.Drawings))
+		for i, member := range d.Drawings {
+			members[i] = cloneDrawing(member)
+		}
+		d.Drawings = members
+		d.Styles = cloneStyles(d.Styles)
+		return d
+	}
+	return drawing
+}
@@ -0,0 +1,38 @@
+package cml
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressReader wraps r with a gzip or zstd decoder when its content starts
+// with the matching magic bytes, and returns it unchanged otherwise
+func decompressReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(header, zstdMagic):
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+
+	return br, nil
+}
@@ -0,0 +1,93 @@
+package cml
+
+import "fmt"
+
+// stitchContracts joins contracts into one continuous series: each contract
+// supplies its bars from the previous roll up to its own, and earlier bars are
+// back-adjusted by the gap at every later roll so the front contract keeps
+// its real prices. The gap compares both contracts' closes at the last bar
+// before the roll, or the next contract's first open when it has no bar then.
+// Each contract's Gap is set to the adjustment made at its roll.
+func stitchContracts(contracts []Contract, mode string) ([]Bar, error) {
+	segments := make([][]Bar, len(contracts))
+	firstOpens := make([]float64, len(contracts)) // Before any adjustment
+	for i, contract := range contracts {
+		last := i == len(contracts)-1
+		if last && !contract.Roll.IsZero() {
+			return nil, fmt.Errorf("last contract %s cannot have a roll date", contract.Name)
+		}
+		if !last && contract.Roll.IsZero() {
+			return nil, fmt.Errorf("contract %s needs a roll date", contract.Name)
+		}
+		if i > 0 && !last && !contract.Roll.After(contracts[i-1].Roll) {
+			return nil, fmt.Errorf("contract %s rolls before the previous contract", contract.Name)
+		}
+
+		for _, bar := range contract.Bars {
+			if i > 0 && bar.DateTime.Before(contracts[i-1].Roll) {
+				continue
+			}
+			if !last && !bar.DateTime.Before(contract.Roll) {
+				continue
+			}
+			segments[i] = append(segments[i], bar)
+		}
+		if len(segments[i]) == 0 {
+			return nil, fmt.Errorf("contract %s has no bars in use before its roll", contract.Name)
+		}
+		firstOpens[i] = segments[i][0].Open
+	}
+
+	// Gap at each roll, from the back so earlier bars collect every later gap
+	offset, scale := 0.0, 1.0
+	for i := len(contracts) - 2; i >= 0; i-- {
+		current := segments[i][len(segments[i])-1]
+		// The next segment is already adjusted by the later gaps, so compare
+		// against its own prices
+		from, to := current.Close, firstOpens[i+1]
+		for _, bar := range contracts[i+1].Bars {
+			if bar.DateTime.Equal(current.DateTime) {
+				to = bar.Close
+				break
+			}
+		}
+
+		switch mode {
+		case "difference":
+			contracts[i].Gap = to - from
+			offset += contracts[i].Gap
+		case "ratio":
+			if from != 0 {
+				contracts[i].Gap = to / from
+				scale *= contracts[i].Gap
+			}
+		}
+		for j := range segments[i] {
+			segments[i][j] = shiftBar(segments[i][j], offset, scale)
+		}
+	}
+
+	var bars []Bar
+	for _, segment := range segments {
+		bars = append(bars, segment...)
+	}
+	return bars, nil
+}
+
+// shiftBar scales a bar's prices, quotes, and levels, then offsets them
+func shiftBar(bar Bar, offset, scale float64) Bar {
+	shift := func(price float64) float64 { return price*scale + offset }
+	bar.Open, bar.High, bar.Low, bar.Close = shift(bar.Open), shift(bar.High), shift(bar.Low), shift(bar.Close)
+	if bar.HasQuote() {
+		bar.BidPrice, bar.AskPrice = shift(bar.BidPrice), shift(bar.AskPrice)
+	}
+	if len(bar.Levels) > 0 {
+		levels := make([]PriceLevel, len(bar.Levels))
+		for i, level := range bar.Levels {
+			level.Price = shift(level.Price)
+			levels[i] = level
+		}
+		bar.Levels = levels
+	}
+	return bar
+}
@@ -0,0 +1,83 @@
+package cml
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// day returns midnight UTC on the given day of January 2025
+func day(d int) time.Time {
+	return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+// flatBars returns one bar a day from first to last, all at the given price
+func flatBars(first, last int, price float64) []Bar {
+	var bars []Bar
+	for d := first; d <= last; d++ {
+		bars = append(bars, Bar{DateTime: day(d), Open: price, High: price, Low: price, Close: price})
+	}
+	return bars
+}
+
+// threeContracts returns contracts A, B, and C, where B starts trading only
+// after A's roll, so A's gap falls back to B's first open
+func threeContracts() []Contract {
+	return []Contract{
+		{Name: "A", Roll: day(3), Bars: flatBars(1, 5, 100)},
+		{Name: "B", Roll: day(5), Bars: flatBars(3, 7, 110)},
+		{Name: "C", Bars: flatBars(4, 7, 140)},
+	}
+}
+
+func TestStitchContractsDifference(t *testing.T) {
+	contracts := threeContracts()
+	bars, err := stitchContracts(contracts, "difference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A's gap is 10 and B's 30, so A ends at 140 and B at 140 like C
+	want := []float64{140, 140, 140, 140, 140, 140, 140}
+	checkCloses(t, bars, want)
+	if contracts[0].Gap != 10 || contracts[1].Gap != 30 {
+		t.Errorf("gaps %v and %v, want 10 and 30", contracts[0].Gap, contracts[1].Gap)
+	}
+}
+
+func TestStitchContractsRatio(t *testing.T) {
+	contracts := threeContracts()
+	bars, err := stitchContracts(contracts, "ratio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{140, 140, 140, 140, 140, 140, 140}
+	checkCloses(t, bars, want)
+	if math.Abs(contracts[0].Gap-1.1) > 1e-9 || math.Abs(contracts[1].Gap-140.0/110) > 1e-9 {
+		t.Errorf("gaps %v and %v, want 1.1 and %v", contracts[0].Gap, contracts[1].Gap, 140.0/110)
+	}
+}
+
+func TestStitchContractsLastBarBeforeRoll(t *testing.T) {
+	// B trades on A's last day, so A's gap compares the two closes there
+	contracts := []Contract{
+		{Name: "A", Roll: day(3), Bars: flatBars(1, 2, 100)},
+		{Name: "B", Bars: append(flatBars(2, 2, 105), flatBars(3, 4, 120)...)},
+	}
+	bars, err := stitchContracts(contracts, "difference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCloses(t, bars, []float64{105, 105, 120, 120})
+}
+
+func checkCloses(t *testing.T, bars []Bar, want []float64) {
+	t.Helper()
+	if len(bars) != len(want) {
+		t.Fatalf("got %d bars, want %d", len(bars), len(want))
+	}
+	for i, bar := range bars {
+		if math.Abs(bar.Close-want[i]) > 1e-9 {
+			t.Errorf("bar %d closes at %v, want %v", i, bar.Close, want[i])
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// maxDescribedNotes caps how many note texts a description quotes
+const maxDescribedNotes = 3
+
+// describedNames spells out indicator and drawing type names that don't read
+// well as written; other short names are upper-cased, like EMA
+var describedNames = map[string]string{
+	"bollinger":         "Bollinger Bands",
+	"chandelier":        "Chandelier Exit",
+	"relative-strength": "Relative Strength",
+	"seasonal":          "Seasonal",
+	"rr-box":            "risk/reward box",
+}
+
+// Describe summarizes a chart in plain English for alt text: what it shows,
+// the period its bars cover, how far price moved, and the indicators and
+// drawings on it. For example:
+//
+//	Intraday - 5 Minute Bars: 78 5-minute bars from March 12, 2025 09:30
+//	UTC to March 12, 2025 15:55 UTC. Price rose 2.02% from 412.50 to
+//	420.83, trading between 412.27 and 422.03. Indicators: EMA(9), EMA(21).
+//	Drawings: 1 continuous line and 2 notes ("Session high", "Session low").
+func Describe(chart *Chart) string {
+	var sentences []string
+	subject := describeSubject(chart)
+	bars := chart.Bars
+	precision := chart.GetYAxisConfig().Precision
+	price := func(p float64) string { return fmt.Sprintf("%.*f", precision, p) }
+
+	switch len(bars) {
+	case 0:
+		sentences = append(sentences, subject+" with no bars.")
+	case 1:
+		bar := bars[0]
+		sentences = append(sentences, fmt.Sprintf("%s: 1 bar at %s, opening at %s and closing at %s with a range of %s to %s.",
+			subject, describeTime(bar.DateTime, false), price(bar.Open), price(bar.Close), price(bar.Low), price(bar.High)))
+	default:
+		first, last := bars[0], bars[len(bars)-1]
+		interval := chart.Interval()
+		daily := interval >= 24*time.Hour && onMidnights(bars)
+		count := fmt.Sprintf("%d bars", len(bars))
+		if name := describeInterval(interval); name != "" {
+			count = fmt.Sprintf("%d %s bars", len(bars), name)
+		}
+		sentences = append(sentences, fmt.Sprintf("%s: %s from %s to %s.",
+			subject, count, describeTime(first.DateTime, daily), describeTime(last.DateTime, daily)))
+
+		low, high := first.Low, first.High
+		for _, bar := range bars[1:] {
+			low, high = math.Min(low, bar.Low), math.Max(high, bar.High)
+		}
+		move := "was unchanged at " + price(last.Close)
+		if first.Open != 0 && last.Close != first.Open {
+			change := (last.Close - first.Open) / math.Abs(first.Open) * 100
+			direction := "rose"
+			if change < 0 {
+				direction = "fell"
+			}
+			move = fmt.Sprintf("%s %.2f%% from %s to %s", direction, math.Abs(change), price(first.Open), price(last.Close))
+		}
+		sentences = append(sentences, fmt.Sprintf("Price %s, trading between %s and %s.", move, price(low), price(high)))
+	}
+
+	if len(chart.Indicators) > 0 {
+		labels := make([]string, len(chart.Indicators))
+		for i, indicator := range chart.Indicators {
+			labels[i] = describeIndicator(indicator)
+		}
+		sentences = append(sentences, "Indicators: "+strings.Join(labels, ", ")+".")
+	}
+	if drawings := describeDrawings(chart.Drawings); drawings != "" {
+		sentences = append(sentences, "Drawings: "+drawings+".")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// describeSubject names what the chart shows from its symbol and title meta
+// entries, falling back to "Chart"
+func describeSubject(chart *Chart) string {
+	var title, symbol string
+	for _, entry := range chart.Meta {
+		value, ok := entry.Value.(string)
+		if !ok {
+			continue
+		}
+		switch entry.Key {
+		case "title":
+			title = value
+		case "symbol":
+			symbol = value
+		}
+	}
+
+	switch {
+	case title == "" && symbol == "":
+		return "Chart"
+	case title == "":
+		return symbol + " chart"
+	case symbol == "" || strings.Contains(title, symbol):
+		return title
+	}
+	return symbol + " " + title
+}
+
+// describeInterval names a bar interval, like "daily" or "5-minute", or
+// returns "" when it is not a whole number of common units
+func describeInterval(interval time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case interval <= 0:
+		return ""
+	case interval == 7*day:
+		return "weekly"
+	case interval == day:
+		return "daily"
+	case interval == time.Hour:
+		return "hourly"
+	case interval%day == 0:
+		return fmt.Sprintf("%d-day", interval/day)
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%d-hour", interval/time.Hour)
+	case interval%time.Minute == 0:
+		return fmt.Sprintf("%d-minute", interval/time.Minute)
+	case interval%time.Second == 0:
+		return fmt.Sprintf("%d-second", interval/time.Second)
+	}
+	return ""
+}
+
+// onMidnights reports whether every bar falls on a midnight, as daily bars do
+func onMidnights(bars []Bar) bool {
+	for _, bar := range bars {
+		if !bar.DateTime.Equal(bar.DateTime.Truncate(24 * time.Hour)) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeTime spells out a time for reading aloud, as the date alone for
+// daily bars
+func describeTime(t time.Time, dateOnly bool) string {
+	if dateOnly || t.Equal(t.Truncate(24*time.Hour)) {
+		return t.Format("January 2, 2006")
+	}
+	if t.Second() != 0 || t.Nanosecond() != 0 {
+		return t.Format("January 2, 2006 15:04:05 UTC")
+	}
+	return t.Format("January 2, 2006 15:04 UTC")
+}
+
+// describeIndicator names an indicator with its calculation parameters, like
+// "EMA(20)" or "MACD(12,26,9)"
+func describeIndicator(indicator Indicator) string {
+	name := indicator.Name
+	if described, ok := describedNames[name]; ok {
+		name = described
+	} else if len(name) <= 4 {
+		name = strings.ToUpper(name)
+	}
+
+	switch indicator.Name {
+	case "rsi":
+		return fmt.Sprintf("%s(%d)", name, RSIParams(indicator.Parameters))
+	case "macd":
+		fast, slow, signal := MACDParams(indicator.Parameters)
+		return fmt.Sprintf("%s(%d,%d,%d)", name, fast, slow, signal)
+	}
+
+	var values []string
+	for _, key := range []string{"period", "fast", "slow", "signal", "stddev", "width", "multiplier", "benchmark"} {
+		if value, ok := indicator.Parameters[key]; ok {
+			values = append(values, fmt.Sprint(value))
+		}
+	}
+	if len(values) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(values, ","))
+}
+
+// describeDrawings counts the drawings by type, including those in groups,
+// in order of first appearance, and quotes the first few notes
+func describeDrawings(drawings []Drawing) string {
+	var types []string
+	counts := map[string]int{}
+	var notes []string
+	var visit func(drawings []Drawing)
+	visit = func(drawings []Drawing) {
+		for _, drawing := range drawings {
+			if group, ok := drawing.(Group); ok {
+				visit(group.Drawings)
+				continue
+			}
+			kind, ok := describedNames[drawing.GetType()]
+			if !ok {
+				kind = strings.ReplaceAll(drawing.GetType(), "-", " ")
+			}
+			if counts[kind] == 0 {
+				types = append(types, kind)
+			}
+			counts[kind]++
+			if note, ok := drawing.(Note); ok && note.Text != "" && len(notes) < maxDescribedNotes {
+				notes = append(notes, fmt.Sprintf("%q", note.Text))
+			}
+		}
+	}
+	visit(drawings)
+	if len(types) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(types))
+	for i, kind := range types {
+		parts[i] = fmt.Sprintf("%d %s", counts[kind], kind)
+		switch {
+		case counts[kind] == 1:
+		case strings.HasSuffix(kind, "x") || strings.HasSuffix(kind, "s"):
+			parts[i] += "es"
+		default:
+			parts[i] += "s"
+		}
+	}
+	summary := joinList(parts)
+	if len(notes) > 0 {
+		summary += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return summary
+}
+
+// joinList joins items as an English list: "a", "a and b", "a, b and c"
+func joinList(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,132 @@
+package cml
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// DuplicateBarPolicies are the values of the duplicate-bars setting
+var DuplicateBarPolicies = []string{"merge", "keep-first", "keep-last", "error"}
+
+// maxListedDuplicates caps how many timestamps a duplicate bars warning lists
+const maxListedDuplicates = 10
+
+// normalizeBars applies the chart's duplicate-bars policy to bars sharing a
+// timestamp, such as auction prints and corrections, recording a warning that
+// lists the affected timestamps. The "error" policy fails instead.
+func normalizeBars(chart *Chart) error {
+	policy := chart.GetDuplicateBars()
+	bars, duplicates := dedupeBars(chart.Bars, policy)
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	listed := make([]string, 0, maxListedDuplicates)
+	for _, t := range duplicates[:min(len(duplicates), maxListedDuplicates)] {
+		listed = append(listed, formatDateTime(t, chart.GetDateTimeFormat()))
+	}
+	if more := len(duplicates) - len(listed); more > 0 {
+		listed = append(listed, fmt.Sprintf("and %d more", more))
+	}
+
+	if policy == "error" {
+		return fmt.Errorf("duplicate bars at %d timestamps: %s", len(duplicates), strings.Join(listed, ", "))
+	}
+	chart.Bars = bars
+	chart.Warnings = append(chart.Warnings, fmt.Sprintf("duplicate bars at %d timestamps (%s): %s", len(duplicates), policy, strings.Join(listed, ", ")))
+	return nil
+}
+
+// dedupeBars collapses bars sharing a timestamp into one, where the first of
+// them was, by the given policy. It returns the remaining bars and the
+// timestamps that had duplicates, in order.
+func dedupeBars(bars []Bar, policy string) ([]Bar, []time.Time) {
+	// Bars in strictly increasing time, the usual case, can't have duplicates
+	increasing := true
+	for i := 1; i < len(bars) && increasing; i++ {
+		increasing = bars[i].DateTime.After(bars[i-1].DateTime)
+	}
+	if increasing {
+		return bars, nil
+	}
+
+	seen := make(map[int64]int, len(bars)) // Timestamp -> index in kept
+	duplicated := map[int64]bool{}
+	kept := make([]Bar, 0, len(bars))
+	var duplicates []time.Time
+	for _, bar := range bars {
+		key := bar.DateTime.UnixNano()
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(kept)
+			kept = append(kept, bar)
+			continue
+		}
+
+		if !duplicated[key] {
+			duplicated[key] = true
+			duplicates = append(duplicates, bar.DateTime)
+		}
+		switch policy {
+		case "keep-last":
+			kept[i] = bar
+		case "merge":
+			kept[i] = mergeBars(kept[i], bar)
+		}
+	}
+	if len(duplicates) == 0 {
+		return bars, nil
+	}
+	return kept, duplicates
+}
+
+// mergeBars combines two bars at the same time by OHLC rules: the earlier
+// bar's open, the later bar's close, the wider range, and summed volumes.
+// The later bar's quote and color win where it has them.
+func mergeBars(earlier, later Bar) Bar {
+	merged := earlier
+	merged.High = math.Max(earlier.High, later.High)
+	merged.Low = math.Min(earlier.Low, later.Low)
+	merged.Close = later.Close
+	merged.Volume += later.Volume
+
+	// Order flow adds up, level by level
+	merged.BidVolume += later.BidVolume
+	merged.AskVolume += later.AskVolume
+	merged.Delta += later.Delta
+	merged.HasDelta = earlier.HasDelta || later.HasDelta
+	merged.Levels = mergeLevels(earlier.Levels, later.Levels)
+
+	if later.HasQuote() {
+		merged.BidPrice, merged.AskPrice = later.BidPrice, later.AskPrice
+	}
+	if later.Color != "" || later.Tag != "" {
+		merged.Color, merged.Tag = later.Color, later.Tag
+	}
+	return merged
+}
+
+// mergeLevels sums the volume at each price of two bars' levels
+func mergeLevels(a, b []PriceLevel) []PriceLevel {
+	if len(b) == 0 {
+		return a
+	}
+	merged := append([]PriceLevel(nil), a...)
+	for _, level := range b {
+		found := false
+		for i := range merged {
+			if merged[i].Price == level.Price {
+				merged[i].BidVolume += level.BidVolume
+				merged[i].AskVolume += level.AskVolume
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, level)
+		}
+	}
+	return merged
+}
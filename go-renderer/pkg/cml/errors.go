@@ -0,0 +1,96 @@
+package cml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is a parse failure at a known place in a CML document. Callers
+// can find it with errors.As to point users at the offending line.
+type ParseError struct {
+	Line    int    // 1-based line number, or 0 for problems not on one line
+	Col     int    // 1-based character column of the offending text, or of the line's first character
+	Msg     string // What went wrong
+	Snippet string // The offending line, without its trailing newline
+}
+
+// Error formats the error as "line L, column C: message"
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// ParseErrors is every problem ParseAll found, in the order found
+type ParseErrors []*ParseError
+
+// Error lists the errors one per line
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the errors for errors.Is and errors.As
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// newParseError locates err at the given 1-based line of lines. The column
+// is where the first quoted value in the message, like the "abc" of a
+// strconv error, appears in the line, or else the line's first character.
+// Quotes within the line itself, which many messages end with, don't count.
+func newParseError(lines []string, line int, err error) *ParseError {
+	e := &ParseError{Line: line, Col: 1, Msg: err.Error()}
+	if line < 1 || line > len(lines) {
+		return e
+	}
+	e.Snippet = strings.TrimRight(lines[line-1], "\r")
+	trimmed := strings.TrimLeft(e.Snippet, " \t")
+	e.Col = len(e.Snippet) - len(trimmed) + 1
+
+	reason := e.Msg
+	if echoed := strings.Index(reason, strings.TrimSpace(trimmed)); echoed >= 0 {
+		reason = reason[:echoed]
+	}
+	if quote := strings.IndexByte(reason, '"'); quote >= 0 {
+		if quoted, err := strconv.QuotedPrefix(reason[quote:]); err == nil {
+			if value, err := strconv.Unquote(quoted); err == nil && strings.TrimSpace(value) != "" {
+				// Prefer the value as quoted in the line, so "g" isn't found in "group"
+				at := strings.Index(e.Snippet, quoted)
+				if at < 0 {
+					at = strings.Index(e.Snippet, value)
+				}
+				if at >= 0 {
+					e.Col = utf8.RuneCountInString(e.Snippet[:at]) + 1
+				}
+			}
+		}
+	}
+	return e
+}
+
+// Pointer returns the snippet with a caret under the error's column, for
+// showing below the error message
+func (e *ParseError) Pointer() string {
+	if e.Snippet == "" {
+		return ""
+	}
+	// Tabs stay tabs so the caret lines up however they are displayed
+	indent := []rune(e.Snippet)[:min(e.Col-1, len([]rune(e.Snippet)))]
+	for i, r := range indent {
+		if r != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return e.Snippet + "\n" + string(indent) + "^"
+}
@@ -0,0 +1,280 @@
+package cml
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// chartJSON is the JSON form of a chart. Sections are named as in CML, meta
+// and settings keep their order as key/value lists, and each drawing carries
+// its type.
+type chartJSON struct {
+	Meta             []entryJSON       `json:"meta,omitempty"`
+	Settings         []entryJSON       `json:"settings,omitempty"`
+	Bars             []Bar             `json:"bars,omitempty"`
+	Drawings         []json.RawMessage `json:"drawings,omitempty"`
+	Indicators       []Indicator       `json:"indicators,omitempty"`
+	Series           []Series          `json:"series,omitempty"`
+	Positions        []Position        `json:"positions,omitempty"`
+	Ticks            []Tick            `json:"ticks,omitempty"`
+	CorporateActions []CorporateAction `json:"corporate_actions,omitempty"`
+	Contracts        []Contract        `json:"contracts,omitempty"`
+	Fan              *Fan              `json:"fan,omitempty"`
+}
+
+// entryJSON is a meta or settings entry
+type entryJSON struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON writes the chart as JSON, for web frontends and other
+// languages that would rather not parse CML. Settings with structured values,
+// like bar-color, are written as their CML text. As with Marshal, bars loaded
+// from a bars-file are left to the file; bars stitched from contracts or built
+// from ticks are written along with their sources.
+func (c *Chart) MarshalJSON() ([]byte, error) {
+	doc := chartJSON{
+		Bars:             c.Bars,
+		Indicators:       c.Indicators,
+		Series:           c.Series,
+		Positions:        c.Positions,
+		Ticks:            c.Ticks,
+		CorporateActions: c.CorporateActions,
+		Contracts:        c.Contracts,
+	}
+	if c.GetBarsFile() != "" {
+		doc.Bars = c.Bars[min(c.fileBars, len(c.Bars)):]
+	}
+	if len(c.Fan.Percentiles) > 0 {
+		doc.Fan = &c.Fan
+	}
+
+	for _, entry := range c.Meta {
+		doc.Meta = append(doc.Meta, entryJSON{Key: entry.Key, Value: entry.Value})
+	}
+	for _, entry := range c.Settings {
+		if tags, ok := entry.Value.(BarTagsConfig); ok && len(tags.Colors) == 0 {
+			continue
+		}
+		value, err := settingJSON(entry)
+		if err != nil {
+			return nil, err
+		}
+		doc.Settings = append(doc.Settings, entryJSON{Key: entry.Key, Value: value})
+	}
+	for _, drawing := range c.Drawings {
+		data, err := marshalDrawing(drawing)
+		if err != nil {
+			return nil, err
+		}
+		doc.Drawings = append(doc.Drawings, data)
+	}
+	return json.Marshal(doc)
+}
+
+// settingJSON returns a settings value as JSON: strings, booleans, numbers,
+// and lists as themselves, and structured values as their CML text
+func settingJSON(entry SettingsEntry) (interface{}, error) {
+	switch entry.Value.(type) {
+	case string, bool, int, float64, []string:
+		return entry.Value, nil
+	}
+	text, err := formatSetting(entry)
+	if err != nil {
+		return nil, err
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number, nil
+	}
+	return text, nil
+}
+
+// marshalDrawing writes a drawing as a JSON object with its type, and for
+// groups their members
+func marshalDrawing(drawing Drawing) (json.RawMessage, error) {
+	data, err := json.Marshal(drawing)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["type"], _ = json.Marshal(drawing.GetType())
+
+	if group, ok := drawing.(Group); ok {
+		members := make([]json.RawMessage, len(group.Drawings))
+		for i, member := range group.Drawings {
+			if members[i], err = marshalDrawing(member); err != nil {
+				return nil, err
+			}
+		}
+		if fields["drawings"], err = json.Marshal(members); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads a chart written by MarshalJSON. Settings are checked
+// as they are in CML, but bars are taken as given: contracts and ticks are
+// not stitched or aggregated again, and a bars-file or theme file is not read.
+func (c *Chart) UnmarshalJSON(data []byte) error {
+	var doc chartJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	chart := Chart{
+		Bars:             doc.Bars,
+		Indicators:       doc.Indicators,
+		Series:           doc.Series,
+		Positions:        doc.Positions,
+		Ticks:            doc.Ticks,
+		CorporateActions: doc.CorporateActions,
+		Contracts:        doc.Contracts,
+	}
+	if doc.Fan != nil {
+		chart.Fan = *doc.Fan
+	}
+
+	for _, entry := range doc.Meta {
+		value, err := metaValue(entry)
+		if err != nil {
+			return err
+		}
+		chart.Meta = append(chart.Meta, MetaEntry{Key: entry.Key, Value: value})
+	}
+
+	parser := NewCMLParser()
+	for _, entry := range doc.Settings {
+		text, err := settingText(entry)
+		if err != nil {
+			return err
+		}
+		setting, err := parser.parseSettingsEntry(entry.Key + ": " + text)
+		if err != nil {
+			return fmt.Errorf("invalid setting %s: %v", entry.Key, err)
+		}
+		chart.Settings = append(chart.Settings, setting)
+	}
+
+	for _, raw := range doc.Drawings {
+		drawing, err := unmarshalDrawing(raw)
+		if err != nil {
+			return err
+		}
+		chart.Drawings = append(chart.Drawings, drawing)
+	}
+
+	*c = chart
+	return nil
+}
+
+// metaValue converts a JSON meta value back to its Go type
+func metaValue(entry entryJSON) (interface{}, error) {
+	switch v := entry.Value.(type) {
+	case string, float64:
+		return v, nil
+	case map[string]interface{}:
+		// Objects are grid configurations, so decode that one again as such
+		data, _ := json.Marshal(v)
+		var grid GridConfig
+		if err := json.Unmarshal(data, &grid); err != nil {
+			return nil, fmt.Errorf("invalid meta %s: %v", entry.Key, err)
+		}
+		return grid, nil
+	}
+	return nil, fmt.Errorf("invalid meta %s: unsupported value %v", entry.Key, entry.Value)
+}
+
+// settingText converts a JSON settings value to the CML text it stands for
+func settingText(entry entryJSON) (string, error) {
+	switch v := entry.Value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return formatNumber(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("invalid setting %s: list items must be strings", entry.Key)
+			}
+			items[i] = `"` + text + `"`
+		}
+		return strings.Join(items, ", "), nil
+	}
+	return "", fmt.Errorf("invalid setting %s: unsupported value %v", entry.Key, entry.Value)
+}
+
+// unmarshalDrawing reads a drawing by its type
+func unmarshalDrawing(data json.RawMessage) (Drawing, error) {
+	var header struct {
+		Type     string            `json:"type"`
+		Drawings []json.RawMessage `json:"drawings"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Type {
+	case "rectangle":
+		return decodeDrawing[Rectangle](data)
+	case "line":
+		return decodeDrawing[Line](data)
+	case "continuous-line":
+		return decodeDrawing[ContinuousLine](data)
+	case "triangle":
+		return decodeDrawing[Triangle](data)
+	case "circle":
+		return decodeDrawing[Circle](data)
+	case "note":
+		return decodeDrawing[Note](data)
+	case "rr-box":
+		return decodeDrawing[RRBox](data)
+	case "cone":
+		return decodeDrawing[Cone](data)
+	case "highlight-bar":
+		return decodeDrawing[HighlightBar](data)
+	case "curve":
+		return decodeDrawing[Curve](data)
+	case "ray":
+		return decodeDrawing[Ray](data)
+	case "trendline":
+		return decodeDrawing[Trendline](data)
+	case "path":
+		return decodeDrawing[Path](data)
+	case "trail":
+		return decodeDrawing[Trail](data)
+	case "group":
+		var group Group
+		if err := json.Unmarshal(data, &group); err != nil {
+			return nil, fmt.Errorf("invalid group: %v", err)
+		}
+		for _, raw := range header.Drawings {
+			member, err := unmarshalDrawing(raw)
+			if err != nil {
+				return nil, err
+			}
+			group.Drawings = append(group.Drawings, member)
+		}
+		return group, nil
+	}
+	return nil, fmt.Errorf("unknown drawing type %q", header.Type)
+}
+
+// decodeDrawing reads a drawing of a known type
+func decodeDrawing[T Drawing](data json.RawMessage) (Drawing, error) {
+	var drawing T
+	if err := json.Unmarshal(data, &drawing); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", drawing.GetType(), err)
+	}
+	return drawing, nil
+}
@@ -0,0 +1,686 @@
+package cml
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Marshal writes a chart as CML that parses back to the same chart, so
+// programs can build charts in code and tools can rewrite or normalize CML
+// files. Sections are written in the grammar's order, datetimes in the
+// chart's datetime-format, and styles and parameters sorted by key.
+//
+// Bars that Parse loads from a bars-file, stitches from contracts, or builds
+// from ticks are left to those sources rather than written as bars. Bar
+// annotation flags and drawings added by annotators are written as the
+// drawings they became. It fails on values CML can't express, like text
+// spanning lines or unknown drawing types.
+func Marshal(chart *Chart) (string, error) {
+	w := &chartWriter{layout: chart.GetDateTimeFormat()}
+	steps := []func(*Chart) error{
+		w.writeMeta,
+		w.writeSettings,
+		w.writeBars,
+		w.writeDrawings,
+		w.writeIndicators,
+		w.writeSeries,
+		w.writePositions,
+		w.writeTicks,
+		w.writeCorporateActions,
+		w.writeContracts,
+		w.writeFan,
+	}
+	for _, step := range steps {
+		if err := step(chart); err != nil {
+			return "", err
+		}
+	}
+	return w.b.String(), nil
+}
+
+// marshalIndent is the indentation of each level of a CML section
+const marshalIndent = "    "
+
+// chartWriter builds CML text section by section
+type chartWriter struct {
+	b      strings.Builder
+	layout string // The chart's datetime-format layout
+}
+
+// section starts a section, separated from the previous one by a blank line
+func (w *chartWriter) section(name string) {
+	if w.b.Len() > 0 {
+		w.b.WriteByte('\n')
+	}
+	w.b.WriteString(name + ":\n")
+}
+
+// line writes an entry indented to the given depth
+func (w *chartWriter) line(depth int, text string) {
+	w.b.WriteString(strings.Repeat(marshalIndent, depth))
+	w.b.WriteString(text)
+	w.b.WriteByte('\n')
+}
+
+// time formats a datetime in the chart's layout
+func (w *chartWriter) time(t time.Time) string {
+	return formatDateTime(t, w.layout)
+}
+
+// point formats a "datetime, price" pair
+func (w *chartWriter) point(t time.Time, price float64) string {
+	return w.time(t) + ", " + formatNumber(price)
+}
+
+func (w *chartWriter) writeMeta(chart *Chart) error {
+	if len(chart.Meta) == 0 {
+		return nil
+	}
+	w.section("meta")
+	for _, entry := range chart.Meta {
+		var value string
+		switch v := entry.Value.(type) {
+		case string:
+			if err := checkText("meta "+entry.Key, v); err != nil {
+				return err
+			}
+			value = `"` + v + `"`
+		case float64:
+			value = formatNumber(v)
+		case int:
+			value = strconv.Itoa(v)
+		case time.Time:
+			value = w.time(v)
+		case GridConfig:
+			value = "grid" + formatGridConfig(v)
+		default:
+			return fmt.Errorf("cannot marshal meta %s value %v of type %T", entry.Key, entry.Value, entry.Value)
+		}
+		w.line(1, entry.Key+": "+value)
+	}
+	return nil
+}
+
+func (w *chartWriter) writeSettings(chart *Chart) error {
+	if len(chart.Settings) == 0 {
+		return nil
+	}
+	w.section("settings")
+	for _, entry := range chart.Settings {
+		// Without tags, a bar-tags setting does nothing
+		if tags, ok := entry.Value.(BarTagsConfig); ok && len(tags.Colors) == 0 {
+			continue
+		}
+		value, err := formatSetting(entry)
+		if err != nil {
+			return err
+		}
+		w.line(1, entry.Key+": "+value)
+	}
+	return nil
+}
+
+// formatSetting writes a settings entry's value as CML
+func formatSetting(entry SettingsEntry) (string, error) {
+	switch v := entry.Value.(type) {
+	case string:
+		if err := checkText("setting "+entry.Key, v); err != nil {
+			return "", err
+		}
+		if entry.Key == "datetime-format" || entry.Key == "bars-file" {
+			return `"` + v + `"`, nil
+		}
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return formatNumber(v), nil
+	case time.Duration:
+		return v.String(), nil
+	case []string:
+		labels := make([]string, len(v))
+		for i, label := range v {
+			labels[i] = `"` + label + `"`
+		}
+		return strings.Join(labels, ", "), nil
+	case GridConfig:
+		return formatGridConfig(v), nil
+	case YAxisConfig:
+		return strconv.Itoa(v.Precision), nil
+	case BarOpacityConfig:
+		return formatNumber(v.Opacity), nil
+	case CandleBorderConfig:
+		switch {
+		case v.Auto:
+			return "auto", nil
+		case v.Width == 0:
+			return "off", nil
+		}
+		return formatNumber(v.Width), nil
+	case RenkoBrickConfig:
+		if v.Size > 0 {
+			return formatNumber(v.Size), nil
+		}
+		if v.ATRPeriod == 0 {
+			v.ATRPeriod = DefaultRenkoATRPeriod
+		}
+		return fmt.Sprintf("atr(%d)", v.ATRPeriod), nil
+	case Theme:
+		if _, ok := Themes[v.Name]; ok {
+			return v.Name, nil
+		}
+		if v.Name == "" {
+			return "", fmt.Errorf("cannot marshal a theme without a name or file path")
+		}
+		if err := checkText("theme", v.Name); err != nil {
+			return "", err
+		}
+		return `"` + v.Name + `"`, nil
+	case CanvasSize:
+		return fmt.Sprintf("%dx%d", v.Width, v.Height), nil
+	case BarTagsConfig:
+		return formatBarTags(v), nil
+	case BarColorConfig:
+		if v.Rule == "ma" {
+			return fmt.Sprintf("ma(%s=%d)", v.Average, v.Period), nil
+		}
+		return fmt.Sprintf("%s(ema=%d, macd=%d,%d,%d)", v.Rule, v.EMA, v.Fast, v.Slow, v.Signal), nil
+	case ConvertConfig:
+		value := "rate=" + formatNumber(v.Rate)
+		if v.Series != "" {
+			value = "series=" + v.Series
+		}
+		if v.Unit != "" {
+			value += " unit=" + v.Unit
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("cannot marshal setting %s value %v of type %T", entry.Key, entry.Value, entry.Value)
+}
+
+// formatGridConfig writes a grid configuration in its inline form
+func formatGridConfig(config GridConfig) string {
+	return fmt.Sprintf("(enabled=%t, line-width=%s, color=%s, opacity=%s)",
+		config.Enabled, formatNumber(config.LineWidth), config.Color, formatNumber(config.Opacity))
+}
+
+// formatBarTags writes bar tags in their inline form, in declaration order
+// and then any tags missing from the order by name
+func formatBarTags(config BarTagsConfig) string {
+	tags := append([]string(nil), config.Order...)
+	listed := map[string]bool{}
+	for _, tag := range tags {
+		listed[tag] = true
+	}
+	for _, tag := range sortedKeys(config.Colors) {
+		if !listed[tag] {
+			tags = append(tags, tag)
+		}
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if color, ok := config.Colors[tag]; ok {
+			pairs = append(pairs, tag+"="+color)
+		}
+	}
+	return "(" + strings.Join(pairs, ", ") + ")"
+}
+
+func (w *chartWriter) writeBars(chart *Chart) error {
+	bars := sourceBars(chart)
+	if len(bars) == 0 {
+		return nil
+	}
+	w.section("bars")
+	for _, bar := range bars {
+		w.line(1, w.bar(bar))
+	}
+	return nil
+}
+
+// bar formats a bar row: datetime, OHLC, and whichever optional columns the
+// bar has
+func (w *chartWriter) bar(bar Bar) string {
+	columns := []string{w.time(bar.DateTime), formatNumber(bar.Open), formatNumber(bar.High), formatNumber(bar.Low), formatNumber(bar.Close)}
+	if bar.Volume != 0 {
+		columns = append(columns, formatNumber(bar.Volume))
+	}
+	if bar.Color != "" {
+		columns = append(columns, bar.Color)
+	}
+	if bar.Tag != "" {
+		columns = append(columns, bar.Tag)
+	}
+
+	// Bid and ask volumes imply their delta, so delta is only written when it
+	// differs or stands alone
+	if bar.HasDelta {
+		if bar.BidVolume != 0 {
+			columns = append(columns, "bid="+formatNumber(bar.BidVolume))
+		}
+		if bar.AskVolume != 0 {
+			columns = append(columns, "ask="+formatNumber(bar.AskVolume))
+		}
+		if bar.Delta != bar.AskVolume-bar.BidVolume || bar.BidVolume == 0 && bar.AskVolume == 0 {
+			columns = append(columns, "delta="+formatNumber(bar.Delta))
+		}
+	}
+	if len(bar.Levels) > 0 {
+		levels := make([]string, len(bar.Levels))
+		for i, level := range bar.Levels {
+			levels[i] = formatNumber(level.Price) + ":" + formatNumber(level.BidVolume) + "x" + formatNumber(level.AskVolume)
+		}
+		columns = append(columns, "levels="+strings.Join(levels, "|"))
+	}
+	if bar.BidPrice != 0 {
+		columns = append(columns, "bid-price="+formatNumber(bar.BidPrice))
+	}
+	if bar.AskPrice != 0 {
+		columns = append(columns, "ask-price="+formatNumber(bar.AskPrice))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// barKey identifies a bar by its time, prices, and volume
+type barKey struct {
+	unixNano                       int64
+	open, high, low, close, volume float64
+}
+
+// sourceBars returns the chart's bars less those Parse would load again from
+// the chart's bars-file, contracts, or ticks
+func sourceBars(chart *Chart) []Bar {
+	bars := chart.Bars
+	if chart.GetBarsFile() != "" {
+		bars = bars[min(chart.fileBars, len(bars)):]
+	}
+
+	var derived []Bar
+	if len(chart.Contracts) > 0 {
+		// Stitching sets each contract's gap, so it works on a copy
+		contracts := append([]Contract(nil), chart.Contracts...)
+		if stitched, err := stitchContracts(contracts, chart.GetRollAdjust()); err == nil {
+			derived = append(derived, stitched...)
+		}
+	}
+	if len(chart.Ticks) > 0 && chart.GetTicksDisplay() == "bars" {
+		derived = append(derived, aggregateTicks(chart.Ticks, chart.GetTickInterval())...)
+	}
+	if len(derived) == 0 {
+		return bars
+	}
+
+	counts := map[barKey]int{}
+	for _, bar := range derived {
+		counts[barKey{bar.DateTime.UnixNano(), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume}]++
+	}
+	kept := make([]Bar, 0, len(bars))
+	for _, bar := range bars {
+		key := barKey{bar.DateTime.UnixNano(), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume}
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		kept = append(kept, bar)
+	}
+	return kept
+}
+
+func (w *chartWriter) writeDrawings(chart *Chart) error {
+	if len(chart.Drawings) == 0 {
+		return nil
+	}
+	w.section("drawings")
+	for _, drawing := range chart.Drawings {
+		if group, ok := drawing.(Group); ok {
+			if err := w.group(group); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := w.drawing(drawing, 1, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// group writes a drawing group with its shared styles and its members
+func (w *chartWriter) group(group Group) error {
+	if err := checkLabel("group", group.Label); err != nil {
+		return err
+	}
+	header := `group "` + group.Label + `"`
+	if len(group.Styles) > 0 {
+		styles, err := formatStyles(group.Styles)
+		if err != nil {
+			return err
+		}
+		header += " (" + strings.Join(styles, ", ") + ")"
+	}
+	w.line(1, header+": {")
+	for _, drawing := range group.Drawings {
+		if _, ok := drawing.(Group); ok {
+			return fmt.Errorf("cannot marshal group %q: groups cannot be nested", group.Label)
+		}
+		if err := w.drawing(drawing, 2, group.Styles); err != nil {
+			return err
+		}
+	}
+	w.line(1, "}")
+	return nil
+}
+
+// drawing writes a drawing and its styles below it, leaving out styles a
+// group already gives it
+func (w *chartWriter) drawing(drawing Drawing, depth int, defaults map[string]interface{}) error {
+	// Styles hold most options; fields set in code are added as the styles
+	// they are parsed from
+	styles := map[string]interface{}{}
+	for key, value := range DrawingStyles(drawing) {
+		if defaults == nil || defaults[key] != value {
+			styles[key] = value
+		}
+	}
+	setStyle := func(key string, value interface{}) {
+		if _, ok := styles[key]; !ok {
+			styles[key] = value
+		}
+	}
+
+	var text string
+	switch d := drawing.(type) {
+	case Rectangle:
+		text = fmt.Sprintf("rectangle(%s; %s)", w.point(d.StartTime, d.StartPrice), w.point(d.EndTime, d.EndPrice))
+	case Line:
+		text = fmt.Sprintf("line(%s; %s)", w.point(d.StartTime, d.StartPrice), w.point(d.EndTime, d.EndPrice))
+		if d.Arrow == "left-arrow" || d.Arrow == "both-arrows" {
+			setStyle("left-arrow", "true")
+		}
+		if d.Arrow == "right-arrow" || d.Arrow == "both-arrows" {
+			setStyle("right-arrow", "true")
+		}
+		if d.LineStyle != "" {
+			setStyle("style", d.LineStyle)
+		}
+	case ContinuousLine:
+		text = fmt.Sprintf("continuous-line(%s; %s)", w.point(d.StartTime, d.StartPrice), w.point(d.EndTime, d.EndPrice))
+		if d.LineStyle != "" {
+			setStyle("style", d.LineStyle)
+		}
+	case Curve:
+		text = fmt.Sprintf("curve(%s; %s", w.point(d.StartTime, d.StartPrice), w.point(d.EndTime, d.EndPrice))
+		if d.Curvature != 0.3 {
+			text += "; curvature=" + formatNumber(d.Curvature)
+		}
+		text += ")"
+	case Ray:
+		text = fmt.Sprintf("ray(%s, slope=%s/bar)", w.point(d.DateTime, d.Price), formatNumber(d.Slope))
+		if d.LineStyle != "" {
+			setStyle("style", d.LineStyle)
+		}
+	case Cone:
+		text = fmt.Sprintf("cone(%s; %s, %s)", w.point(d.DateTime, d.Price), formatNumber(d.SlopeUp), formatNumber(d.SlopeDown))
+	case Trendline:
+		fit := d.Fit
+		if fit == "" {
+			fit = "close"
+		}
+		text = fmt.Sprintf("trendline(%s..%s, fit=%s)", w.time(d.StartTime), w.time(d.EndTime), fit)
+		if d.Extend != "" {
+			setStyle("extend", d.Extend)
+		}
+	case Path:
+		text = "path(" + w.points(d.Points) + ")"
+		if d.Closed {
+			setStyle("closed", "true")
+		}
+	case Trail:
+		text = "trail(" + w.points(d.Points) + ")"
+		if d.Extend {
+			setStyle("extend", "true")
+		}
+	case Triangle:
+		text = fmt.Sprintf("%s-triangle(%s)", d.Direction, w.time(d.DateTime))
+	case Circle:
+		text = fmt.Sprintf("%scircle(%s)", d.Position, w.time(d.DateTime))
+	case Note:
+		if err := checkText("note", d.Text); err != nil {
+			return err
+		}
+		text = fmt.Sprintf(`%snote(%s, "%s")`, d.Position, w.time(d.DateTime), d.Text)
+	case HighlightBar:
+		text = fmt.Sprintf("highlight-bar(%s)", w.time(d.DateTime))
+	case RRBox:
+		text = fmt.Sprintf("rr-box(%s, %s, %s, %s, %s)", w.time(d.StartTime), formatNumber(d.Entry), formatNumber(d.Stop), formatNumber(d.Target), w.time(d.EndTime))
+	default:
+		return fmt.Errorf("cannot marshal drawing of type %s", drawing.GetType())
+	}
+
+	// A marker's label goes after it, unless a label style already gives it
+	var label string
+	switch d := drawing.(type) {
+	case Triangle:
+		label = d.Label
+	case Circle:
+		label = d.Label
+	}
+	if style, _ := styles["label"].(string); label != "" && strings.Trim(style, `"`) != label {
+		if err := checkLabel("marker", label); err != nil {
+			return err
+		}
+		text += ` label="` + label + `"`
+	}
+
+	lines, err := formatStyles(styles)
+	if err != nil {
+		return err
+	}
+	w.line(depth, text)
+	for _, line := range lines {
+		w.line(depth+1, line)
+	}
+	return nil
+}
+
+// points formats path and trail points separated by semicolons
+func (w *chartWriter) points(points []PathPoint) string {
+	formatted := make([]string, len(points))
+	for i, point := range points {
+		formatted[i] = w.point(point.Time, point.Price)
+	}
+	return strings.Join(formatted, "; ")
+}
+
+func (w *chartWriter) writeIndicators(chart *Chart) error {
+	if len(chart.Indicators) == 0 {
+		return nil
+	}
+	w.section("indicators")
+	for _, indicator := range chart.Indicators {
+		params, err := formatStyles(indicator.Parameters)
+		if err != nil {
+			return fmt.Errorf("cannot marshal indicator %s: %v", indicator.Name, err)
+		}
+		w.line(1, indicator.Name+"("+strings.Join(params, ", ")+")")
+	}
+	return nil
+}
+
+func (w *chartWriter) writeSeries(chart *Chart) error {
+	if len(chart.Series) == 0 {
+		return nil
+	}
+	w.section("series")
+	for _, series := range chart.Series {
+		params, err := formatStyles(series.Parameters)
+		if err != nil {
+			return fmt.Errorf("cannot marshal series %s: %v", series.Name, err)
+		}
+		w.line(1, series.Name+"("+strings.Join(params, ", ")+")")
+		for _, point := range series.Points {
+			row := w.point(point.DateTime, point.Value)
+			if point.HasBounds {
+				row += ", " + formatNumber(point.Lower) + ", " + formatNumber(point.Upper)
+			}
+			w.line(2, row)
+		}
+	}
+	return nil
+}
+
+func (w *chartWriter) writePositions(chart *Chart) error {
+	if len(chart.Positions) == 0 {
+		return nil
+	}
+	w.section("positions")
+	for _, position := range chart.Positions {
+		fields := []string{w.time(position.DateTime)}
+		if position.Size != 0 {
+			fields = append(fields, "size="+formatNumber(position.Size))
+		}
+		fields = append(fields, "entry="+formatNumber(position.Entry))
+		if position.Stop != 0 {
+			fields = append(fields, "stop="+formatNumber(position.Stop))
+		}
+		if position.Target != 0 {
+			fields = append(fields, "target="+formatNumber(position.Target))
+		}
+		if position.Order {
+			fields = append(fields, "order=true")
+		}
+		w.line(1, position.Side+"("+strings.Join(fields, ", ")+")")
+	}
+	return nil
+}
+
+func (w *chartWriter) writeTicks(chart *Chart) error {
+	if len(chart.Ticks) == 0 {
+		return nil
+	}
+	w.section("ticks")
+	for _, tick := range chart.Ticks {
+		w.line(1, w.point(tick.DateTime, tick.Price)+", "+formatNumber(tick.Size))
+	}
+	return nil
+}
+
+func (w *chartWriter) writeCorporateActions(chart *Chart) error {
+	if len(chart.CorporateActions) == 0 {
+		return nil
+	}
+	w.section("corporate-actions")
+	for _, action := range chart.CorporateActions {
+		switch action.Type {
+		case "split":
+			w.line(1, fmt.Sprintf("split(%s, ratio=%s:1)", w.time(action.DateTime), formatNumber(action.Ratio)))
+		case "dividend":
+			w.line(1, fmt.Sprintf("dividend(%s, amount=%s)", w.time(action.DateTime), formatNumber(action.Amount)))
+		default:
+			return fmt.Errorf("cannot marshal corporate action of type %q", action.Type)
+		}
+	}
+	return nil
+}
+
+func (w *chartWriter) writeContracts(chart *Chart) error {
+	if len(chart.Contracts) == 0 {
+		return nil
+	}
+	w.section("contracts")
+	for _, contract := range chart.Contracts {
+		header := contract.Name + "()"
+		if !contract.Roll.IsZero() {
+			header = contract.Name + "(roll=" + w.time(contract.Roll) + ")"
+		}
+		w.line(1, header)
+		for _, bar := range contract.Bars {
+			w.line(2, w.bar(bar))
+		}
+	}
+	return nil
+}
+
+func (w *chartWriter) writeFan(chart *Chart) error {
+	if len(chart.Fan.Percentiles) == 0 {
+		return nil
+	}
+	w.section("fan")
+	header := make([]string, len(chart.Fan.Percentiles))
+	for i, percentile := range chart.Fan.Percentiles {
+		header[i] = "P" + formatNumber(percentile)
+	}
+	w.line(1, strings.Join(header, ", "))
+	for _, point := range chart.Fan.Points {
+		row := []string{w.time(point.DateTime)}
+		for _, value := range point.Values {
+			row = append(row, formatNumber(value))
+		}
+		w.line(1, strings.Join(row, ", "))
+	}
+	return nil
+}
+
+// formatStyles formats drawing styles or indicator parameters as key=value
+// pairs sorted by key
+func formatStyles(styles map[string]interface{}) ([]string, error) {
+	pairs := make([]string, 0, len(styles))
+	for _, key := range sortedKeys(styles) {
+		var value string
+		switch v := styles[key].(type) {
+		case string:
+			if err := checkText(key, v); err != nil {
+				return nil, err
+			}
+			value = v
+		case float64:
+			value = formatNumber(v)
+		case int:
+			value = strconv.Itoa(v)
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("cannot marshal %s value %v of type %T", key, v, v)
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return pairs, nil
+}
+
+// formatNumber writes a number in the shortest form that parses back to it
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// checkText rejects text that would spill onto another line
+func checkText(what, text string) error {
+	if strings.ContainsAny(text, "\r\n") {
+		return fmt.Errorf("cannot marshal %s %q: text cannot span lines", what, text)
+	}
+	return nil
+}
+
+// checkLabel rejects group and marker labels the parser couldn't read back,
+// which end at the first quote
+func checkLabel(what, label string) error {
+	if strings.Contains(label, `"`) {
+		return fmt.Errorf("cannot marshal %s label %q: labels cannot contain quotes", what, label)
+	}
+	return checkText(what+" label", label)
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
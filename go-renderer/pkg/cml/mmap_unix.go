@@ -0,0 +1,34 @@
+//go:build unix
+
+package cml
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile memory-maps a file read-only; release unmaps it
+func mapFile(path string) ([]byte, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Empty files can't be mapped
+	if info.Size() == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,80 @@
+package cml
+
+// IsPriceSource reports whether a source names a bar price rather than a series
+func IsPriceSource(source string) bool {
+	return source == "open" || source == "high" || source == "low" || source == "close"
+}
+
+// ChandelierParams returns a chandelier indicator's period, ATR multiplier,
+// and side ("long", "short", or "both"), defaulting to a 22-bar, 3 ATR long exit
+func ChandelierParams(params map[string]interface{}) (period int, multiplier float64, side string) {
+	period, multiplier, side = 22, 3, "long"
+	if p, ok := params["period"].(float64); ok && p >= 1 {
+		period = int(p)
+	}
+	if m, ok := params["multiplier"].(float64); ok {
+		multiplier = m
+	}
+	if s, ok := params["side"].(string); ok {
+		side = s
+	}
+	return period, multiplier, side
+}
+
+// IndicatorSource returns the source param of a seasonal or bands indicator, defaulting to close
+func IndicatorSource(params map[string]interface{}) string {
+	if source, ok := params["source"].(string); ok {
+		return source
+	}
+	return "close"
+}
+
+// BandsParams returns a bands indicator's period, width, and type, defaulting
+// to 20 periods of 2 standard deviations
+func BandsParams(params map[string]interface{}) (period int, width float64, bandType string) {
+	period, width, bandType = 20, 2, "stddev"
+	if p, ok := params["period"].(float64); ok && p >= 1 {
+		period = int(p)
+	}
+	if w, ok := params["width"].(float64); ok {
+		width = w
+	}
+	if t, ok := params["type"].(string); ok {
+		bandType = t
+	}
+	return period, width, bandType
+}
+
+// RSIParams returns an rsi indicator's period, defaulting to 14
+func RSIParams(params map[string]interface{}) (period int) {
+	period = 14
+	if p, ok := params["period"].(float64); ok && p >= 1 {
+		period = int(p)
+	}
+	return period
+}
+
+// MACDParams returns a macd indicator's fast, slow, and signal periods,
+// defaulting to the classic 12, 26, 9
+func MACDParams(params map[string]interface{}) (fast, slow, signal int) {
+	fast, slow, signal = 12, 26, 9
+	if p, ok := params["fast"].(float64); ok && p >= 1 {
+		fast = int(p)
+	}
+	if p, ok := params["slow"].(float64); ok && p >= 1 {
+		slow = int(p)
+	}
+	if p, ok := params["signal"].(float64); ok && p >= 1 {
+		signal = int(p)
+	}
+	return fast, slow, signal
+}
+
+// PaneHeight returns the pane-height weight of an indicator drawn in its own
+// subpanel, defaulting to 1
+func PaneHeight(params map[string]interface{}) float64 {
+	if h, ok := params["pane-height"].(float64); ok && h > 0 {
+		return h
+	}
+	return 1
+}
@@ -0,0 +1,3683 @@
+// Package cml parses Chart Markup Language documents into charts: bars, drawings,
+// indicators, series, and settings.
+package cml
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chart represents a complete CML chart
+type Chart struct {
+	Meta       []MetaEntry
+	Settings   []SettingsEntry
+	Bars       []Bar
+	Drawings   []Drawing
+	Indicators []Indicator
+	Series     []Series
+	Positions  []Position
+	Ticks      []Tick
+
+	CorporateActions []CorporateAction
+	Contracts        []Contract
+
+	// Fan holds percentile paths projected past the last bar, if any
+	Fan Fan
+
+	// Warnings lists lines skipped by a lenient parse
+	Warnings []string
+
+	// fileBars counts the leading bars loaded from the bars-file, which
+	// Marshal leaves out
+	fileBars int
+
+	// barDrawings holds the drawings expanded from bar annotation flags, which
+	// are also in Drawings, so ReplaceSection can keep them
+	barDrawings []Drawing
+}
+
+// GetBarType returns the bar type from settings, defaulting to "candlestick"
+func (c *Chart) GetBarType() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "bar-type" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "candlestick"
+}
+
+// GetAutoColor returns whether unkeyed elements get colors from the chart's
+// color cycler, defaulting to false
+func (c *Chart) GetAutoColor() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "auto-color" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// GetAdjustment returns which corporate actions back-adjust prices: "none"
+// (default), "splits", or "all" for splits and dividends
+func (c *Chart) GetAdjustment() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "adjust" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "none"
+}
+
+// GetRenkoBrickSize returns the renko brick size configuration, defaulting to
+// the 14-bar average true range
+func (c *Chart) GetRenkoBrickSize() RenkoBrickConfig {
+	for _, entry := range c.Settings {
+		if entry.Key == "renko-brick-size" {
+			if config, ok := entry.Value.(RenkoBrickConfig); ok {
+				return config
+			}
+		}
+	}
+	return RenkoBrickConfig{ATRPeriod: DefaultRenkoATRPeriod}
+}
+
+// GetCandleBorder returns the candle outline configuration, defaulting to a 1px outline
+func (c *Chart) GetCandleBorder() CandleBorderConfig {
+	for _, entry := range c.Settings {
+		if entry.Key == "candle-border" {
+			if config, ok := entry.Value.(CandleBorderConfig); ok {
+				return config
+			}
+		}
+	}
+	return CandleBorderConfig{Width: 1}
+}
+
+// GetWickColor returns the color of candle wicks, defaulting to the theme's
+func (c *Chart) GetWickColor() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "wick-color" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return c.GetTheme().Wick
+}
+
+// GetFanColor returns the fill color of the fan section's bands, defaulting
+// to steel blue
+func (c *Chart) GetFanColor() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "fan-color" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "#4682b4"
+}
+
+// GetYAxisPosition returns which side price labels are drawn on: "left"
+// (default), "right", or "both"
+func (c *Chart) GetYAxisPosition() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "y-axis-position" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "left"
+}
+
+// GetFrame returns how the plot area is framed: "full" (default), "axes" for
+// only the left and bottom axis lines, or "none"
+func (c *Chart) GetFrame() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "frame" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "full"
+}
+
+// GetFrameColor returns the color of the plot area frame, defaulting to the
+// theme's axis color
+func (c *Chart) GetFrameColor() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "frame-color" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return c.GetTheme().Axis
+}
+
+// GetFrameWidth returns the width of the plot area frame, or 0 for the default
+func (c *Chart) GetFrameWidth() float64 {
+	for _, entry := range c.Settings {
+		if entry.Key == "frame-width" {
+			if width, ok := entry.Value.(float64); ok {
+				return width
+			}
+		}
+	}
+	return 0
+}
+
+// GetPrintMode returns whether the chart renders for black and white printing
+func (c *Chart) GetPrintMode() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "print-mode" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// GetCrispLines returns whether grid lines, the frame, and bars snap to whole
+// pixels, defaulting to false
+func (c *Chart) GetCrispLines() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "crisp-lines" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// GetRollAdjust returns how contracts are back-adjusted at each roll:
+// "difference" (default), "ratio", or "none"
+func (c *Chart) GetRollAdjust() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "roll-adjust" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return "difference"
+}
+
+// GetRollMarkers returns whether contract rolls are marked on the chart
+func (c *Chart) GetRollMarkers() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "roll-markers" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// GetActionMarkers returns whether corporate actions are marked on the chart
+func (c *Chart) GetActionMarkers() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "action-markers" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return false
+}
+
+// GetGridConfig returns the grid configuration from meta, with defaults
+func (c *Chart) GetGridConfig() GridConfig {
+	defaultConfig := GridConfig{
+		Enabled:   true,
+		LineWidth: 0.5,
+		Color:     c.GetTheme().Grid,
+		Opacity:   1.0,
+	}
+
+	for _, entry := range c.Settings {
+		if entry.Key == "grid" {
+			if config, ok := entry.Value.(GridConfig); ok {
+				// Apply defaults for missing values
+				if config.LineWidth == 0 {
+					config.LineWidth = defaultConfig.LineWidth
+				}
+				if config.Color == "" {
+					config.Color = defaultConfig.Color
+				}
+				if config.Opacity == 0 {
+					config.Opacity = defaultConfig.Opacity
+				}
+				return config
+			}
+		}
+	}
+	return defaultConfig
+}
+
+// GetYAxisConfig returns the Y-axis configuration from settings, with defaults
+func (c *Chart) GetYAxisConfig() YAxisConfig {
+	defaultConfig := YAxisConfig{
+		Precision: 2, // Default 2 decimal places
+	}
+
+	for _, entry := range c.Settings {
+		if entry.Key == "y-axis-precision" {
+			if config, ok := entry.Value.(YAxisConfig); ok {
+				// Apply defaults for missing values
+				if config.Precision == 0 {
+					config.Precision = defaultConfig.Precision
+				}
+				return config
+			}
+		}
+	}
+	return defaultConfig
+}
+
+// GetBarOpacityConfig returns the bar opacity configuration
+func (c *Chart) GetBarOpacityConfig() BarOpacityConfig {
+	defaultConfig := BarOpacityConfig{
+		Opacity: 1.0, // Default full opacity
+	}
+
+	for _, entry := range c.Settings {
+		if entry.Key == "bar-opacity" {
+			if config, ok := entry.Value.(BarOpacityConfig); ok {
+				// Apply defaults for missing values
+				if config.Opacity == 0 {
+					config.Opacity = defaultConfig.Opacity
+				}
+				return config
+			}
+		}
+	}
+	return defaultConfig
+}
+
+// GetTicksDisplay returns how ticks are shown: "bars" to aggregate them into
+// bars or "strip" for a scatter strip below the chart. Parsing fills in the
+// default, "bars" for charts without bars and "strip" otherwise.
+func (c *Chart) GetTicksDisplay() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "ticks-display" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// GetTickInterval returns the bar length ticks are aggregated into, defaulting to one minute
+func (c *Chart) GetTickInterval() time.Duration {
+	for _, entry := range c.Settings {
+		if entry.Key == "tick-interval" {
+			if interval, ok := entry.Value.(time.Duration); ok {
+				return interval
+			}
+		}
+	}
+	return time.Minute
+}
+
+// GetVolumePane returns whether bars with volume get a volume subpanel, defaulting to true
+func (c *Chart) GetVolumePane() bool {
+	for _, entry := range c.Settings {
+		if entry.Key == "volume-pane" {
+			if enabled, ok := entry.Value.(bool); ok {
+				return enabled
+			}
+		}
+	}
+	return true
+}
+
+// GetPricePaneHeight returns the price pane's height relative to the
+// subpanels' pane-height weights, or 0 for the default split
+func (c *Chart) GetPricePaneHeight() float64 {
+	for _, entry := range c.Settings {
+		if entry.Key == "price-pane-height" {
+			if weight, ok := entry.Value.(float64); ok {
+				return weight
+			}
+		}
+	}
+	return 0
+}
+
+// GetDuplicateBars returns the policy for bars sharing a timestamp, defaulting to "merge"
+func (c *Chart) GetDuplicateBars() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "duplicate-bars" {
+			if policy, ok := entry.Value.(string); ok {
+				return policy
+			}
+		}
+	}
+	return "merge"
+}
+
+// GetDateTimeFormat returns the Go time layout bar and drawing datetimes are written in, or "" for the CML format
+func (c *Chart) GetDateTimeFormat() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "datetime-format" {
+			if layout, ok := entry.Value.(string); ok {
+				return layout
+			}
+		}
+	}
+	return ""
+}
+
+// GetBarsFile returns the path of the external binary bar file, if any
+func (c *Chart) GetBarsFile() string {
+	for _, entry := range c.Settings {
+		if entry.Key == "bars-file" {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// GetPNGOptions returns the PNG encoding options from settings, with defaults
+func (c *Chart) GetPNGOptions() PNGOptions {
+	options := PNGOptions{Compression: "default"}
+
+	for _, entry := range c.Settings {
+		switch entry.Key {
+		case "png-compression":
+			if level, ok := entry.Value.(string); ok {
+				options.Compression = level
+			}
+		case "png-colors":
+			if colors, ok := entry.Value.(int); ok {
+				options.Colors = colors
+			}
+		case "png-interlace":
+			if enabled, ok := entry.Value.(bool); ok {
+				options.Interlace = enabled
+			}
+		}
+	}
+	return options
+}
+
+// GetFontSizes returns the font sizes from settings; unset sizes are zero
+func (c *Chart) GetFontSizes() FontSizes {
+	var sizes FontSizes
+	for _, entry := range c.Settings {
+		size, ok := entry.Value.(float64)
+		if !ok {
+			continue
+		}
+		switch entry.Key {
+		case "axis-font-size":
+			sizes.Axis = size
+		case "title-font-size":
+			sizes.Title = size
+		case "note-font-size":
+			sizes.Note = size
+		}
+	}
+	return sizes
+}
+
+// GetCanvasSize returns the canvas size from the size setting, if any
+func (c *Chart) GetCanvasSize() (CanvasSize, bool) {
+	for _, entry := range c.Settings {
+		if entry.Key == "size" {
+			if size, ok := entry.Value.(CanvasSize); ok {
+				return size, true
+			}
+		}
+	}
+	return CanvasSize{}, false
+}
+
+// GetHiddenGroups returns the labels of drawing groups hidden by hide-group settings
+func (c *Chart) GetHiddenGroups() map[string]bool {
+	hidden := map[string]bool{}
+	for _, entry := range c.Settings {
+		if entry.Key == "hide-group" {
+			if labels, ok := entry.Value.([]string); ok {
+				for _, label := range labels {
+					hidden[label] = true
+				}
+			}
+		}
+	}
+	return hidden
+}
+
+// GetSeries returns the series with the given name
+func (c *Chart) GetSeries(name string) (Series, bool) {
+	for _, series := range c.Series {
+		if series.Name == name {
+			return series, true
+		}
+	}
+	return Series{}, false
+}
+
+// GetDataSeries returns the names of series used only as data: benchmarks of
+// relative-strength indicators and the convert setting's rate series. They
+// are not drawn themselves.
+func (c *Chart) GetDataSeries() map[string]bool {
+	names := map[string]bool{}
+	for _, indicator := range c.Indicators {
+		if indicator.Name == "relative-strength" {
+			if name, ok := indicator.Parameters["benchmark"].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+	if convert, ok := c.GetConvertConfig(); ok && convert.Series != "" {
+		names[convert.Series] = true
+	}
+	return names
+}
+
+// GetBarColorConfig returns the bar coloring rule, if the chart sets one
+func (c *Chart) GetBarColorConfig() (BarColorConfig, bool) {
+	for _, entry := range c.Settings {
+		if entry.Key == "bar-color" {
+			if config, ok := entry.Value.(BarColorConfig); ok {
+				return config, true
+			}
+		}
+	}
+	return BarColorConfig{}, false
+}
+
+// GetConvertConfig returns the price conversion from settings, if any
+func (c *Chart) GetConvertConfig() (ConvertConfig, bool) {
+	for _, entry := range c.Settings {
+		if entry.Key == "convert" {
+			if config, ok := entry.Value.(ConvertConfig); ok {
+				return config, true
+			}
+		}
+	}
+	return ConvertConfig{}, false
+}
+
+// GetBarTagsConfig returns the bar tag to color mapping from settings
+func (c *Chart) GetBarTagsConfig() BarTagsConfig {
+	for _, entry := range c.Settings {
+		if entry.Key == "bar-tags" {
+			if config, ok := entry.Value.(BarTagsConfig); ok {
+				return config
+			}
+		}
+	}
+	return BarTagsConfig{Colors: map[string]string{}}
+}
+
+// MetaEntry represents a metadata entry
+type MetaEntry struct {
+	Key   string
+	Value interface{}
+}
+
+type SettingsEntry struct {
+	Key   string
+	Value interface{}
+}
+
+// GridConfig represents grid configuration
+type GridConfig struct {
+	Enabled   bool    `json:"enabled"`
+	LineWidth float64 `json:"line_width"`
+	Color     string  `json:"color"`
+	Opacity   float64 `json:"opacity"`
+}
+
+// YAxisConfig represents Y-axis configuration
+type YAxisConfig struct {
+	Precision int
+}
+
+// BarOpacityConfig represents bar opacity configuration
+type BarOpacityConfig struct {
+	Opacity float64
+}
+
+// CandleBorderConfig controls the outline drawn around candle bodies
+type CandleBorderConfig struct {
+	Width float64 // Outline width in pixels; 0 draws no outline
+	Auto  bool    // Drop the outline when bodies are too narrow for it
+}
+
+// DefaultRenkoATRPeriod is the average true range period of ATR-based renko
+// bricks when none is given
+const DefaultRenkoATRPeriod = 14
+
+// RenkoBrickConfig sizes renko bricks: a fixed price distance, or the latest
+// average true range over ATRPeriod bars when Size is 0
+type RenkoBrickConfig struct {
+	Size      float64
+	ATRPeriod int
+}
+
+// BarTagsConfig maps per-bar tags to candle colors
+type BarTagsConfig struct {
+	Colors map[string]string
+	Order  []string // Tags in declaration order, for the legend
+}
+
+// PNGOptions controls how the rendered image is encoded
+type PNGOptions struct {
+	Compression string // "default", "none", "fast", or "best"
+	Colors      int    // Palette size for 8-bit quantization (2-256), 0 for truecolor
+	Interlace   bool   // Write an Adam7-interlaced image
+}
+
+// FontSizes holds text sizes in pixels; zero scales the default with the canvas
+type FontSizes struct {
+	Axis  float64 // Axis labels and the bar tag legend
+	Title float64 // Chart title
+	Note  float64 // Notes without a font-size style
+}
+
+// CanvasSize is the size of the rendered chart in pixels
+type CanvasSize struct {
+	Width  int
+	Height int
+}
+
+// MaxCanvasSize is the largest width or height the size setting accepts, so
+// an untrusted file can't ask for an enormous canvas
+const MaxCanvasSize = 16384
+
+// Bar represents OHLC price data
+type Bar struct {
+	DateTime time.Time `json:"time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume,omitempty"` // Optional traded volume (zero when absent)
+	Color    string    `json:"color,omitempty"`  // Optional per-bar color override
+	Tag      string    `json:"tag,omitempty"`    // Optional per-bar tag, colored via bar-tags
+
+	// Optional order flow for footprint bars
+	BidVolume float64      `json:"bid_volume,omitempty"` // Volume traded at the bid
+	AskVolume float64      `json:"ask_volume,omitempty"` // Volume traded at the ask
+	Delta     float64      `json:"delta,omitempty"`      // Ask minus bid volume, given directly or derived from them
+	HasDelta  bool         `json:"has_delta,omitempty"`  // Whether the bar carries bid/ask or delta volume
+	Levels    []PriceLevel `json:"levels,omitempty"`     // Bid/ask volume at each traded price
+
+	// Optional best bid and ask quotes, drawn as a spread band (zero when absent)
+	BidPrice float64 `json:"bid_price,omitempty"`
+	AskPrice float64 `json:"ask_price,omitempty"`
+}
+
+// HasQuote reports whether the bar carries both a best bid and a best ask
+func (b Bar) HasQuote() bool {
+	return b.BidPrice != 0 && b.AskPrice != 0
+}
+
+// HasVolume reports whether any of the chart's bars carries volume
+func (c *Chart) HasVolume() bool {
+	for _, bar := range c.Bars {
+		if bar.Volume > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Interval returns the chart's bar interval, inferred from its bars (see BarInterval)
+func (c *Chart) Interval() time.Duration {
+	return BarInterval(c.Bars)
+}
+
+// BarInterval infers the interval of bars in time order from the gaps between
+// consecutive bars: the most common gap, or the median when no gap repeats,
+// as with irregular tick data. Weekends, session breaks, and missing bars
+// don't throw it off the way the first gap alone would. It is 0 for fewer
+// than two bars.
+func BarInterval(bars []Bar) time.Duration {
+	gaps := make([]time.Duration, 0, len(bars))
+	for i := 1; i < len(bars); i++ {
+		if gap := bars[i].DateTime.Sub(bars[i-1].DateTime); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+
+	// The longest run of equal gaps, preferring the shorter gap on ties
+	mode, modeCount := gaps[len(gaps)/2], 1
+	for start := 0; start < len(gaps); {
+		end := start
+		for end < len(gaps) && gaps[end] == gaps[start] {
+			end++
+		}
+		if end-start > modeCount {
+			mode, modeCount = gaps[start], end-start
+		}
+		start = end
+	}
+	return mode
+}
+
+// PriceLevel holds the volume traded at the bid and ask at one price of a bar
+type PriceLevel struct {
+	Price     float64 `json:"price"`
+	BidVolume float64 `json:"bid_volume"`
+	AskVolume float64 `json:"ask_volume"`
+}
+
+// Drawing represents any drawing element
+type Drawing interface {
+	GetType() string
+}
+
+// Rectangle represents a rectangle drawing
+type Rectangle struct {
+	StartTime  time.Time              `json:"start_time"`
+	StartPrice float64                `json:"start_price"`
+	EndTime    time.Time              `json:"end_time"`
+	EndPrice   float64                `json:"end_price"`
+	Styles     map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (r Rectangle) GetType() string { return "rectangle" }
+
+// Line represents a line drawing
+type Line struct {
+	StartTime  time.Time              `json:"start_time"`
+	StartPrice float64                `json:"start_price"`
+	EndTime    time.Time              `json:"end_time"`
+	EndPrice   float64                `json:"end_price"`
+	Arrow      string                 `json:"arrow,omitempty"`
+	LineStyle  string                 `json:"line_style,omitempty"`
+	Styles     map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (l Line) GetType() string { return "line" }
+
+// ContinuousLine represents a continuous line drawing
+type ContinuousLine struct {
+	StartTime  time.Time              `json:"start_time"`
+	StartPrice float64                `json:"start_price"`
+	EndTime    time.Time              `json:"end_time"`
+	EndPrice   float64                `json:"end_price"`
+	LineStyle  string                 `json:"line_style,omitempty"`
+	Styles     map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (cl ContinuousLine) GetType() string { return "continuous-line" }
+
+// Triangle represents a triangle marker
+type Triangle struct {
+	DateTime  time.Time              `json:"time"`
+	Direction string                 `json:"direction"`       // "uptick" or "downtick"
+	Label     string                 `json:"label,omitempty"` // Optional text drawn beside the marker
+	Styles    map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (t Triangle) GetType() string { return "triangle" }
+
+// Circle represents a circle marker
+type Circle struct {
+	DateTime time.Time              `json:"time"`
+	Position string                 `json:"position"`        // "under" or "over"
+	Label    string                 `json:"label,omitempty"` // Optional text drawn beside the marker
+	Styles   map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (c Circle) GetType() string { return "circle" }
+
+// Note represents a text note
+type Note struct {
+	DateTime time.Time              `json:"time"`
+	Text     string                 `json:"text"`
+	Position string                 `json:"position"` // "under" or "over"
+	Styles   map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (n Note) GetType() string { return "note" }
+
+// RRBox represents a risk/reward tool: a stop-loss zone and a take-profit
+// zone on either side of an entry, between two times
+type RRBox struct {
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
+	Entry     float64                `json:"entry"`
+	Stop      float64                `json:"stop"`
+	Target    float64                `json:"target"`
+	Styles    map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (b RRBox) GetType() string { return "rr-box" }
+
+// Cone represents a forward projection cone: two rays diverging from an anchor
+type Cone struct {
+	DateTime  time.Time              `json:"time"`
+	Price     float64                `json:"price"`
+	SlopeUp   float64                `json:"slope_up"`   // Upper edge, in price per bar
+	SlopeDown float64                `json:"slope_down"` // Lower edge, in price per bar
+	Styles    map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (c Cone) GetType() string { return "cone" }
+
+// HighlightBar shades the full vertical slot of a single bar, behind the bars
+type HighlightBar struct {
+	DateTime time.Time              `json:"time"`
+	Styles   map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (h HighlightBar) GetType() string { return "highlight-bar" }
+
+// Curve represents a quadratic bezier connector between two points
+type Curve struct {
+	StartTime  time.Time              `json:"start_time"`
+	StartPrice float64                `json:"start_price"`
+	EndTime    time.Time              `json:"end_time"`
+	EndPrice   float64                `json:"end_price"`
+	Curvature  float64                `json:"curvature"` // Control point offset as a fraction of the chord length
+	Styles     map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (c Curve) GetType() string { return "curve" }
+
+// Ray represents a trendline defined by an anchor point and a slope, projected
+// to the right edge of the chart
+type Ray struct {
+	DateTime  time.Time              `json:"time"`
+	Price     float64                `json:"price"`
+	Slope     float64                `json:"slope"` // Price change per bar
+	LineStyle string                 `json:"line_style,omitempty"`
+	Styles    map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (r Ray) GetType() string { return "ray" }
+
+// Trendline represents a least-squares line fitted to bar prices over a time range
+type Trendline struct {
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
+	Fit       string                 `json:"fit"`              // "high", "low", or "close"
+	Extend    string                 `json:"extend,omitempty"` // "", "right", "left", or "both"
+	Styles    map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (t Trendline) GetType() string { return "trendline" }
+
+// PathPoint represents a single vertex of a path drawing
+type PathPoint struct {
+	Time  time.Time `json:"time"`
+	Price float64   `json:"price"`
+}
+
+// Path represents a freeform multi-point polyline
+type Path struct {
+	Points []PathPoint            `json:"points"`
+	Closed bool                   `json:"closed,omitempty"`
+	Styles map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (p Path) GetType() string { return "path" }
+
+// Trail represents a trailing stop: a step line holding each stop price until
+// the next point
+type Trail struct {
+	Points []PathPoint            `json:"points"`
+	Extend bool                   `json:"extend,omitempty"` // Hold the last stop to the right edge of the chart
+	Styles map[string]interface{} `json:"styles,omitempty"`
+}
+
+func (t Trail) GetType() string { return "trail" }
+
+// Group is a labeled set of drawings that share default styles and can be
+// hidden together with the hide-group setting
+type Group struct {
+	Label    string                 `json:"label"`
+	Drawings []Drawing              `json:"-"`
+	Styles   map[string]interface{} `json:"styles,omitempty"` // Defaults for member drawings
+}
+
+func (g Group) GetType() string { return "group" }
+
+// DrawingStyles returns the style map of a drawing, or nil for groups and
+// unknown drawing types
+func DrawingStyles(drawing Drawing) map[string]interface{} {
+	switch d := drawing.(type) {
+	case Rectangle:
+		return d.Styles
+	case Line:
+		return d.Styles
+	case ContinuousLine:
+		return d.Styles
+	case Triangle:
+		return d.Styles
+	case Circle:
+		return d.Styles
+	case Note:
+		return d.Styles
+	case Curve:
+		return d.Styles
+	case Ray:
+		return d.Styles
+	case Trendline:
+		return d.Styles
+	case Path:
+		return d.Styles
+	case Trail:
+		return d.Styles
+	case HighlightBar:
+		return d.Styles
+	case RRBox:
+		return d.Styles
+	case Cone:
+		return d.Styles
+	}
+	return nil
+}
+
+// Indicator represents a technical indicator
+type Indicator struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Series represents a user-supplied data series drawn alongside the bars
+type Series struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Points     []SeriesPoint          `json:"points"`
+}
+
+// SeriesPoint represents a single value of a series
+type SeriesPoint struct {
+	DateTime time.Time `json:"time"`
+	Value    float64   `json:"value"`
+
+	// Optional bounds around the value, like a prediction's confidence
+	// interval, drawn by the error-bars and band render modes
+	Lower     float64 `json:"lower,omitempty"`
+	Upper     float64 `json:"upper,omitempty"`
+	HasBounds bool    `json:"has_bounds,omitempty"`
+}
+
+// Fan is a set of percentile paths over future times, like the P10/P50/P90
+// outcomes of a Monte Carlo model
+type Fan struct {
+	Percentiles []float64  `json:"percentiles"` // Ascending, e.g. 10, 50, 90
+	Points      []FanPoint `json:"points"`
+}
+
+// FanPoint holds each percentile's value at one time, in the order of the
+// fan's percentiles
+type FanPoint struct {
+	DateTime time.Time `json:"time"`
+	Values   []float64 `json:"values"`
+}
+
+// End returns the time of the fan's last point
+func (f Fan) End() (time.Time, bool) {
+	if len(f.Points) == 0 {
+		return time.Time{}, false
+	}
+	return f.Points[len(f.Points)-1].DateTime, true
+}
+
+// ConvertConfig converts every price into another currency or unit at render
+// time, either by a fixed rate or by a series of rates over time
+type ConvertConfig struct {
+	Rate   float64 // Fixed multiplier, when Series is empty
+	Series string  // Name of a series holding the rate at each time
+	Unit   string  // Unit label shown on the price axis, e.g. "EUR"
+}
+
+// BarColorConfig recolors bars by an indicator rule: "impulse" for Elder's
+// impulse system, or "ma" for closes above or below a moving average
+type BarColorConfig struct {
+	Rule string
+
+	// Impulse: the EMA period and MACD fast, slow, and signal periods
+	EMA                int
+	Fast, Slow, Signal int
+
+	// MA: the moving average type ("ema" or "sma") and period
+	Average string
+	Period  int
+}
+
+// CorporateAction is a stock split or a cash dividend
+type CorporateAction struct {
+	Type     string    `json:"type"` // "split" or "dividend"
+	DateTime time.Time `json:"time"`
+	Ratio    float64   `json:"ratio,omitempty"`  // New shares per old share, for splits (4 for 4:1)
+	Amount   float64   `json:"amount,omitempty"` // Cash per share, for dividends
+}
+
+// Contract is one futures contract's bars, used until its roll date
+type Contract struct {
+	Name string    `json:"name"`
+	Roll time.Time `json:"roll"` // First time of the next contract; zero for the last contract
+	Bars []Bar     `json:"bars"`
+
+	Gap float64 `json:"gap,omitempty"` // Adjustment applied at the roll, set when contracts are stitched
+}
+
+// Tick represents a single trade from a time-and-sales feed
+type Tick struct {
+	DateTime time.Time `json:"time"`
+	Price    float64   `json:"price"`
+	Size     float64   `json:"size"`
+}
+
+// Position represents an open position or a working order
+type Position struct {
+	Side     string    `json:"side"`             // "long" or "short"
+	DateTime time.Time `json:"time"`             // When the position was opened or the order placed
+	Size     float64   `json:"size,omitempty"`   // Quantity, 0 when not given
+	Entry    float64   `json:"entry"`            // Entry (or order) price
+	Stop     float64   `json:"stop,omitempty"`   // Stop-loss price, 0 when not set
+	Target   float64   `json:"target,omitempty"` // Take-profit price, 0 when not set
+	Order    bool      `json:"order,omitempty"`  // A working order that hasn't filled yet
+}
+
+// ParseLimits bounds the size of input the parser accepts; zero disables a limit
+type ParseLimits struct {
+	MaxBars       int
+	MaxDrawings   int
+	MaxLineLength int
+	MaxFileSize   int64 // Bytes of (decompressed) CML content
+}
+
+// DefaultParseLimits are generous limits that still reject pathological input
+var DefaultParseLimits = ParseLimits{
+	MaxBars:       5000000,
+	MaxDrawings:   100000,
+	MaxLineLength: 64 * 1024,
+	MaxFileSize:   512 * 1024 * 1024,
+}
+
+// CMLParser handles parsing of CML content
+type CMLParser struct {
+	datetimeRegex *regexp.Regexp
+	colorRegex    *regexp.Regexp
+
+	// BaseDir resolves relative file references such as bars-file
+	BaseDir string
+
+	// Lenient skips malformed bar and drawing lines (recording them in
+	// Chart.Warnings) instead of failing the whole parse
+	Lenient bool
+
+	// Limits are enforced during parsing
+	Limits ParseLimits
+
+	// TemplateData, when set, runs the content through text/template with
+	// this data before parsing, so one CML template can produce many charts
+	TemplateData interface{}
+
+	// DateTimeParser, when set, replaces auto-detection of datetimes that
+	// don't match the chart's datetime-format setting
+	DateTimeParser func(string) (time.Time, error)
+
+	// dateTimeFormat is the datetime-format layout of the chart being parsed
+	dateTimeFormat string
+
+	// headerLines counts synthetic lines parseLines is given ahead of the
+	// caller's content, which don't count toward line numbers
+	headerLines int
+
+	// collectErrors records errors in collected and carries on, for ParseAll
+	collectErrors bool
+	collected     ParseErrors
+}
+
+// NewCMLParser creates a new CML parser
+func NewCMLParser() *CMLParser {
+	return &CMLParser{
+		datetimeRegex: regexp.MustCompile(`(\d{4})/(\d{2})/(\d{2})\s+(\d{2}):(\d{2})(?::(\d{2})(?:\.(\d{1,9}))?)?`),
+		colorRegex:    regexp.MustCompile(`#([0-9a-fA-F]{8}|[0-9a-fA-F]{6}|[0-9a-fA-F]{3,4})`),
+		Limits:        DefaultParseLimits,
+	}
+}
+
+// ParseReader reads CML content from r and parses it, transparently
+// decompressing gzip or zstd input
+func (p *CMLParser) ParseReader(r io.Reader) (*Chart, error) {
+	content, err := p.readContent(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(content)
+}
+
+// readContent reads and decompresses CML content from r within the size limit
+func (p *CMLParser) readContent(r io.Reader) (string, error) {
+	decompressed, err := decompressReader(r)
+	if err != nil {
+		return "", fmt.Errorf("error decompressing input: %v", err)
+	}
+
+	// Read at most one byte past the limit so oversized (or decompression
+	// bomb) input is rejected without buffering all of it
+	if p.Limits.MaxFileSize > 0 {
+		decompressed = io.LimitReader(decompressed, p.Limits.MaxFileSize+1)
+	}
+
+	content, err := io.ReadAll(decompressed)
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	return string(content), nil
+}
+
+// ParseAllReader reads CML content from r like ParseReader and parses it
+// with ParseAll
+func (p *CMLParser) ParseAllReader(r io.Reader) (*Chart, error) {
+	content, err := p.readContent(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseAll(content)
+}
+
+// Parse parses CML content and returns a Chart
+func (p *CMLParser) Parse(content string) (*Chart, error) {
+	return p.parse(content, false)
+}
+
+// ParseAll parses CML content like Parse, but skips each bad entry and
+// carries on, so every problem can be reported at once. It returns the chart
+// built from the valid entries and, if anything was skipped, a ParseErrors
+// listing why. Problems that end parsing, like input over the size or count
+// limits, return a nil chart as with Parse.
+func (p *CMLParser) ParseAll(content string) (*Chart, error) {
+	return p.parse(content, true)
+}
+
+// parse parses CML content, collecting errors instead of failing on the
+// first when collectErrors is set
+func (p *CMLParser) parse(content string, collectErrors bool) (*Chart, error) {
+	if p.Limits.MaxFileSize > 0 && int64(len(content)) > p.Limits.MaxFileSize {
+		return nil, fmt.Errorf("input exceeds maximum size of %d bytes", p.Limits.MaxFileSize)
+	}
+
+	if p.TemplateData != nil {
+		expanded, err := expandTemplate(content, p.TemplateData)
+		if err != nil {
+			return nil, fmt.Errorf("error expanding template: %v", err)
+		}
+		if p.Limits.MaxFileSize > 0 && int64(len(expanded)) > p.Limits.MaxFileSize {
+			return nil, fmt.Errorf("expanded template exceeds maximum size of %d bytes", p.Limits.MaxFileSize)
+		}
+		content = expanded
+	}
+
+	// Parse with a copy so a chart's datetime-format doesn't leak into later
+	// (or concurrent) parses
+	session := *p
+	session.dateTimeFormat = ""
+	session.collectErrors = collectErrors
+	session.collected = nil
+
+	// Problems found after the line-by-line parse aren't on any one line
+	fail := func(err error) error {
+		if !collectErrors {
+			return err
+		}
+		session.collected = append(session.collected, &ParseError{Msg: err.Error()})
+		return nil
+	}
+
+	chart := newChart()
+	if err := session.parseLines(chart, strings.Split(content, "\n")); err != nil {
+		return nil, err
+	}
+	if collectErrors {
+		session.dropInvalidReferences(chart)
+	} else if err := validateReferences(chart); err != nil {
+		return nil, err
+	}
+
+	// Load bars from an external binary file ahead of any inline bars
+	if barsFile := chart.GetBarsFile(); barsFile != "" {
+		if !filepath.IsAbs(barsFile) && p.BaseDir != "" {
+			barsFile = filepath.Join(p.BaseDir, barsFile)
+		}
+		fileBars, err := ReadBinaryBars(barsFile)
+		if err != nil {
+			if err := fail(fmt.Errorf("error reading bars file %s: %v", barsFile, err)); err != nil {
+				return nil, err
+			}
+		}
+		chart.Bars = append(fileBars, chart.Bars...)
+		chart.fileBars = len(fileBars)
+		if err := p.checkCountLimits(chart); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read a custom theme file in place of its path
+	for i, entry := range chart.Settings {
+		theme, ok := entry.Value.(Theme)
+		if entry.Key != "theme" || !ok || theme.Background != "" {
+			continue
+		}
+		themeFile := theme.Name
+		if !filepath.IsAbs(themeFile) && p.BaseDir != "" {
+			themeFile = filepath.Join(p.BaseDir, themeFile)
+		}
+		loaded, err := LoadTheme(themeFile)
+		if err != nil {
+			if err := fail(fmt.Errorf("error reading theme: %v", err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		loaded.Name = theme.Name
+		chart.Settings[i].Value = loaded
+	}
+
+	// Contracts are stitched into one continuous series after any other bars
+	if len(chart.Contracts) > 0 {
+		stitched, err := stitchContracts(chart.Contracts, chart.GetRollAdjust())
+		if err != nil {
+			if err := fail(fmt.Errorf("error stitching contracts: %v", err)); err != nil {
+				return nil, err
+			}
+		}
+		chart.Bars = append(chart.Bars, stitched...)
+		if err := p.checkCountLimits(chart); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ticks become bars when the chart has none, unless set to draw as a strip
+	if len(chart.Ticks) > 0 {
+		display := chart.GetTicksDisplay()
+		if display == "" {
+			display = "strip"
+			if len(chart.Bars) == 0 {
+				display = "bars"
+			}
+			chart.Settings = append(chart.Settings, SettingsEntry{Key: "ticks-display", Value: display})
+		}
+		if display == "bars" {
+			chart.Bars = append(chart.Bars, aggregateTicks(chart.Ticks, chart.GetTickInterval())...)
+			if err := p.checkCountLimits(chart); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Settle bars that share a timestamp now that every source has been added
+	if err := normalizeBars(chart); err != nil {
+		if err := fail(err); err != nil {
+			return nil, err
+		}
+	}
+
+	// Let registered annotators add their drawings
+	applyAnnotators(chart)
+
+	if len(session.collected) > 0 {
+		return chart, session.collected
+	}
+	return chart, nil
+}
+
+// newChart returns an empty chart with every section initialized
+func newChart() *Chart {
+	return &Chart{
+		Meta:       []MetaEntry{},
+		Settings:   []SettingsEntry{},
+		Bars:       []Bar{},
+		Drawings:   []Drawing{},
+		Indicators: []Indicator{},
+		Series:     []Series{},
+		Positions:  []Position{},
+		Ticks:      []Tick{},
+
+		CorporateActions: []CorporateAction{},
+		Contracts:        []Contract{},
+	}
+}
+
+// parseLines parses CML lines section by section, appending to the chart.
+// Errors are returned as a *ParseError at the line that caused them, or
+// recorded when collecting errors for ParseAll.
+func (p *CMLParser) parseLines(chart *Chart, lines []string) error {
+	var currentSection string
+	var i int
+	var group *Group // Open drawing group, if any
+	var groupLine int
+	var entryLine int // Entries spanning several lines fail at the line they start on
+	var limited bool  // A count limit was hit, which ends even a ParseAll
+	var drawings int  // Drawings so far, counting group members, for MaxDrawings
+
+	lineNumber := func(i int) int { return i + 1 - p.headerLines }
+
+	// fail locates err at a line, returning it to end the parse or, when
+	// collecting errors, recording it and returning nil to carry on
+	fail := func(at int, err error) error {
+		parseErr := newParseError(lines[p.headerLines:], lineNumber(at), err)
+		if p.collectErrors && !limited {
+			p.collected = append(p.collected, parseErr)
+			return nil
+		}
+		return parseErr
+	}
+
+	parseEntry := func(line string) error {
+		switch currentSection {
+		case "meta":
+			meta, err := p.parseMetaEntry(line)
+			if err != nil {
+				return fmt.Errorf("error parsing meta entry: %v", err)
+			}
+			chart.Meta = append(chart.Meta, meta)
+		case "settings":
+			settings, err := p.parseSettingsEntry(line)
+			if err != nil {
+				return fmt.Errorf("error parsing settings entry: %v", err)
+			}
+			chart.Settings = append(chart.Settings, settings)
+
+			// Settings come before bars and drawings, so the layout applies to every datetime after it
+			if settings.Key == "datetime-format" {
+				p.dateTimeFormat = settings.Value.(string)
+			}
+
+			// Check if this is a bar tags mapping with indented properties
+			if settings.Key == "bar-tags" && len(settings.Value.(BarTagsConfig).Order) == 0 {
+				tagsConfig, err := p.parseIndentedBarTags(lines, &i)
+				if err != nil {
+					return fmt.Errorf("error parsing bar tags: %v", err)
+				}
+				chart.Settings[len(chart.Settings)-1].Value = tagsConfig
+			}
+
+			// Check if this is a grid configuration with indented properties
+			if settings.Key == "grid" {
+				gridConfig := settings.Value.(GridConfig)
+				// Check if it's an empty config (new indented format)
+				if !gridConfig.Enabled && gridConfig.LineWidth == 0 && gridConfig.Color == "" && gridConfig.Opacity == 0 {
+					// Parse indented grid properties
+					gridConfig, err := p.parseIndentedGridProperties(lines, &i)
+					if err != nil {
+						return fmt.Errorf("error parsing grid properties: %v", err)
+					}
+					// Update the last settings entry with the parsed grid config
+					chart.Settings[len(chart.Settings)-1].Value = gridConfig
+				}
+			}
+		case "bars":
+			barLine, annotations := splitBarAnnotations(line)
+			bar, err := p.parseBar(barLine)
+			if err != nil {
+				if p.Lenient {
+					chart.Warnings = append(chart.Warnings, fmt.Sprintf("line %d: skipped bar: %v", lineNumber(i), err))
+					break
+				}
+				return fmt.Errorf("error parsing bar: %v", err)
+			}
+
+			// Expand per-bar annotation flags into standard drawings
+			var annotationDrawings []Drawing
+			if annotations != "" {
+				annotationDrawings, err = p.parseBarAnnotations(bar.DateTime, annotations)
+				if err != nil {
+					if p.Lenient {
+						chart.Warnings = append(chart.Warnings, fmt.Sprintf("line %d: skipped bar: %v", lineNumber(i), err))
+						break
+					}
+					return fmt.Errorf("error parsing bar annotation: %v", err)
+				}
+			}
+
+			chart.Bars = append(chart.Bars, bar)
+			chart.Drawings = append(chart.Drawings, annotationDrawings...)
+			chart.barDrawings = append(chart.barDrawings, annotationDrawings...)
+			drawings += len(annotationDrawings)
+			if err := p.checkCounts(len(chart.Bars), drawings); err != nil {
+				limited = true
+				return err
+			}
+		case "drawings":
+			// Group headers and closing braces delimit drawing groups
+			if strings.HasPrefix(line, "group ") {
+				if group != nil {
+					return fmt.Errorf("error parsing drawing group: groups cannot be nested: %s", line)
+				}
+				header, err := p.parseGroupHeader(line)
+				if err != nil {
+					return fmt.Errorf("error parsing drawing group: %v", err)
+				}
+				group = &header
+				groupLine = entryLine
+				break
+			}
+			if line == "}" {
+				if group == nil {
+					return fmt.Errorf("error parsing drawings: unexpected } outside a group")
+				}
+				chart.Drawings = append(chart.Drawings, *group)
+				group = nil
+				break
+			}
+
+			drawing, err := p.parseDrawing(lines, &i)
+			if err != nil {
+				if p.Lenient {
+					chart.Warnings = append(chart.Warnings, fmt.Sprintf("line %d: skipped drawing: %v", lineNumber(entryLine), err))
+					break
+				}
+				return fmt.Errorf("error parsing drawing: %v", err)
+			}
+			if group != nil {
+				// Member styles win over the group's defaults
+				if styles := DrawingStyles(drawing); styles != nil {
+					for key, value := range group.Styles {
+						if _, ok := styles[key]; !ok {
+							styles[key] = value
+						}
+					}
+				}
+				group.Drawings = append(group.Drawings, drawing)
+			} else {
+				chart.Drawings = append(chart.Drawings, drawing)
+			}
+			drawings++
+			if err := p.checkCounts(len(chart.Bars), drawings); err != nil {
+				limited = true
+				return err
+			}
+		case "indicators":
+			indicator, err := p.parseIndicator(line)
+			if err != nil {
+				return fmt.Errorf("error parsing indicator: %v", err)
+			}
+			chart.Indicators = append(chart.Indicators, indicator)
+		case "series":
+			// Data rows start with a datetime, anything else starts a new series
+			if p.startsWithDateTime(line) && strings.Index(line, "(") == -1 {
+				if len(chart.Series) == 0 {
+					return fmt.Errorf("error parsing series: data row before series header: %s", line)
+				}
+				point, err := p.parseSeriesPoint(line)
+				if err != nil {
+					return fmt.Errorf("error parsing series point: %v", err)
+				}
+				last := &chart.Series[len(chart.Series)-1]
+				last.Points = append(last.Points, point)
+			} else {
+				header, err := p.parseIndicator(line)
+				if err != nil {
+					return fmt.Errorf("error parsing series: %v", err)
+				}
+				chart.Series = append(chart.Series, Series{
+					Name:       header.Name,
+					Parameters: header.Parameters,
+					Points:     []SeriesPoint{},
+				})
+			}
+		case "contracts":
+			// Data rows start with a datetime, anything else starts a new contract
+			if p.startsWithDateTime(line) && strings.Index(line, "(") == -1 {
+				if len(chart.Contracts) == 0 {
+					return fmt.Errorf("error parsing contracts: bar before contract header: %s", line)
+				}
+				bar, err := p.parseBar(line)
+				if err != nil {
+					if p.Lenient {
+						chart.Warnings = append(chart.Warnings, fmt.Sprintf("line %d: skipped bar: %v", lineNumber(i), err))
+						break
+					}
+					return fmt.Errorf("error parsing contract bar: %v", err)
+				}
+				last := &chart.Contracts[len(chart.Contracts)-1]
+				last.Bars = append(last.Bars, bar)
+			} else {
+				contract, err := p.parseContractHeader(line)
+				if err != nil {
+					return fmt.Errorf("error parsing contracts: %v", err)
+				}
+				chart.Contracts = append(chart.Contracts, contract)
+			}
+		case "fan":
+			// Data rows start with a datetime; the header names the percentiles
+			if p.startsWithDateTime(line) {
+				if len(chart.Fan.Percentiles) == 0 {
+					return fmt.Errorf("error parsing fan: data row before percentile header: %s", line)
+				}
+				point, err := p.parseFanPoint(line, len(chart.Fan.Percentiles))
+				if err != nil {
+					return fmt.Errorf("error parsing fan point: %v", err)
+				}
+				if n := len(chart.Fan.Points); n > 0 && !point.DateTime.After(chart.Fan.Points[n-1].DateTime) {
+					return fmt.Errorf("error parsing fan point: times must be in ascending order: %s", line)
+				}
+				chart.Fan.Points = append(chart.Fan.Points, point)
+			} else {
+				if len(chart.Fan.Percentiles) > 0 {
+					return fmt.Errorf("error parsing fan: only one percentile header is allowed: %s", line)
+				}
+				percentiles, err := parseFanHeader(line)
+				if err != nil {
+					return fmt.Errorf("error parsing fan: %v", err)
+				}
+				chart.Fan.Percentiles = percentiles
+			}
+		case "corporate-actions":
+			action, err := p.parseCorporateAction(line)
+			if err != nil {
+				return fmt.Errorf("error parsing corporate action: %v", err)
+			}
+			chart.CorporateActions = append(chart.CorporateActions, action)
+		case "ticks":
+			tick, err := p.parseTick(line)
+			if err != nil {
+				if p.Lenient {
+					chart.Warnings = append(chart.Warnings, fmt.Sprintf("line %d: skipped tick: %v", lineNumber(i), err))
+					break
+				}
+				return fmt.Errorf("error parsing tick: %v", err)
+			}
+			chart.Ticks = append(chart.Ticks, tick)
+		case "positions":
+			position, err := p.parsePosition(line)
+			if err != nil {
+				return fmt.Errorf("error parsing position: %v", err)
+			}
+			chart.Positions = append(chart.Positions, position)
+		}
+		return nil
+	}
+
+	for i < len(lines) {
+		entryLine = i
+		originalLine := lines[i]
+		line := strings.TrimSpace(originalLine)
+
+		if p.Limits.MaxLineLength > 0 && len(originalLine) > p.Limits.MaxLineLength {
+			if err := fail(i, fmt.Errorf("line exceeds maximum length of %d characters", p.Limits.MaxLineLength)); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			i++
+			continue
+		}
+
+		// Check for section headers (only if not indented)
+		if strings.HasSuffix(line, ":") && !strings.HasPrefix(originalLine, " ") && !strings.HasPrefix(originalLine, "\t") {
+			currentSection = strings.TrimSuffix(line, ":")
+			i++
+			continue
+		}
+
+		if err := parseEntry(line); err != nil {
+			if err := fail(entryLine, err); err != nil {
+				return err
+			}
+		}
+		i++
+	}
+
+	if group != nil {
+		// A ParseAll keeps the members of a group left open
+		if err := fail(groupLine, fmt.Errorf("error parsing drawings: group %q is missing its closing }", group.Label)); err != nil {
+			return err
+		}
+		chart.Drawings = append(chart.Drawings, *group)
+	}
+	return nil
+}
+
+// validateReferences checks that series named by indicators and settings exist
+func validateReferences(chart *Chart) error {
+	for _, indicator := range chart.Indicators {
+		if err := checkIndicatorReferences(chart, indicator); err != nil {
+			return err
+		}
+	}
+	return checkConvertReference(chart)
+}
+
+// dropInvalidReferences removes the indicators and convert setting that
+// validateReferences would reject, recording why, so ParseAll can render
+// the rest of the chart
+func (p *CMLParser) dropInvalidReferences(chart *Chart) {
+	valid := chart.Indicators[:0]
+	for _, indicator := range chart.Indicators {
+		if err := checkIndicatorReferences(chart, indicator); err != nil {
+			p.collected = append(p.collected, &ParseError{Msg: err.Error()})
+			continue
+		}
+		valid = append(valid, indicator)
+	}
+	chart.Indicators = valid
+
+	if err := checkConvertReference(chart); err != nil {
+		p.collected = append(p.collected, &ParseError{Msg: err.Error()})
+		settings := chart.Settings[:0]
+		for _, entry := range chart.Settings {
+			if entry.Key != "convert" {
+				settings = append(settings, entry)
+			}
+		}
+		chart.Settings = settings
+	}
+}
+
+// checkIndicatorReferences checks an indicator's options that depend on the
+// rest of the chart
+func checkIndicatorReferences(chart *Chart, indicator Indicator) error {
+	switch indicator.Name {
+	case "relative-strength":
+		// Relative strength needs its benchmark from the series section
+		name, ok := indicator.Parameters["benchmark"].(string)
+		if !ok {
+			return fmt.Errorf("error parsing indicator: relative-strength requires a benchmark series name")
+		}
+		if _, ok := chart.GetSeries(name); !ok {
+			return fmt.Errorf("error parsing indicator: relative-strength benchmark %q is not in the series section", name)
+		}
+	case "bands":
+		// Bands need a known type and a price or series to surround
+		if _, _, bandType := BandsParams(indicator.Parameters); bandType != "stddev" && bandType != "atr" && bandType != "percent" {
+			return fmt.Errorf("error parsing indicator: invalid bands type %q (want stddev, atr, or percent)", bandType)
+		}
+		if source := IndicatorSource(indicator.Parameters); !IsPriceSource(source) {
+			if _, ok := chart.GetSeries(source); !ok {
+				return fmt.Errorf("error parsing indicator: bands source %q is not a price or a series in the series section", source)
+			}
+		}
+	case "chandelier":
+		// Chandelier exits trail longs, shorts, or both
+		if _, _, side := ChandelierParams(indicator.Parameters); side != "long" && side != "short" && side != "both" {
+			return fmt.Errorf("error parsing indicator: invalid chandelier side %q (want long, short, or both)", side)
+		}
+	}
+	return nil
+}
+
+// checkConvertReference checks that a conversion by series has the series
+func checkConvertReference(chart *Chart) error {
+	if convert, ok := chart.GetConvertConfig(); ok && convert.Series != "" {
+		if _, ok := chart.GetSeries(convert.Series); !ok {
+			return fmt.Errorf("error parsing settings: convert series %q is not in the series section", convert.Series)
+		}
+	}
+	return nil
+}
+
+// checkCountLimits returns an error once the chart holds more bars or
+// drawings than the parser's limits allow
+func (p *CMLParser) checkCountLimits(chart *Chart) error {
+	return p.checkCounts(len(chart.Bars), countDrawings(chart.Drawings))
+}
+
+// checkCounts returns an error when bar or drawing counts exceed the limits
+func (p *CMLParser) checkCounts(bars, drawings int) error {
+	if p.Limits.MaxBars > 0 && bars > p.Limits.MaxBars {
+		return fmt.Errorf("chart exceeds maximum of %d bars", p.Limits.MaxBars)
+	}
+	if p.Limits.MaxDrawings > 0 && drawings > p.Limits.MaxDrawings {
+		return fmt.Errorf("chart exceeds maximum of %d drawings", p.Limits.MaxDrawings)
+	}
+	return nil
+}
+
+// countDrawings counts drawings toward MaxDrawings, a group as its members
+func countDrawings(drawings []Drawing) int {
+	count := 0
+	for _, drawing := range drawings {
+		if group, ok := drawing.(Group); ok {
+			count += countDrawings(group.Drawings)
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// parseMetaEntry parses a metadata entry
+func (p *CMLParser) parseMetaEntry(line string) (MetaEntry, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return MetaEntry{}, fmt.Errorf("invalid meta entry format: %s", line)
+	}
+
+	key := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+
+	// Check if it's a grid configuration
+	if key == "grid" && strings.HasPrefix(value, "grid(") && strings.HasSuffix(value, ")") {
+		config, err := p.parseGridConfig(value)
+		if err != nil {
+			return MetaEntry{}, err
+		}
+		return MetaEntry{Key: key, Value: config}, nil
+	}
+
+	// Remove quotes if present
+	if strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	} else {
+		// Try to parse as number
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			return MetaEntry{Key: key, Value: num}, nil
+		}
+	}
+
+	return MetaEntry{Key: key, Value: value}, nil
+}
+
+// checkDateTimeFormat rejects layouts that can't stand in for CML datetimes:
+// they must pin down the date, and can't contain the separators of bar and
+// drawing lines
+func checkDateTimeFormat(layout string) error {
+	if strings.ContainsAny(layout, ",;()") {
+		return fmt.Errorf("invalid datetime-format %q: layout cannot contain commas, semicolons, or parentheses", layout)
+	}
+	reference := time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(layout, reference.Format(layout))
+	if err != nil || !parsed.Equal(reference) {
+		return fmt.Errorf("invalid datetime-format %q: expected a Go time layout with year, month, and day, like \"2006-01-02 15:04\"", layout)
+	}
+	return nil
+}
+
+// parseRenkoBrickSize parses a renko-brick-size setting: a positive price
+// distance, or atr or atr(PERIOD) for the latest average true range
+func (p *CMLParser) parseRenkoBrickSize(value string) (SettingsEntry, error) {
+	key := "renko-brick-size"
+	if value == "atr" {
+		return SettingsEntry{Key: key, Value: RenkoBrickConfig{ATRPeriod: DefaultRenkoATRPeriod}}, nil
+	}
+	if strings.HasPrefix(value, "atr(") && strings.HasSuffix(value, ")") {
+		period, err := strconv.Atoi(strings.TrimSpace(value[4 : len(value)-1]))
+		if err != nil || period < 1 {
+			return SettingsEntry{}, fmt.Errorf("invalid renko-brick-size %q (want a positive ATR period)", value)
+		}
+		return SettingsEntry{Key: key, Value: RenkoBrickConfig{ATRPeriod: period}}, nil
+	}
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size <= 0 || math.IsInf(size, 0) {
+		return SettingsEntry{}, fmt.Errorf("invalid renko-brick-size %q (want a positive price distance, or atr(PERIOD))", value)
+	}
+	return SettingsEntry{Key: key, Value: RenkoBrickConfig{Size: size}}, nil
+}
+
+// parseSettingsEntry parses a settings entry
+func (p *CMLParser) parseSettingsEntry(line string) (SettingsEntry, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return SettingsEntry{}, fmt.Errorf("invalid settings entry format: %s", line)
+	}
+
+	key := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+
+	// Check if it's a bar type
+	if key == "bar-type" && (value == "candlestick" || value == "heikin-ashi" || value == "ohlc" || value == "footprint" || value == "renko") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+
+	// Check if it's the layout bar and drawing datetimes are written in
+	if key == "datetime-format" {
+		layout := strings.Trim(value, `"`)
+		if err := checkDateTimeFormat(layout); err != nil {
+			return SettingsEntry{}, err
+		}
+		return SettingsEntry{Key: key, Value: layout}, nil
+	}
+
+	// Check if it's the tick display mode or aggregation interval
+	if key == "ticks-display" && (value == "bars" || value == "strip") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "tick-interval" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid tick-interval %q: must be a positive duration like 30s or 5m", value)
+		}
+		return SettingsEntry{Key: key, Value: interval}, nil
+	}
+
+	// Check if it's an external bar file reference
+	if key == "bars-file" && value != "" {
+		value = strings.Trim(value, `"`)
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+
+	// Check if it's the auto-color toggle
+	if key == "auto-color" && (value == "true" || value == "false") {
+		return SettingsEntry{Key: key, Value: value == "true"}, nil
+	}
+
+	// Check if it's a corporate action adjustment or marker toggle
+	if key == "adjust" && (value == "none" || value == "splits" || value == "all") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "action-markers" && (value == "true" || value == "false") {
+		return SettingsEntry{Key: key, Value: value == "true"}, nil
+	}
+	if key == "volume-pane" && (value == "true" || value == "false") {
+		return SettingsEntry{Key: key, Value: value == "true"}, nil
+	}
+	if key == "duplicate-bars" {
+		for _, policy := range DuplicateBarPolicies {
+			if value == policy {
+				return SettingsEntry{Key: key, Value: value}, nil
+			}
+		}
+		return SettingsEntry{}, fmt.Errorf("invalid duplicate-bars %q: must be one of %s", value, strings.Join(DuplicateBarPolicies, ", "))
+	}
+
+	// Check if it's a futures roll adjustment or marker toggle
+	if key == "roll-adjust" && (value == "difference" || value == "ratio" || value == "none") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "roll-markers" && (value == "true" || value == "false") {
+		return SettingsEntry{Key: key, Value: value == "true"}, nil
+	}
+
+	// Check if it's a list of drawing groups to hide
+	if key == "hide-group" && value != "" {
+		var labels []string
+		for _, label := range strings.Split(value, ",") {
+			labels = append(labels, strings.Trim(strings.TrimSpace(label), `"`))
+		}
+		return SettingsEntry{Key: key, Value: labels}, nil
+	}
+
+	// Check if it's a bar coloring rule
+	if key == "bar-color" {
+		config, err := parseBarColorConfig(value)
+		if err != nil {
+			return SettingsEntry{}, err
+		}
+		return SettingsEntry{Key: key, Value: config}, nil
+	}
+
+	// Check if it's a price conversion
+	if key == "convert" {
+		config, err := parseConvertConfig(value)
+		if err != nil {
+			return SettingsEntry{}, err
+		}
+		return SettingsEntry{Key: key, Value: config}, nil
+	}
+
+	// Check if it's a PNG encoding option
+	switch key {
+	case "png-compression":
+		if value == "default" || value == "none" || value == "fast" || value == "best" {
+			return SettingsEntry{Key: key, Value: value}, nil
+		}
+		return SettingsEntry{}, fmt.Errorf("invalid png-compression %q (want default, none, fast, or best)", value)
+	case "png-colors":
+		colors, err := strconv.Atoi(value)
+		if err != nil || colors < 2 || colors > 256 {
+			return SettingsEntry{}, fmt.Errorf("invalid png-colors %q (want 2-256)", value)
+		}
+		return SettingsEntry{Key: key, Value: colors}, nil
+	case "png-interlace":
+		if value == "true" || value == "false" {
+			return SettingsEntry{Key: key, Value: value == "true"}, nil
+		}
+	}
+
+	// Check if it's a font size in pixels
+	if key == "axis-font-size" || key == "title-font-size" || key == "note-font-size" {
+		size, err := strconv.ParseFloat(value, 64)
+		if err != nil || size <= 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid %s %q (want a positive number)", key, value)
+		}
+		return SettingsEntry{Key: key, Value: size}, nil
+	}
+
+	// Check if it's the canvas size, like 1200x800
+	if key == "size" {
+		width, height, ok := strings.Cut(strings.ToLower(value), "x")
+		w, errW := strconv.Atoi(strings.TrimSpace(width))
+		h, errH := strconv.Atoi(strings.TrimSpace(height))
+		if !ok || errW != nil || errH != nil || w <= 0 || h <= 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid size %q (want WIDTHxHEIGHT, like 1200x800)", value)
+		}
+		if w > MaxCanvasSize || h > MaxCanvasSize {
+			return SettingsEntry{}, fmt.Errorf("invalid size %q: width and height can be at most %d", value, MaxCanvasSize)
+		}
+		return SettingsEntry{Key: key, Value: CanvasSize{Width: w, Height: h}}, nil
+	}
+
+	// Check if it's a y-axis precision (just a number)
+	if key == "y-axis-precision" {
+		if precision, err := strconv.Atoi(value); err == nil {
+			return SettingsEntry{Key: key, Value: YAxisConfig{Precision: precision}}, nil
+		}
+	}
+
+	// Check if it's a bar opacity (just a number)
+	if key == "bar-opacity" {
+		if opacity, err := strconv.ParseFloat(value, 64); err == nil {
+			return SettingsEntry{Key: key, Value: BarOpacityConfig{Opacity: opacity}}, nil
+		}
+	}
+
+	// Check if it's the side of the price axis
+	if key == "y-axis-position" && (value == "left" || value == "right" || value == "both") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+
+	// Check if it's a frame style, color, or width
+	if key == "frame" && (value == "full" || value == "axes" || value == "none") {
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "frame-color" {
+		if !isHexColor(value) {
+			return SettingsEntry{}, fmt.Errorf("invalid frame-color: %s", value)
+		}
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "frame-width" {
+		width, err := strconv.ParseFloat(value, 64)
+		if err != nil || width <= 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid frame-width %q (want a positive number)", value)
+		}
+		return SettingsEntry{Key: key, Value: width}, nil
+	}
+	// Check if it's the renko brick size: a price distance or atr(PERIOD)
+	if key == "renko-brick-size" {
+		return p.parseRenkoBrickSize(value)
+	}
+
+	if key == "price-pane-height" {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight <= 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid price-pane-height %q (want a positive number)", value)
+		}
+		return SettingsEntry{Key: key, Value: weight}, nil
+	}
+
+	// Check if it's the print mode or crisp lines toggle
+	if (key == "print-mode" || key == "crisp-lines") && (value == "true" || value == "false") {
+		return SettingsEntry{Key: key, Value: value == "true"}, nil
+	}
+
+	// Check if it's the candle outline or wick color
+	if key == "candle-border" {
+		switch value {
+		case "off":
+			return SettingsEntry{Key: key, Value: CandleBorderConfig{}}, nil
+		case "auto":
+			return SettingsEntry{Key: key, Value: CandleBorderConfig{Width: 1, Auto: true}}, nil
+		}
+		width, err := strconv.ParseFloat(value, 64)
+		if err != nil || width < 0 {
+			return SettingsEntry{}, fmt.Errorf("invalid candle-border %q (want off, auto, or a width in pixels)", value)
+		}
+		return SettingsEntry{Key: key, Value: CandleBorderConfig{Width: width}}, nil
+	}
+	if key == "wick-color" {
+		if !isHexColor(value) {
+			return SettingsEntry{}, fmt.Errorf("invalid wick-color: %s", value)
+		}
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+	if key == "theme" {
+		return parseTheme(value)
+	}
+	if key == "fan-color" {
+		if !isHexColor(value) {
+			return SettingsEntry{}, fmt.Errorf("invalid fan-color: %s", value)
+		}
+		return SettingsEntry{Key: key, Value: value}, nil
+	}
+
+	// Check if it's a bar tags mapping
+	if key == "bar-tags" {
+		if value == "" {
+			// Indented format - tags are populated from subsequent lines
+			return SettingsEntry{Key: key, Value: BarTagsConfig{Colors: map[string]string{}}}, nil
+		} else if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+			config := BarTagsConfig{Colors: map[string]string{}}
+			for _, prop := range strings.Split(value[1:len(value)-1], ",") {
+				if err := p.addBarTag(&config, prop); err != nil {
+					return SettingsEntry{}, err
+				}
+			}
+			return SettingsEntry{Key: key, Value: config}, nil
+		}
+	}
+
+	// Check if it's a grid configuration
+	if key == "grid" {
+		// Handle both old format: grid: (enabled=true, ...) and new format: grid: (no value, properties on next lines)
+		if value == "" {
+			// New indented format - return empty config, will be populated by subsequent lines
+			return SettingsEntry{Key: key, Value: GridConfig{}}, nil
+		} else if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+			// Old inline format
+			config, err := p.parseGridConfig("grid" + value)
+			if err != nil {
+				return SettingsEntry{}, err
+			}
+			return SettingsEntry{Key: key, Value: config}, nil
+		}
+	}
+
+	return SettingsEntry{}, fmt.Errorf("unknown settings key: %s", key)
+}
+
+// parseIndentedGridProperties parses indented grid properties
+func (p *CMLParser) parseIndentedGridProperties(lines []string, i *int) (GridConfig, error) {
+	config := GridConfig{}
+
+	// Look ahead for indented lines
+	for *i+1 < len(lines) {
+		nextLine := strings.TrimSpace(lines[*i+1])
+
+		// Check if line is indented (starts with spaces/tabs)
+		if nextLine == "" || !strings.HasPrefix(lines[*i+1], " ") && !strings.HasPrefix(lines[*i+1], "\t") {
+			break
+		}
+
+		*i++ // Move to next line
+
+		// Parse grid property
+		parts := strings.SplitN(nextLine, "=", 2)
+		if len(parts) != 2 {
+			continue // Skip malformed lines
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "enabled":
+			if value == "true" {
+				config.Enabled = true
+			} else if value == "false" {
+				config.Enabled = false
+			}
+		case "line-width":
+			if width, err := strconv.ParseFloat(value, 64); err == nil {
+				config.LineWidth = width
+			}
+		case "color":
+			config.Color = value
+		case "opacity":
+			if opacity, err := strconv.ParseFloat(value, 64); err == nil {
+				config.Opacity = opacity
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// parseIndentedBarTags parses indented tag=color lines following a bar-tags setting
+func (p *CMLParser) parseIndentedBarTags(lines []string, i *int) (BarTagsConfig, error) {
+	config := BarTagsConfig{Colors: map[string]string{}}
+	headerIndent := indentWidth(lines[*i])
+
+	// Tag lines must be indented deeper than the bar-tags line itself
+	for *i+1 < len(lines) {
+		nextLine := strings.TrimSpace(lines[*i+1])
+		if nextLine == "" || indentWidth(lines[*i+1]) <= headerIndent {
+			break
+		}
+
+		*i++ // Move to next line
+
+		if err := p.addBarTag(&config, nextLine); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+// addBarTag parses a single tag=color pair into a bar tags config
+func (p *CMLParser) addBarTag(config *BarTagsConfig, prop string) error {
+	parts := strings.SplitN(strings.TrimSpace(prop), "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid bar tag format: %s", strings.TrimSpace(prop))
+	}
+
+	tag := strings.TrimSpace(parts[0])
+	colorStr := strings.TrimSpace(parts[1])
+	if !isHexColor(colorStr) {
+		return fmt.Errorf("invalid bar tag color: %s", colorStr)
+	}
+
+	if _, exists := config.Colors[tag]; !exists {
+		config.Order = append(config.Order, tag)
+	}
+	config.Colors[tag] = colorStr
+	return nil
+}
+
+// indentWidth returns the number of leading whitespace characters in a line
+func indentWidth(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// parseGridConfig parses a grid configuration
+func (p *CMLParser) parseGridConfig(value string) (GridConfig, error) {
+	// Remove "grid(" and ")"
+	content := strings.TrimPrefix(value, "grid(")
+	content = strings.TrimSuffix(content, ")")
+
+	config := GridConfig{
+		Enabled:   true,      // Default enabled
+		LineWidth: 0.5,       // Default line width
+		Color:     "#000000", // Default color (black)
+		Opacity:   1.0,       // Default opacity (fully opaque)
+	}
+
+	if content == "" {
+		return config, nil
+	}
+
+	// Parse properties
+	properties := strings.Split(content, ",")
+	for _, prop := range properties {
+		prop = strings.TrimSpace(prop)
+		parts := strings.SplitN(prop, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "enabled":
+			config.Enabled = (val == "true")
+		case "line-width":
+			if width, err := strconv.ParseFloat(val, 64); err == nil {
+				config.LineWidth = width
+			}
+		case "color":
+			config.Color = val
+		case "opacity":
+			if opacity, err := strconv.ParseFloat(val, 64); err == nil {
+				config.Opacity = opacity
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// parseYAxisConfig parses a Y-axis configuration
+func (p *CMLParser) parseYAxisConfig(value string) (YAxisConfig, error) {
+	// Remove "y-axis-precision(" and ")"
+	content := strings.TrimPrefix(value, "y-axis-precision(")
+	content = strings.TrimSuffix(content, ")")
+
+	config := YAxisConfig{
+		Precision: 2, // Default 2 decimal places
+	}
+
+	if content == "" {
+		return config, nil
+	}
+
+	// Parse properties
+	properties := strings.Split(content, ",")
+	for _, prop := range properties {
+		prop = strings.TrimSpace(prop)
+		parts := strings.SplitN(prop, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "precision":
+			if precision, err := strconv.Atoi(val); err == nil {
+				config.Precision = precision
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// parseBar parses a price bar
+func (p *CMLParser) parseBar(line string) (Bar, error) {
+	parts := strings.Split(line, ",")
+	if len(parts) < 5 {
+		return Bar{}, fmt.Errorf("invalid bar format: %s", line)
+	}
+
+	// Parse datetime
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Bar{}, fmt.Errorf("error parsing datetime: %v", err)
+	}
+
+	// Parse OHLC values
+	open, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return Bar{}, fmt.Errorf("error parsing open price: %v", err)
+	}
+
+	high, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil {
+		return Bar{}, fmt.Errorf("error parsing high price: %v", err)
+	}
+
+	low, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+	if err != nil {
+		return Bar{}, fmt.Errorf("error parsing low price: %v", err)
+	}
+
+	close, err := strconv.ParseFloat(strings.TrimSpace(parts[4]), 64)
+	if err != nil {
+		return Bar{}, fmt.Errorf("error parsing close price: %v", err)
+	}
+
+	bar := Bar{
+		DateTime: dt,
+		Open:     open,
+		High:     high,
+		Low:      low,
+		Close:    close,
+	}
+
+	// Optional trailing columns: volume (only as the sixth column), a #hex
+	// color, a tag name, or order flow
+	for i, extra := range parts[5:] {
+		extra = strings.TrimSpace(extra)
+		volume, err := strconv.ParseFloat(extra, 64)
+		switch {
+		case i == 0 && err == nil && !isIdentifier(extra):
+			if volume < 0 || math.IsNaN(volume) || math.IsInf(volume, 0) {
+				return Bar{}, fmt.Errorf("invalid volume %q: %s", extra, line)
+			}
+			bar.Volume = volume
+		case strings.Contains(extra, "="):
+			if err := parseBarVolume(&bar, extra); err != nil {
+				return Bar{}, fmt.Errorf("%v: %s", err, line)
+			}
+		case isHexColor(extra):
+			bar.Color = extra
+		case isIdentifier(extra):
+			bar.Tag = extra
+		default:
+			return Bar{}, fmt.Errorf("invalid bar column %q: %s", extra, line)
+		}
+	}
+
+	if bar.HasQuote() && bar.BidPrice > bar.AskPrice {
+		return Bar{}, fmt.Errorf("bid-price %g is above ask-price %g: %s", bar.BidPrice, bar.AskPrice, line)
+	}
+
+	// Without bid/ask totals, the levels add up to the bar's volume
+	if len(bar.Levels) > 0 && !bar.HasDelta {
+		for _, level := range bar.Levels {
+			bar.BidVolume += level.BidVolume
+			bar.AskVolume += level.AskVolume
+		}
+		bar.Delta = bar.AskVolume - bar.BidVolume
+		bar.HasDelta = true
+	}
+
+	return bar, nil
+}
+
+// parseBarVolume parses an order flow column of a bar: bid=N, ask=N,
+// delta=N, levels=PRICE:BIDxASK|PRICE:BIDxASK..., or a bid-price=N or
+// ask-price=N quote
+func parseBarVolume(bar *Bar, column string) error {
+	parts := strings.SplitN(column, "=", 2)
+	key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+	if key == "bid-price" || key == "ask-price" {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price <= 0 {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+		if key == "bid-price" {
+			bar.BidPrice = price
+		} else {
+			bar.AskPrice = price
+		}
+		return nil
+	}
+
+	if key == "levels" {
+		for _, level := range strings.Split(value, "|") {
+			priceStr, volumes, ok := strings.Cut(strings.TrimSpace(level), ":")
+			bidStr, askStr, ok2 := strings.Cut(volumes, "x")
+			if !ok || !ok2 {
+				return fmt.Errorf("invalid price level %q (want PRICE:BIDxASK)", level)
+			}
+			price, err := strconv.ParseFloat(strings.TrimSpace(priceStr), 64)
+			if err != nil {
+				return fmt.Errorf("invalid price level %q: %v", level, err)
+			}
+			bid, err := strconv.ParseFloat(strings.TrimSpace(bidStr), 64)
+			if err != nil {
+				return fmt.Errorf("invalid price level %q: %v", level, err)
+			}
+			ask, err := strconv.ParseFloat(strings.TrimSpace(askStr), 64)
+			if err != nil {
+				return fmt.Errorf("invalid price level %q: %v", level, err)
+			}
+			bar.Levels = append(bar.Levels, PriceLevel{Price: price, BidVolume: bid, AskVolume: ask})
+		}
+		return nil
+	}
+
+	volume, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s volume %q", key, value)
+	}
+	switch key {
+	case "bid":
+		bar.BidVolume = volume
+		bar.Delta = bar.AskVolume - bar.BidVolume
+	case "ask":
+		bar.AskVolume = volume
+		bar.Delta = bar.AskVolume - bar.BidVolume
+	case "delta":
+		bar.Delta = volume
+	default:
+		return fmt.Errorf("unknown bar column %q", key)
+	}
+	bar.HasDelta = true
+	return nil
+}
+
+// splitBarAnnotations separates trailing "!flag" annotations from a bar line
+func splitBarAnnotations(line string) (string, string) {
+	idx := strings.Index(line, "!")
+	if idx == -1 {
+		return line, ""
+	}
+
+	barLine := strings.TrimSpace(line[:idx])
+	barLine = strings.TrimSpace(strings.TrimSuffix(barLine, ","))
+	return barLine, strings.TrimSpace(line[idx:])
+}
+
+// parseBarAnnotations expands per-bar annotation flags (e.g. `!buy "entered"`)
+// into markers and notes on the bar at dt
+func (p *CMLParser) parseBarAnnotations(dt time.Time, spec string) ([]Drawing, error) {
+	var drawings []Drawing
+
+	for spec != "" {
+		if !strings.HasPrefix(spec, "!") {
+			return nil, fmt.Errorf("invalid bar annotation: %s", spec)
+		}
+
+		// Read the flag name (letters up to the next space, quote, or flag)
+		end := 1
+		for end < len(spec) && strings.IndexByte(" \t\"!", spec[end]) == -1 {
+			end++
+		}
+		flag := spec[1:end]
+		spec = strings.TrimSpace(spec[end:])
+
+		// Read the optional quoted note text
+		text := ""
+		if strings.HasPrefix(spec, `"`) {
+			closing := strings.Index(spec[1:], `"`)
+			if closing == -1 {
+				return nil, fmt.Errorf("unterminated bar annotation text: %s", spec)
+			}
+			text = spec[1 : closing+1]
+			spec = strings.TrimSpace(spec[closing+2:])
+		}
+
+		// Marker text becomes the marker's label
+		switch flag {
+		case "buy":
+			drawings = append(drawings, Triangle{DateTime: dt, Direction: "uptick", Label: text, Styles: map[string]interface{}{"fill-color": "#00AA00"}})
+		case "sell":
+			drawings = append(drawings, Triangle{DateTime: dt, Direction: "downtick", Label: text, Styles: map[string]interface{}{"fill-color": "#DD0000"}})
+		case "over":
+			drawings = append(drawings, Circle{DateTime: dt, Position: "over", Label: text, Styles: map[string]interface{}{}})
+		case "under":
+			drawings = append(drawings, Circle{DateTime: dt, Position: "under", Label: text, Styles: map[string]interface{}{}})
+		case "note":
+			if text == "" {
+				return nil, fmt.Errorf("bar annotation !note requires text")
+			}
+			drawings = append(drawings, Note{DateTime: dt, Text: text, Position: "over", Styles: map[string]interface{}{}})
+		default:
+			return nil, fmt.Errorf("unknown bar annotation flag: !%s", flag)
+		}
+	}
+
+	return drawings, nil
+}
+
+// isHexColor reports whether s is exactly a #RGB, #RGBA, #RRGGBB, or #RRGGBBAA color
+func isHexColor(s string) bool {
+	if !strings.HasPrefix(s, "#") || (len(s) != 4 && len(s) != 5 && len(s) != 7 && len(s) != 9) {
+		return false
+	}
+	_, err := strconv.ParseUint(s[1:], 16, 32)
+	return err == nil
+}
+
+// isIdentifier reports whether s is a letter followed by letters, digits,
+// underscores, or dashes
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, ch := range s {
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if i == 0 && !isLetter {
+			return false
+		}
+		if !isLetter && !(ch >= '0' && ch <= '9') && ch != '_' && ch != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGroupHeader parses a group opening line such as
+// group "old-analysis" (border-color=#999999, line-style=dashed): {
+func (p *CMLParser) parseGroupHeader(line string) (Group, error) {
+	header := strings.TrimSpace(strings.TrimPrefix(line, "group"))
+	if !strings.HasSuffix(header, "{") {
+		return Group{}, fmt.Errorf("group header must end with {: %s", line)
+	}
+	header = strings.TrimSpace(strings.TrimSuffix(header, "{"))
+	if !strings.HasSuffix(header, ":") {
+		return Group{}, fmt.Errorf("group header must end with : {: %s", line)
+	}
+	header = strings.TrimSpace(strings.TrimSuffix(header, ":"))
+
+	if !strings.HasPrefix(header, `"`) {
+		return Group{}, fmt.Errorf("group label must be quoted: %s", line)
+	}
+	end := strings.Index(header[1:], `"`)
+	if end < 0 {
+		return Group{}, fmt.Errorf("unterminated group label: %s", line)
+	}
+	group := Group{
+		Label:    header[1 : end+1],
+		Drawings: []Drawing{},
+		Styles:   make(map[string]interface{}),
+	}
+
+	// Optional shared styles in parentheses
+	rest := strings.TrimSpace(header[end+2:])
+	if rest == "" {
+		return group, nil
+	}
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return Group{}, fmt.Errorf("invalid group styles: %s", rest)
+	}
+	for _, prop := range strings.Split(rest[1:len(rest)-1], ",") {
+		parts := strings.SplitN(prop, "=", 2)
+		if len(parts) != 2 {
+			return Group{}, fmt.Errorf("invalid group style: %s", strings.TrimSpace(prop))
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if num, err := strconv.ParseFloat(value, 64); err == nil {
+			group.Styles[key] = num
+		} else {
+			group.Styles[key] = value
+		}
+	}
+	return group, nil
+}
+
+// parseDrawing parses a drawing element
+func (p *CMLParser) parseDrawing(lines []string, i *int) (Drawing, error) {
+	line := strings.TrimSpace(lines[*i])
+
+	// Parse styles from subsequent lines
+	styles := make(map[string]interface{})
+	*i++
+	for *i < len(lines) {
+		styleLine := strings.TrimSpace(lines[*i])
+		if styleLine == "" || strings.HasPrefix(styleLine, "#") {
+			break
+		}
+
+		// Check if this is a new drawing (no indentation and contains parentheses)
+		if !strings.HasPrefix(styleLine, " ") && !strings.HasPrefix(styleLine, "\t") && strings.Contains(styleLine, "(") {
+			*i-- // Back up one line
+			break
+		}
+
+		// Group delimiters end the style block too
+		if styleLine == "}" || strings.HasPrefix(styleLine, "group ") {
+			*i--
+			break
+		}
+
+		// Parse style property
+		parts := strings.SplitN(styleLine, "=", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+
+			// Try to parse as number
+			if num, err := strconv.ParseFloat(value, 64); err == nil {
+				styles[key] = num
+			} else {
+				styles[key] = value
+			}
+		}
+		*i++
+	}
+
+	// Parse the drawing type and parameters
+	if strings.HasPrefix(line, "rectangle(") {
+		return p.parseRectangle(line, styles)
+	} else if strings.HasPrefix(line, "line(") {
+		return p.parseLine(line, styles)
+	} else if strings.HasPrefix(line, "continuous-line(") {
+		return p.parseContinuousLine(line, styles)
+	} else if strings.HasPrefix(line, "curve(") {
+		return p.parseCurve(line, styles)
+	} else if strings.HasPrefix(line, "trendline(") {
+		return p.parseTrendline(line, styles)
+	} else if strings.HasPrefix(line, "path(") {
+		return p.parsePath(line, styles)
+	} else if strings.HasPrefix(line, "trail(") {
+		return p.parseTrail(line, styles)
+	} else if strings.HasPrefix(line, "ray(") {
+		return p.parseRay(line, styles)
+	} else if strings.HasPrefix(line, "uptick-triangle(") {
+		return p.parseTriangle(line, "uptick", styles)
+	} else if strings.HasPrefix(line, "downtick-triangle(") {
+		return p.parseTriangle(line, "downtick", styles)
+	} else if strings.HasPrefix(line, "undercircle(") {
+		return p.parseCircle(line, "under", styles)
+	} else if strings.HasPrefix(line, "overcircle(") {
+		return p.parseCircle(line, "over", styles)
+	} else if strings.HasPrefix(line, "undernote(") {
+		return p.parseNote(line, "under", styles)
+	} else if strings.HasPrefix(line, "overnote(") {
+		return p.parseNote(line, "over", styles)
+	} else if strings.HasPrefix(line, "highlight-bar(") {
+		return p.parseHighlightBar(line, styles)
+	} else if strings.HasPrefix(line, "rr-box(") {
+		return p.parseRRBox(line, styles)
+	} else if strings.HasPrefix(line, "cone(") {
+		return p.parseCone(line, styles)
+	}
+
+	return nil, fmt.Errorf("unknown drawing type: %s", line)
+}
+
+// parseRectangle parses a rectangle drawing
+func (p *CMLParser) parseRectangle(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from rectangle(datetime1,price1;datetime2,price2)
+	content := strings.TrimPrefix(line, "rectangle(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid rectangle format")
+	}
+
+	// Parse start point
+	startParts := strings.Split(parts[0], ",")
+	if len(startParts) != 2 {
+		return nil, fmt.Errorf("invalid rectangle start point")
+	}
+
+	startTime, err := p.ParseDateTime(strings.TrimSpace(startParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	startPrice, err := strconv.ParseFloat(strings.TrimSpace(startParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse end point
+	endParts := strings.Split(parts[1], ",")
+	if len(endParts) != 2 {
+		return nil, fmt.Errorf("invalid rectangle end point")
+	}
+
+	endTime, err := p.ParseDateTime(strings.TrimSpace(endParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	endPrice, err := strconv.ParseFloat(strings.TrimSpace(endParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return Rectangle{
+		StartTime:  startTime,
+		StartPrice: startPrice,
+		EndTime:    endTime,
+		EndPrice:   endPrice,
+		Styles:     styles,
+	}, nil
+}
+
+// parseLine parses a line drawing
+func (p *CMLParser) parseLine(line string, styles map[string]interface{}) (Drawing, error) {
+	// Similar to rectangle but with arrow and line style support
+	content := strings.TrimPrefix(line, "line(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid line format")
+	}
+
+	// Parse start and end points (similar to rectangle)
+	startParts := strings.Split(parts[0], ",")
+	if len(startParts) != 2 {
+		return nil, fmt.Errorf("invalid line start point")
+	}
+
+	startTime, err := p.ParseDateTime(strings.TrimSpace(startParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	startPrice, err := strconv.ParseFloat(strings.TrimSpace(startParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	endParts := strings.Split(parts[1], ",")
+	if len(endParts) != 2 {
+		return nil, fmt.Errorf("invalid line end point")
+	}
+
+	endTime, err := p.ParseDateTime(strings.TrimSpace(endParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	endPrice, err := strconv.ParseFloat(strings.TrimSpace(endParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract arrow properties and line style from styles
+	leftArrow := false
+	rightArrow := false
+	if val, ok := styles["left-arrow"]; ok {
+		if str, ok := val.(string); ok && str == "true" {
+			leftArrow = true
+		}
+	}
+	if val, ok := styles["right-arrow"]; ok {
+		if str, ok := val.(string); ok && str == "true" {
+			rightArrow = true
+		}
+	}
+
+	lineStyle := ""
+	if val, ok := styles["style"]; ok {
+		lineStyle = val.(string)
+	}
+
+	// Determine arrow type based on properties
+	arrow := ""
+	if leftArrow && rightArrow {
+		arrow = "both-arrows"
+	} else if leftArrow {
+		arrow = "left-arrow"
+	} else if rightArrow {
+		arrow = "right-arrow"
+	}
+
+	return Line{
+		StartTime:  startTime,
+		StartPrice: startPrice,
+		EndTime:    endTime,
+		EndPrice:   endPrice,
+		Arrow:      arrow,
+		LineStyle:  lineStyle,
+		Styles:     styles,
+	}, nil
+}
+
+// parseContinuousLine parses a continuous line drawing
+func (p *CMLParser) parseContinuousLine(line string, styles map[string]interface{}) (Drawing, error) {
+	// Similar to line but without arrow support
+	content := strings.TrimPrefix(line, "continuous-line(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid continuous line format")
+	}
+
+	// Parse start and end points (same as line)
+	startParts := strings.Split(parts[0], ",")
+	if len(startParts) != 2 {
+		return nil, fmt.Errorf("invalid continuous line start point")
+	}
+
+	startTime, err := p.ParseDateTime(strings.TrimSpace(startParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	startPrice, err := strconv.ParseFloat(strings.TrimSpace(startParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	endParts := strings.Split(parts[1], ",")
+	if len(endParts) != 2 {
+		return nil, fmt.Errorf("invalid continuous line end point")
+	}
+
+	endTime, err := p.ParseDateTime(strings.TrimSpace(endParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	endPrice, err := strconv.ParseFloat(strings.TrimSpace(endParts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	lineStyle := ""
+	if val, ok := styles["style"]; ok {
+		lineStyle = val.(string)
+	}
+
+	return ContinuousLine{
+		StartTime:  startTime,
+		StartPrice: startPrice,
+		EndTime:    endTime,
+		EndPrice:   endPrice,
+		LineStyle:  lineStyle,
+		Styles:     styles,
+	}, nil
+}
+
+// parseCurve parses a curved connector drawing
+func (p *CMLParser) parseCurve(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from curve(datetime1,price1;datetime2,price2[;curvature=0.3])
+	content := strings.TrimPrefix(line, "curve(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("invalid curve format")
+	}
+
+	startTime, startPrice, err := p.parsePoint(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid curve start point: %v", err)
+	}
+
+	endTime, endPrice, err := p.parsePoint(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid curve end point: %v", err)
+	}
+
+	curvature := 0.3 // Default gentle bend
+	if len(parts) == 3 {
+		param := strings.SplitN(strings.TrimSpace(parts[2]), "=", 2)
+		if len(param) != 2 || strings.TrimSpace(param[0]) != "curvature" {
+			return nil, fmt.Errorf("invalid curve parameter: %s", parts[2])
+		}
+		curvature, err = strconv.ParseFloat(strings.TrimSpace(param[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid curve curvature: %v", err)
+		}
+	}
+
+	return Curve{
+		StartTime:  startTime,
+		StartPrice: startPrice,
+		EndTime:    endTime,
+		EndPrice:   endPrice,
+		Curvature:  curvature,
+		Styles:     styles,
+	}, nil
+}
+
+// parseRay parses an anchor + slope trendline drawing
+func (p *CMLParser) parseRay(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from ray(datetime, price, slope=0.25/bar)
+	content := strings.TrimPrefix(line, "ray(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid ray format")
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	param := strings.SplitN(strings.TrimSpace(parts[2]), "=", 2)
+	if len(param) != 2 || strings.TrimSpace(param[0]) != "slope" {
+		return nil, fmt.Errorf("invalid ray slope: %s", strings.TrimSpace(parts[2]))
+	}
+	slopeStr := strings.TrimSuffix(strings.TrimSpace(param[1]), "/bar")
+	slope, err := strconv.ParseFloat(slopeStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ray slope: %v", err)
+	}
+
+	lineStyle := ""
+	if val, ok := styles["style"]; ok {
+		lineStyle = val.(string)
+	}
+
+	return Ray{
+		DateTime:  dt,
+		Price:     price,
+		Slope:     slope,
+		LineStyle: lineStyle,
+		Styles:    styles,
+	}, nil
+}
+
+// parseCone parses a projection cone: cone(datetime, price ; slope_up, slope_down),
+// with slopes in price per bar and an optional /bar suffix
+func (p *CMLParser) parseCone(line string, styles map[string]interface{}) (Drawing, error) {
+	content := strings.TrimPrefix(line, "cone(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cone format")
+	}
+
+	dt, price, err := p.parsePoint(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid cone anchor: %v", err)
+	}
+
+	slopeParts := strings.Split(parts[1], ",")
+	if len(slopeParts) != 2 {
+		return nil, fmt.Errorf("invalid cone slopes: %s", strings.TrimSpace(parts[1]))
+	}
+	var slopes [2]float64
+	for i, part := range slopeParts {
+		slopes[i], err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(part), "/bar"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cone slope: %v", err)
+		}
+	}
+	if slopes[0] <= slopes[1] {
+		return nil, fmt.Errorf("cone upper slope %g must be greater than lower slope %g", slopes[0], slopes[1])
+	}
+
+	return Cone{
+		DateTime:  dt,
+		Price:     price,
+		SlopeUp:   slopes[0],
+		SlopeDown: slopes[1],
+		Styles:    styles,
+	}, nil
+}
+
+// parseTrendline parses a regression-fit trendline drawing
+func (p *CMLParser) parseTrendline(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from trendline(datetime1..datetime2[, fit=close])
+	content := strings.TrimPrefix(line, "trendline(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ",")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("invalid trendline format")
+	}
+
+	rangeParts := strings.Split(parts[0], "..")
+	if len(rangeParts) != 2 {
+		return nil, fmt.Errorf("invalid trendline range: %s", strings.TrimSpace(parts[0]))
+	}
+
+	startTime, err := p.ParseDateTime(strings.TrimSpace(rangeParts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	endTime, err := p.ParseDateTime(strings.TrimSpace(rangeParts[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	if endTime.Before(startTime) {
+		return nil, fmt.Errorf("trendline range ends before it starts")
+	}
+
+	fit := "close"
+	if len(parts) == 2 {
+		param := strings.SplitN(strings.TrimSpace(parts[1]), "=", 2)
+		if len(param) != 2 || strings.TrimSpace(param[0]) != "fit" {
+			return nil, fmt.Errorf("invalid trendline parameter: %s", strings.TrimSpace(parts[1]))
+		}
+		fit = strings.TrimSpace(param[1])
+		if fit != "high" && fit != "low" && fit != "close" {
+			return nil, fmt.Errorf("invalid trendline fit: %s", fit)
+		}
+	}
+
+	extend := ""
+	if val, ok := styles["extend"]; ok {
+		if str, ok := val.(string); ok {
+			switch str {
+			case "true", "right":
+				extend = "right"
+			case "left", "both":
+				extend = str
+			}
+		}
+	}
+
+	return Trendline{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Fit:       fit,
+		Extend:    extend,
+		Styles:    styles,
+	}, nil
+}
+
+// parsePath parses a freeform path drawing
+func (p *CMLParser) parsePath(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from path(datetime1,price1;datetime2,price2;...)
+	content := strings.TrimPrefix(line, "path(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid path format: at least two points are required")
+	}
+
+	points := make([]PathPoint, 0, len(parts))
+	for i, part := range parts {
+		dt, price, err := p.parsePoint(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path point %d: %v", i+1, err)
+		}
+		points = append(points, PathPoint{Time: dt, Price: price})
+	}
+
+	closed := false
+	if val, ok := styles["closed"]; ok {
+		if str, ok := val.(string); ok && str == "true" {
+			closed = true
+		}
+	}
+
+	return Path{
+		Points: points,
+		Closed: closed,
+		Styles: styles,
+	}, nil
+}
+
+// parseTrail parses a trailing stop drawing
+func (p *CMLParser) parseTrail(line string, styles map[string]interface{}) (Drawing, error) {
+	// Extract parameters from trail(datetime1,stop1;datetime2,stop2;...)
+	content := strings.TrimPrefix(line, "trail(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ";")
+	points := make([]PathPoint, 0, len(parts))
+	for i, part := range parts {
+		dt, price, err := p.parsePoint(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trail point %d: %v", i+1, err)
+		}
+		if i > 0 && dt.Before(points[i-1].Time) {
+			return nil, fmt.Errorf("invalid trail point %d: points must be in time order", i+1)
+		}
+		points = append(points, PathPoint{Time: dt, Price: price})
+	}
+
+	extend := false
+	if val, ok := styles["extend"].(string); ok {
+		extend = val == "true" || val == "right"
+	}
+
+	return Trail{
+		Points: points,
+		Extend: extend,
+		Styles: styles,
+	}, nil
+}
+
+// parsePoint parses a "datetime,price" pair
+func (p *CMLParser) parsePoint(point string) (time.Time, float64, error) {
+	parts := strings.Split(point, ",")
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("invalid point format: %s", strings.TrimSpace(point))
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	return dt, price, nil
+}
+
+// markerLabelPattern matches a marker line ending in label="text"
+var markerLabelPattern = regexp.MustCompile(`^(.*\))\s+label\s*=\s*"([^"]*)"$`)
+
+// splitMarkerLabel separates a marker line from its label, given either
+// inline after the marker or as a label style
+func splitMarkerLabel(line string, styles map[string]interface{}) (string, string) {
+	if m := markerLabelPattern.FindStringSubmatch(line); m != nil {
+		return m[1], m[2]
+	}
+	if label, ok := styles["label"].(string); ok {
+		return line, strings.Trim(label, `"`)
+	}
+	return line, ""
+}
+
+// parseTriangle parses a triangle marker
+func (p *CMLParser) parseTriangle(line string, direction string, styles map[string]interface{}) (Drawing, error) {
+	line, label := splitMarkerLabel(line, styles)
+	content := strings.TrimPrefix(line, direction+"-triangle(")
+	content = strings.TrimSuffix(content, ")")
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return Triangle{
+		DateTime:  dt,
+		Direction: direction,
+		Label:     label,
+		Styles:    styles,
+	}, nil
+}
+
+// parseCircle parses a circle marker
+func (p *CMLParser) parseCircle(line string, position string, styles map[string]interface{}) (Drawing, error) {
+	line, label := splitMarkerLabel(line, styles)
+	content := strings.TrimPrefix(line, position+"circle(")
+	content = strings.TrimSuffix(content, ")")
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return Circle{
+		DateTime: dt,
+		Position: position,
+		Label:    label,
+		Styles:   styles,
+	}, nil
+}
+
+// parseHighlightBar parses a bar highlight
+func (p *CMLParser) parseHighlightBar(line string, styles map[string]interface{}) (Drawing, error) {
+	content := strings.TrimPrefix(line, "highlight-bar(")
+	content = strings.TrimSuffix(content, ")")
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(content))
+	if err != nil {
+		return nil, err
+	}
+
+	return HighlightBar{
+		DateTime: dt,
+		Styles:   styles,
+	}, nil
+}
+
+// parseRRBox parses a risk/reward box:
+// rr-box(entry_datetime, entry, stop, target, end_datetime)
+func (p *CMLParser) parseRRBox(line string, styles map[string]interface{}) (Drawing, error) {
+	content := strings.TrimPrefix(line, "rr-box(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.Split(content, ",")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid rr-box format (want entry time, entry, stop, target, end time)")
+	}
+
+	startTime, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+	endTime, err := p.ParseDateTime(strings.TrimSpace(parts[4]))
+	if err != nil {
+		return nil, err
+	}
+	if !endTime.After(startTime) {
+		return nil, fmt.Errorf("rr-box must end after its entry time")
+	}
+
+	var prices [3]float64
+	for i, part := range parts[1:4] {
+		prices[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rr-box price %q", strings.TrimSpace(part))
+		}
+	}
+	entry, stop, target := prices[0], prices[1], prices[2]
+	if (stop-entry)*(target-entry) >= 0 {
+		return nil, fmt.Errorf("rr-box stop and target must be on opposite sides of the entry")
+	}
+
+	return RRBox{
+		StartTime: startTime,
+		EndTime:   endTime,
+		Entry:     entry,
+		Stop:      stop,
+		Target:    target,
+		Styles:    styles,
+	}, nil
+}
+
+// parseNote parses a text note
+func (p *CMLParser) parseNote(line string, position string, styles map[string]interface{}) (Drawing, error) {
+	content := strings.TrimPrefix(line, position+"note(")
+	content = strings.TrimSuffix(content, ")")
+
+	parts := strings.SplitN(content, ",", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid note format")
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimSpace(parts[1])
+	// Remove quotes if present
+	if strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		text = text[1 : len(text)-1]
+	}
+
+	return Note{
+		DateTime: dt,
+		Text:     text,
+		Position: position,
+		Styles:   styles,
+	}, nil
+}
+
+// parseIndicator parses a technical indicator
+func (p *CMLParser) parseIndicator(line string) (Indicator, error) {
+	// Extract indicator name and parameters
+	openParen := strings.Index(line, "(")
+	if openParen == -1 {
+		return Indicator{}, fmt.Errorf("invalid indicator format: %s", line)
+	}
+
+	name := strings.TrimSpace(line[:openParen])
+	paramsStr := strings.TrimSpace(line[openParen+1:])
+	paramsStr = strings.TrimSuffix(paramsStr, ")")
+
+	parameters := make(map[string]interface{})
+
+	if paramsStr != "" {
+		params := strings.Split(paramsStr, ",")
+		for _, param := range params {
+			parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(parts) == 2 {
+				key := strings.TrimSpace(parts[0])
+				value := strings.TrimSpace(parts[1])
+
+				// Try to parse as number
+				if num, err := strconv.ParseFloat(value, 64); err == nil {
+					parameters[key] = num
+				} else {
+					parameters[key] = value
+				}
+			}
+		}
+	}
+
+	return Indicator{
+		Name:       name,
+		Parameters: parameters,
+	}, nil
+}
+
+// parsePosition parses a position line like
+// long(2025/01/15 10:15, size=100, entry=1.2520, stop=1.2480, target=1.2640)
+func (p *CMLParser) parsePosition(line string) (Position, error) {
+	openParen := strings.Index(line, "(")
+	if openParen == -1 || !strings.HasSuffix(line, ")") {
+		return Position{}, fmt.Errorf("invalid position format: %s", line)
+	}
+
+	position := Position{Side: strings.TrimSpace(line[:openParen])}
+	if position.Side != "long" && position.Side != "short" {
+		return Position{}, fmt.Errorf("unknown position side %q (want long or short)", position.Side)
+	}
+
+	params := strings.Split(line[openParen+1:len(line)-1], ",")
+	dt, err := p.ParseDateTime(strings.TrimSpace(params[0]))
+	if err != nil {
+		return Position{}, err
+	}
+	position.DateTime = dt
+
+	hasEntry := false
+	for _, param := range params[1:] {
+		parts := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(parts) != 2 {
+			return Position{}, fmt.Errorf("invalid position parameter: %s", param)
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if key == "order" {
+			if value != "true" && value != "false" {
+				return Position{}, fmt.Errorf("invalid order %q (want true or false)", value)
+			}
+			position.Order = value == "true"
+			continue
+		}
+
+		num, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Position{}, fmt.Errorf("invalid position %s %q", key, value)
+		}
+		switch key {
+		case "size":
+			position.Size = num
+		case "entry":
+			position.Entry = num
+			hasEntry = true
+		case "stop":
+			position.Stop = num
+		case "target":
+			position.Target = num
+		default:
+			return Position{}, fmt.Errorf("unknown position parameter: %s", key)
+		}
+	}
+
+	if !hasEntry {
+		return Position{}, fmt.Errorf("position is missing its entry price: %s", line)
+	}
+
+	// Stops and targets must be on the losing and winning side of the entry
+	sign := 1.0
+	if position.Side == "short" {
+		sign = -1.0
+	}
+	if position.Stop != 0 && (position.Stop-position.Entry)*sign >= 0 {
+		return Position{}, fmt.Errorf("stop %g is on the wrong side of entry %g for a %s position", position.Stop, position.Entry, position.Side)
+	}
+	if position.Target != 0 && (position.Target-position.Entry)*sign <= 0 {
+		return Position{}, fmt.Errorf("target %g is on the wrong side of entry %g for a %s position", position.Target, position.Entry, position.Side)
+	}
+
+	return position, nil
+}
+
+// parseSeriesPoint parses a "datetime, value" series row, optionally
+// followed by the value's lower and upper bounds
+func (p *CMLParser) parseSeriesPoint(line string) (SeriesPoint, error) {
+	parts := strings.Split(line, ",")
+	if len(parts) != 2 && len(parts) != 4 {
+		return SeriesPoint{}, fmt.Errorf("invalid series point format: %s", line)
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return SeriesPoint{}, fmt.Errorf("error parsing datetime: %v", err)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return SeriesPoint{}, fmt.Errorf("error parsing series value: %v", err)
+	}
+	point := SeriesPoint{DateTime: dt, Value: value}
+	if len(parts) == 2 {
+		return point, nil
+	}
+
+	// Bounds follow the value as "lower, upper"
+	if point.Lower, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err != nil {
+		return SeriesPoint{}, fmt.Errorf("error parsing series lower bound: %v", err)
+	}
+	if point.Upper, err = strconv.ParseFloat(strings.TrimSpace(parts[3]), 64); err != nil {
+		return SeriesPoint{}, fmt.Errorf("error parsing series upper bound: %v", err)
+	}
+	if point.Lower > value || value > point.Upper {
+		return SeriesPoint{}, fmt.Errorf("series value must be between its lower and upper bounds: %s", line)
+	}
+	point.HasBounds = true
+	return point, nil
+}
+
+// parseFanHeader parses a fan's percentile header like "P10, P50, P90";
+// percentiles must be ascending and between 0 and 100
+func parseFanHeader(line string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(line, ",") {
+		field = strings.TrimSpace(field)
+		if !strings.HasPrefix(field, "P") && !strings.HasPrefix(field, "p") {
+			return nil, fmt.Errorf("invalid percentile %q (want e.g. P10)", field)
+		}
+		percentile, err := strconv.ParseFloat(field[1:], 64)
+		if err != nil || percentile <= 0 || percentile >= 100 {
+			return nil, fmt.Errorf("invalid percentile %q (want e.g. P10)", field)
+		}
+		if n := len(percentiles); n > 0 && percentile <= percentiles[n-1] {
+			return nil, fmt.Errorf("percentiles must be in ascending order: %s", line)
+		}
+		percentiles = append(percentiles, percentile)
+	}
+	if len(percentiles) < 2 {
+		return nil, fmt.Errorf("a fan needs at least two percentiles: %s", line)
+	}
+	return percentiles, nil
+}
+
+// parseFanPoint parses a fan row of datetime followed by one value per
+// percentile; values must not decrease from one percentile to the next
+func (p *CMLParser) parseFanPoint(line string, percentiles int) (FanPoint, error) {
+	parts := strings.Split(line, ",")
+	if len(parts) != percentiles+1 {
+		return FanPoint{}, fmt.Errorf("expected %d values, got %d: %s", percentiles, len(parts)-1, line)
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return FanPoint{}, fmt.Errorf("error parsing datetime: %v", err)
+	}
+
+	values := make([]float64, percentiles)
+	for i, part := range parts[1:] {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return FanPoint{}, fmt.Errorf("error parsing fan value: %v", err)
+		}
+		if i > 0 && value < values[i-1] {
+			return FanPoint{}, fmt.Errorf("values must not decrease across percentiles: %s", line)
+		}
+		values[i] = value
+	}
+	return FanPoint{DateTime: dt, Values: values}, nil
+}
+
+// parseConvertConfig parses a conversion like "rate=0.92 unit=EUR" or
+// "series=EURUSD unit=EUR"
+func parseConvertConfig(value string) (ConvertConfig, error) {
+	var config ConvertConfig
+	for _, field := range strings.Fields(value) {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return ConvertConfig{}, fmt.Errorf("invalid convert property %q (want key=value)", field)
+		}
+		switch key {
+		case "rate":
+			rate, err := strconv.ParseFloat(val, 64)
+			if err != nil || rate <= 0 {
+				return ConvertConfig{}, fmt.Errorf("invalid convert rate %q: must be a positive number", val)
+			}
+			config.Rate = rate
+		case "series":
+			config.Series = val
+		case "unit":
+			config.Unit = strings.Trim(val, `"`)
+		default:
+			return ConvertConfig{}, fmt.Errorf("unknown convert property %q", key)
+		}
+	}
+
+	if (config.Rate == 0) == (config.Series == "") {
+		return ConvertConfig{}, fmt.Errorf("convert needs exactly one of rate or series")
+	}
+	return config, nil
+}
+
+// parseBarColorConfig parses a bar coloring rule like
+// "impulse(ema=13, macd=12,26,9)" or "ma(sma=50)"
+func parseBarColorConfig(value string) (BarColorConfig, error) {
+	rule, args, ok := strings.Cut(value, "(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return BarColorConfig{}, fmt.Errorf("invalid bar-color %q (want impulse(...) or ma(...))", value)
+	}
+
+	// Values like macd=12,26,9 contain commas, so a part without "=" continues the previous value
+	params := map[string]string{}
+	var keys []string
+	for _, part := range strings.Split(strings.TrimSuffix(args, ")"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if key, val, ok := strings.Cut(part, "="); ok {
+			key = strings.TrimSpace(key)
+			params[key] = strings.TrimSpace(val)
+			keys = append(keys, key)
+		} else if len(keys) > 0 {
+			params[keys[len(keys)-1]] += "," + part
+		} else {
+			return BarColorConfig{}, fmt.Errorf("invalid bar-color parameter %q (want key=value)", part)
+		}
+	}
+
+	config := BarColorConfig{Rule: strings.TrimSpace(rule)}
+	switch config.Rule {
+	case "impulse":
+		config.EMA, config.Fast, config.Slow, config.Signal = 13, 12, 26, 9
+		for key, val := range params {
+			switch key {
+			case "ema":
+				period, err := strconv.Atoi(val)
+				if err != nil || period < 1 {
+					return BarColorConfig{}, fmt.Errorf("invalid impulse ema period %q", val)
+				}
+				config.EMA = period
+			case "macd":
+				periods := strings.Split(val, ",")
+				if len(periods) != 3 {
+					return BarColorConfig{}, fmt.Errorf("invalid impulse macd %q (want fast,slow,signal)", val)
+				}
+				values := make([]int, 3)
+				for i, period := range periods {
+					n, err := strconv.Atoi(strings.TrimSpace(period))
+					if err != nil || n < 1 {
+						return BarColorConfig{}, fmt.Errorf("invalid impulse macd %q (want fast,slow,signal)", val)
+					}
+					values[i] = n
+				}
+				config.Fast, config.Slow, config.Signal = values[0], values[1], values[2]
+			default:
+				return BarColorConfig{}, fmt.Errorf("unknown impulse parameter %q", key)
+			}
+		}
+	case "ma":
+		for key, val := range params {
+			if key != "ema" && key != "sma" {
+				return BarColorConfig{}, fmt.Errorf("unknown ma parameter %q (want ema or sma)", key)
+			}
+			period, err := strconv.Atoi(val)
+			if err != nil || period < 1 {
+				return BarColorConfig{}, fmt.Errorf("invalid ma period %q", val)
+			}
+			config.Average, config.Period = key, period
+		}
+		if len(params) != 1 {
+			return BarColorConfig{}, fmt.Errorf("bar-color ma needs exactly one of ema or sma")
+		}
+	default:
+		return BarColorConfig{}, fmt.Errorf("unknown bar-color rule %q (want impulse or ma)", config.Rule)
+	}
+	return config, nil
+}
+
+// parseContractHeader parses a contract header like ESH5(roll=2025/03/14 00:00)
+func (p *CMLParser) parseContractHeader(line string) (Contract, error) {
+	header, err := p.parseIndicator(line)
+	if err != nil {
+		return Contract{}, err
+	}
+	contract := Contract{Name: header.Name, Bars: []Bar{}}
+	for key, value := range header.Parameters {
+		if key != "roll" {
+			return Contract{}, fmt.Errorf("unknown contract parameter %q: %s", key, line)
+		}
+		roll, ok := value.(string)
+		if !ok {
+			return Contract{}, fmt.Errorf("invalid roll date: %s", line)
+		}
+		contract.Roll, err = p.ParseDateTime(roll)
+		if err != nil {
+			return Contract{}, fmt.Errorf("invalid roll date: %v", err)
+		}
+	}
+	return contract, nil
+}
+
+// parseCorporateAction parses a line like split(2024/06/10 00:00, ratio=4:1)
+// or dividend(2024/03/15 00:00, amount=0.24)
+func (p *CMLParser) parseCorporateAction(line string) (CorporateAction, error) {
+	openParen := strings.Index(line, "(")
+	if openParen == -1 || !strings.HasSuffix(line, ")") {
+		return CorporateAction{}, fmt.Errorf("invalid corporate action format: %s", line)
+	}
+	action := CorporateAction{Type: strings.TrimSpace(line[:openParen])}
+	if action.Type != "split" && action.Type != "dividend" {
+		return CorporateAction{}, fmt.Errorf("unknown corporate action %q (want split or dividend)", action.Type)
+	}
+
+	parts := strings.Split(line[openParen+1:len(line)-1], ",")
+	if len(parts) != 2 {
+		return CorporateAction{}, fmt.Errorf("invalid %s format: %s", action.Type, line)
+	}
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return CorporateAction{}, fmt.Errorf("error parsing datetime: %v", err)
+	}
+	action.DateTime = dt
+
+	key, value, _ := strings.Cut(strings.TrimSpace(parts[1]), "=")
+	switch {
+	case action.Type == "split" && key == "ratio":
+		newShares, oldShares, ok := strings.Cut(value, ":")
+		n, err1 := strconv.ParseFloat(strings.TrimSpace(newShares), 64)
+		o, err2 := strconv.ParseFloat(strings.TrimSpace(oldShares), 64)
+		if !ok || err1 != nil || err2 != nil || n <= 0 || o <= 0 {
+			return CorporateAction{}, fmt.Errorf("invalid split ratio %q (want NEW:OLD, e.g. 4:1)", value)
+		}
+		action.Ratio = n / o
+	case action.Type == "dividend" && key == "amount":
+		amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || amount <= 0 {
+			return CorporateAction{}, fmt.Errorf("invalid dividend amount %q", value)
+		}
+		action.Amount = amount
+	default:
+		return CorporateAction{}, fmt.Errorf("invalid %s format: %s", action.Type, line)
+	}
+	return action, nil
+}
+
+// parseTick parses a time-and-sales line: datetime, price, size
+func (p *CMLParser) parseTick(line string) (Tick, error) {
+	parts := strings.Split(line, ",")
+	if len(parts) != 3 {
+		return Tick{}, fmt.Errorf("invalid tick format: %s", line)
+	}
+
+	dt, err := p.ParseDateTime(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Tick{}, fmt.Errorf("error parsing datetime: %v", err)
+	}
+
+	price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return Tick{}, fmt.Errorf("error parsing tick price: %v", err)
+	}
+
+	size, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil || size < 0 {
+		return Tick{}, fmt.Errorf("invalid tick size: %s", strings.TrimSpace(parts[2]))
+	}
+
+	return Tick{DateTime: dt, Price: price, Size: size}, nil
+}
+
+// isoDateTimeLayouts are the ISO 8601 forms auto-detection accepts alongside
+// the CML format; fractional seconds are accepted after any seconds field
+var isoDateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseDateTime parses a datetime string with the chart's datetime-format
+// layout when one is set, falling back to DateTimeParser or, by default, to
+// auto-detection of the CML format YYYY/MM/DD HH:MM[:SS[.fff]] and ISO 8601
+// forms such as 2025-01-15T10:30:00Z. Times are returned in UTC.
+func (p *CMLParser) ParseDateTime(dtStr string) (time.Time, error) {
+	if p.dateTimeFormat != "" {
+		if t, err := time.Parse(p.dateTimeFormat, dtStr); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if p.DateTimeParser != nil {
+		t, err := p.DateTimeParser(dtStr)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	}
+	return p.detectDateTime(dtStr)
+}
+
+// formatDateTime writes a time in the given datetime-format layout, or in the
+// CML format with seconds and fractions only when the time has them
+func formatDateTime(t time.Time, layout string) string {
+	t = t.UTC()
+	switch {
+	case layout != "":
+		return t.Format(layout)
+	case t.Nanosecond() != 0:
+		return t.Format("2006/01/02 15:04:05.999999999")
+	case t.Second() != 0:
+		return t.Format("2006/01/02 15:04:05")
+	}
+	return t.Format("2006/01/02 15:04")
+}
+
+// startsWithDateTime reports whether the first field of a line is a datetime,
+// as it is for data rows in the series, contracts, and fan sections
+func (p *CMLParser) startsWithDateTime(line string) bool {
+	field, _, _ := strings.Cut(line, ",")
+	_, err := p.ParseDateTime(strings.TrimSpace(field))
+	return err == nil
+}
+
+// detectDateTime parses a datetime in the CML format, where the seconds may
+// have up to nine fractional digits, or in one of the ISO 8601 forms
+func (p *CMLParser) detectDateTime(dtStr string) (time.Time, error) {
+	matches := p.datetimeRegex.FindStringSubmatch(dtStr)
+	if len(matches) < 6 {
+		for _, layout := range isoDateTimeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(dtStr)); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid datetime format: %s", dtStr)
+	}
+
+	year, _ := strconv.Atoi(matches[1])
+	month, _ := strconv.Atoi(matches[2])
+	day, _ := strconv.Atoi(matches[3])
+	hour, _ := strconv.Atoi(matches[4])
+	minute, _ := strconv.Atoi(matches[5])
+
+	second := 0
+	if len(matches) > 6 && matches[6] != "" {
+		second, _ = strconv.Atoi(matches[6])
+	}
+
+	// Fractional seconds, padded to nanoseconds
+	nanosecond := 0
+	if len(matches) > 7 && matches[7] != "" {
+		nanosecond, _ = strconv.Atoi(matches[7] + strings.Repeat("0", 9-len(matches[7])))
+	}
+
+	return time.Date(year, time.Month(month), day, hour, minute, second, nanosecond, time.UTC), nil
+}
+
+// parseBarOpacityConfig parses a bar opacity configuration
+func (p *CMLParser) parseBarOpacityConfig(value string) (BarOpacityConfig, error) {
+	// Remove "bar-opacity(" and ")"
+	content := strings.TrimPrefix(value, "bar-opacity(")
+	content = strings.TrimSuffix(content, ")")
+
+	config := BarOpacityConfig{
+		Opacity: 1.0, // Default full opacity
+	}
+
+	if content == "" {
+		return config, nil
+	}
+
+	// Parse properties
+	properties := strings.Split(content, ",")
+	for _, prop := range properties {
+		prop = strings.TrimSpace(prop)
+		parts := strings.SplitN(prop, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(parts[0])
+		val := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "opacity":
+			if opacity, err := strconv.ParseFloat(val, 64); err == nil {
+				config.Opacity = opacity
+			}
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,98 @@
+package cml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ReplaceableSections are the sections ReplaceSection can swap on a parsed
+// chart. Bar data sections and settings, which can change how bars load,
+// need a full parse.
+var ReplaceableSections = []string{"meta", "drawings", "indicators", "series", "positions", "fan"}
+
+// SplitSections returns the body of each section in CML content, keyed by
+// section name. A section that appears more than once gets its bodies joined.
+func SplitSections(content string) map[string]string {
+	sections := map[string]string{}
+	current := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(trimmed, "#") {
+			current = strings.TrimSuffix(trimmed, ":")
+			if _, ok := sections[current]; !ok {
+				sections[current] = ""
+			}
+			continue
+		}
+		if current != "" {
+			sections[current] += line + "\n"
+		}
+	}
+	return sections
+}
+
+// SectionChecksums returns a SHA-256 hex digest of each section's body, so a
+// service can tell which sections changed between two versions of a chart
+func SectionChecksums(content string) map[string]string {
+	checksums := map[string]string{}
+	for name, body := range SplitSections(content) {
+		sum := sha256.Sum256([]byte(body))
+		checksums[name] = hex.EncodeToString(sum[:])
+	}
+	return checksums
+}
+
+// ReplaceSection parses body as the named section and swaps it into an
+// already-parsed chart, leaving the bars and every other section untouched.
+// Replacing drawings keeps the markers and notes from bar annotation flags
+// and re-runs the registered annotators. On error the chart is unchanged.
+func (p *CMLParser) ReplaceSection(chart *Chart, name, body string) error {
+	replaceable := false
+	for _, section := range ReplaceableSections {
+		replaceable = replaceable || section == name
+	}
+	if !replaceable {
+		return fmt.Errorf("section %q cannot be replaced; parse the whole chart instead", name)
+	}
+
+	// Datetimes in the new section are read in the chart's own layout
+	session := *p
+	session.dateTimeFormat = chart.GetDateTimeFormat()
+	session.headerLines = 1
+
+	parsed := newChart()
+	if err := session.parseLines(parsed, append([]string{name + ":"}, strings.Split(body, "\n")...)); err != nil {
+		return err
+	}
+
+	updated := *chart
+	switch name {
+	case "meta":
+		updated.Meta = parsed.Meta
+	case "drawings":
+		updated.Drawings = append(append([]Drawing(nil), chart.barDrawings...), parsed.Drawings...)
+	case "indicators":
+		updated.Indicators = parsed.Indicators
+	case "series":
+		updated.Series = parsed.Series
+	case "positions":
+		updated.Positions = parsed.Positions
+	case "fan":
+		updated.Fan = parsed.Fan
+	}
+	if err := validateReferences(&updated); err != nil {
+		return err
+	}
+	if name == "drawings" {
+		applyAnnotators(&updated)
+	}
+	if err := p.checkCountLimits(&updated); err != nil {
+		return err
+	}
+
+	updated.Warnings = append(append([]string(nil), chart.Warnings...), parsed.Warnings...)
+	*chart = updated
+	return nil
+}
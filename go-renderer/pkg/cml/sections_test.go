@@ -0,0 +1,48 @@
+package cml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceSectionKeepsBarAnnotations(t *testing.T) {
+	content := `bars:
+    2025/01/01 00:00, 10, 12, 9, 11 !buy "in"
+    2025/01/02 00:00, 11, 13, 10, 12
+drawings:
+    line(2025/01/01 00:00, 10 ; 2025/01/02 00:00, 13)
+`
+	parser := NewCMLParser()
+	chart, err := parser.ParseReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := drawingTypes(chart.Drawings); got != "triangle,line" {
+		t.Fatalf("parsed drawings %s, want triangle,line", got)
+	}
+
+	body := "    rectangle(2025/01/01 00:00, 9 ; 2025/01/02 00:00, 13)\n"
+	if err := parser.ReplaceSection(chart, "drawings", body); err != nil {
+		t.Fatal(err)
+	}
+	if got := drawingTypes(chart.Drawings); got != "triangle,rectangle" {
+		t.Errorf("drawings after replacing %s, want triangle,rectangle", got)
+	}
+
+	// Replacing again keeps the marker once
+	if err := parser.ReplaceSection(chart, "drawings", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := drawingTypes(chart.Drawings); got != "triangle" {
+		t.Errorf("drawings after clearing %s, want triangle", got)
+	}
+}
+
+// drawingTypes lists the types of the drawings, comma-separated
+func drawingTypes(drawings []Drawing) string {
+	types := make([]string, len(drawings))
+	for i, drawing := range drawings {
+		types[i] = drawing.GetType()
+	}
+	return strings.Join(types, ",")
+}
@@ -0,0 +1,52 @@
+package cml
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are available to CML templates in addition to the text/template
+// builtins, for deriving levels from the data (e.g. {{add .entry 0.5}})
+var TemplateFuncs = template.FuncMap{
+	"add": func(a, b float64) float64 { return a + b },
+	"sub": func(a, b float64) float64 { return a - b },
+	"mul": func(a, b float64) float64 { return a * b },
+	"div": func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// LoadTemplateData reads a JSON file to execute CML templates with
+func LoadTemplateData(path string) (interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return data, nil
+}
+
+// expandTemplate runs CML content through text/template with the given data.
+// Referencing a key missing from the data is an error rather than "<no value>".
+func expandTemplate(content string, data interface{}) (string, error) {
+	tmpl, err := template.New("cml").Funcs(TemplateFuncs).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var expanded strings.Builder
+	if err := tmpl.Execute(&expanded, data); err != nil {
+		return "", err
+	}
+	return expanded.String(), nil
+}
@@ -0,0 +1,121 @@
+package cml
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Theme is a chart's color scheme, as hex colors like those in styles.
+// Settings such as frame-color, wick-color, and the grid's color override
+// the theme.
+type Theme struct {
+	Name       string `json:"-"`          // Built-in theme name, or the path of the theme file
+	Background string `json:"background"` // Canvas
+	Axis       string `json:"axis"`       // Plot area frame
+	Grid       string `json:"grid"`       // Grid lines
+	Up         string `json:"up"`         // Bars closing at or above their open
+	Down       string `json:"down"`       // Bars closing below their open
+	Wick       string `json:"wick"`       // Candle wicks and outlines
+	Text       string `json:"text"`       // Title, axis labels, and notes and labels without a font-color
+}
+
+// Themes are the built-in themes by name; light is the default
+var Themes = map[string]Theme{
+	"light": {
+		Name:       "light",
+		Background: "#ffffff",
+		Axis:       "#000000",
+		Grid:       "#000000",
+		Up:         "#009600",
+		Down:       "#c80000",
+		Wick:       "#000000",
+		Text:       "#000000",
+	},
+	"dark": {
+		Name:       "dark",
+		Background: "#131722",
+		Axis:       "#787b86",
+		Grid:       "#2a2e39",
+		Up:         "#26a69a",
+		Down:       "#ef5350",
+		Wick:       "#b2b5be",
+		Text:       "#d1d4dc",
+	},
+}
+
+// LoadTheme reads a custom theme from a JSON file with the color fields of
+// Theme. Its "base" names the built-in theme it starts from, light unless
+// given, so the file only needs the colors it changes.
+func LoadTheme(path string) (Theme, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, err
+	}
+
+	var base struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(content, &base); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %v", path, err)
+	}
+	if base.Base == "" {
+		base.Base = "light"
+	}
+	theme, ok := Themes[base.Base]
+	if !ok {
+		return Theme{}, fmt.Errorf("invalid theme %s: unknown base theme %q", path, base.Base)
+	}
+
+	if err := json.Unmarshal(content, &theme); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %v", path, err)
+	}
+	for name, value := range theme.colors() {
+		if !isHexColor(value) {
+			return Theme{}, fmt.Errorf("invalid theme %s: invalid %s color %q", path, name, value)
+		}
+	}
+	theme.Name = path
+	return theme, nil
+}
+
+// colors returns the theme's colors by their JSON names
+func (t Theme) colors() map[string]string {
+	return map[string]string{
+		"background": t.Background,
+		"axis":       t.Axis,
+		"grid":       t.Grid,
+		"up":         t.Up,
+		"down":       t.Down,
+		"wick":       t.Wick,
+		"text":       t.Text,
+	}
+}
+
+// parseTheme parses a theme setting: a built-in theme name, or the quoted path
+// of a theme file, which is read once the whole chart is parsed
+func parseTheme(value string) (SettingsEntry, error) {
+	if theme, ok := Themes[value]; ok {
+		return SettingsEntry{Key: "theme", Value: theme}, nil
+	}
+	path := strings.Trim(value, `"`)
+	if path == "" || (path == value && !strings.HasSuffix(path, ".json")) {
+		return SettingsEntry{}, fmt.Errorf("invalid theme %q (want light, dark, or the quoted path of a theme file)", value)
+	}
+	return SettingsEntry{Key: "theme", Value: Theme{Name: path}}, nil
+}
+
+// GetTheme returns the chart's theme, or the light theme when it has none.
+// A theme file that hasn't been read, as in a chart read from JSON, also
+// gives the light theme.
+func (c *Chart) GetTheme() Theme {
+	for _, entry := range c.Settings {
+		if entry.Key == "theme" {
+			if theme, ok := entry.Value.(Theme); ok && theme.Background != "" {
+				return theme
+			}
+		}
+	}
+	return Themes["light"]
+}
@@ -0,0 +1,29 @@
+package cml
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// aggregateTicks builds one bar per interval that has trades, in time order,
+// with the trade sizes summed into its volume
+func aggregateTicks(ticks []Tick, interval time.Duration) []Bar {
+	sorted := append([]Tick(nil), ticks...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].DateTime.Before(sorted[j].DateTime) })
+
+	var bars []Bar
+	for _, tick := range sorted {
+		start := tick.DateTime.Truncate(interval)
+		if n := len(bars); n > 0 && bars[n-1].DateTime.Equal(start) {
+			bar := &bars[n-1]
+			bar.High = math.Max(bar.High, tick.Price)
+			bar.Low = math.Min(bar.Low, tick.Price)
+			bar.Close = tick.Price
+			bar.Volume += tick.Size
+			continue
+		}
+		bars = append(bars, Bar{DateTime: start, Open: tick.Price, High: tick.Price, Low: tick.Price, Close: tick.Price, Volume: tick.Size})
+	}
+	return bars
+}
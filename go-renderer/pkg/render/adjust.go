@@ -0,0 +1,129 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Corporate action marker colors
+var (
+	splitMarkerColor    = color.RGBA{123, 31, 162, 255}
+	dividendMarkerColor = color.RGBA{0, 121, 107, 255}
+)
+
+// AdjustChart returns a copy of the chart with prices before each corporate
+// action back-adjusted for it, as chosen by the adjust setting, or the chart
+// itself when nothing is adjusted. A split of N:M divides earlier prices by
+// N/M and multiplies earlier volumes by it; a dividend scales prices by
+// 1 - amount / the close before its date and leaves volumes alone.
+func AdjustChart(chart *cml.Chart) *cml.Chart {
+	adjustment := chart.GetAdjustment()
+	if adjustment == "none" || len(chart.CorporateActions) == 0 {
+		return chart
+	}
+
+	actions := append([]cml.CorporateAction(nil), chart.CorporateActions...)
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].DateTime.Before(actions[j].DateTime) })
+
+	factors := make([]float64, len(actions))
+	volumeFactors := make([]float64, len(actions))
+	for i, action := range actions {
+		factors[i], volumeFactors[i] = 1, 1
+		switch {
+		case action.Type == "split":
+			factors[i] = 1 / action.Ratio
+			volumeFactors[i] = action.Ratio
+		case action.Type == "dividend" && adjustment == "all":
+			if previous, ok := closeBefore(chart.Bars, action.DateTime); ok && action.Amount < previous {
+				factors[i] = 1 - action.Amount/previous
+			}
+		}
+	}
+
+	// Prices from each action's date on carry the factors of later actions,
+	// and prices before the first action carry them all
+	rates := make([]cml.SeriesPoint, len(actions)+1)
+	volumeRates := make([]cml.SeriesPoint, len(actions)+1)
+	cumulative, cumulativeVolume := 1.0, 1.0
+	for i := len(actions) - 1; i >= 0; i-- {
+		rates[i+1] = cml.SeriesPoint{DateTime: actions[i].DateTime, Value: cumulative}
+		volumeRates[i+1] = cml.SeriesPoint{DateTime: actions[i].DateTime, Value: cumulativeVolume}
+		cumulative *= factors[i]
+		cumulativeVolume *= volumeFactors[i]
+	}
+	rates[0] = cml.SeriesPoint{Value: cumulative}
+	volumeRates[0] = cml.SeriesPoint{Value: cumulativeVolume}
+
+	return priceConverter{rates: rates, volumeRates: volumeRates}.chart(chart)
+}
+
+// closeBefore returns the close of the last bar before a time
+func closeBefore(bars []cml.Bar, t time.Time) (float64, bool) {
+	found, close := false, 0.0
+	latest := time.Time{}
+	for _, bar := range bars {
+		if bar.DateTime.Before(t) && (!found || bar.DateTime.After(latest)) {
+			found, close, latest = true, bar.Close, bar.DateTime
+		}
+	}
+	return close, found
+}
+
+// renderActionMarkers marks each corporate action in the visible range with a
+// lettered badge at the bottom of the plot, "S" for splits and "D" for
+// dividends, labeled with the ratio or amount
+func (r *CMLRenderer) renderActionMarkers(actions []cml.CorporateAction) {
+	if !r.chart.GetActionMarkers() || len(r.bars) == 0 {
+		return
+	}
+
+	for _, action := range actions {
+		letter, label, badgeColor := "D", fmt.Sprintf("%g", action.Amount), dividendMarkerColor
+		if action.Type == "split" {
+			letter, label, badgeColor = "S", splitRatioLabel(action.Ratio), splitMarkerColor
+		}
+		r.drawEventBadge(action.DateTime, letter, label, badgeColor, ImageRegion{
+			Kind:  "corporate-action",
+			ID:    "action-" + elementTime(action.DateTime),
+			Class: "action action-" + action.Type,
+			Label: action.Type + " " + label,
+		})
+	}
+}
+
+// drawEventBadge draws a lettered badge for an event at the bottom of the
+// plot with a label above it, recording it as the given region; events
+// outside the visible range are skipped
+func (r *CMLRenderer) drawEventBadge(t time.Time, letter, label string, badgeColor color.Color, region ImageRegion) {
+	l := r.layout
+	x := l.X(t)
+	if x < l.Left || x > l.Right {
+		return
+	}
+	const radius = 7.0
+	y := l.Bottom - radius - 3
+
+	r.beginRegion()
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	r.dc.SetColor(badgeColor)
+	r.dc.DrawCircle(x, y, radius)
+	r.dc.Fill()
+	r.dc.SetColor(color.White)
+	r.dc.DrawStringAnchored(letter, x, y, 0.5, 0.35)
+	r.dc.SetColor(badgeColor)
+	r.dc.DrawStringAnchored(label, x, y-radius-2, 0.5, 0)
+	r.markRegion(x, y, radius)
+	r.endRegion(region, false)
+}
+
+// splitRatioLabel formats a split ratio as NEW:OLD, e.g. 4:1 or 1:10
+func splitRatioLabel(ratio float64) string {
+	if ratio < 1 {
+		return fmt.Sprintf("1:%g", 1/ratio)
+	}
+	return fmt.Sprintf("%g:1", ratio)
+}
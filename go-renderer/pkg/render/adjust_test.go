@@ -0,0 +1,35 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+func TestAdjustChartScalesVolumeForSplitsOnly(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC) }
+	chart := &cml.Chart{
+		Settings: []cml.SettingsEntry{{Key: "adjust", Value: "all"}},
+		CorporateActions: []cml.CorporateAction{
+			{Type: "dividend", DateTime: day(4), Amount: 10},
+			{Type: "split", DateTime: day(5), Ratio: 4},
+		},
+	}
+	for d := 3; d <= 5; d++ {
+		chart.Bars = append(chart.Bars, cml.Bar{DateTime: day(d), Open: 100, High: 100, Low: 100, Close: 100, Volume: 1000})
+	}
+
+	adjusted := AdjustChart(chart)
+	// The split divides earlier prices by 4 and the dividend scales those
+	// before it by 0.9, while only the split multiplies earlier volume
+	want := []struct{ close, volume float64 }{{22.5, 4000}, {25, 4000}, {100, 1000}}
+	for i, bar := range adjusted.Bars {
+		if bar.Close != want[i].close || bar.Volume != want[i].volume {
+			t.Errorf("bar %d: close %v, volume %v, want %v, %v", i, bar.Close, bar.Volume, want[i].close, want[i].volume)
+		}
+	}
+	if chart.Bars[0].Volume != 1000 {
+		t.Errorf("original volume changed to %v", chart.Bars[0].Volume)
+	}
+}
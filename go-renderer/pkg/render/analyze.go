@@ -0,0 +1,217 @@
+package render
+
+import (
+	"math"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// ChartStats holds summary statistics for a chart, computed from the same
+// data and indicator math the renderer draws
+type ChartStats struct {
+	Bars        int       `json:"bars"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	FirstOpen   float64   `json:"first_open"`
+	LastClose   float64   `json:"last_close"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Return      float64   `json:"return"`       // Fractional change from the first open to the last close
+	Volatility  float64   `json:"volatility"`   // Standard deviation of bar-to-bar close returns
+	MaxDrawdown float64   `json:"max_drawdown"` // Largest peak-to-trough decline in closes, as a fraction of the peak
+
+	Indicators []IndicatorStats `json:"indicators,omitempty"`
+	Series     []SeriesStats    `json:"series,omitempty"`
+}
+
+// IndicatorStats holds the final values of one indicator; banded indicators
+// report one value per band
+type IndicatorStats struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Last       map[string]float64     `json:"last"`
+}
+
+// SeriesStats holds the final value of one user-supplied series
+type SeriesStats struct {
+	Name string  `json:"name"`
+	Last float64 `json:"last"`
+}
+
+// Analyze computes summary statistics for a chart. Indicators that need more
+// bars than the chart has, and those not drawn on the price scale, are omitted.
+func Analyze(chart *cml.Chart) ChartStats {
+	stats := ChartStats{Bars: len(chart.Bars)}
+	bars := chart.Bars
+	if len(bars) > 0 {
+		first, last := bars[0], bars[len(bars)-1]
+		stats.Start = first.DateTime
+		stats.End = last.DateTime
+		stats.FirstOpen = first.Open
+		stats.LastClose = last.Close
+		stats.High = first.High
+		stats.Low = first.Low
+		if first.Open != 0 {
+			stats.Return = (last.Close - first.Open) / first.Open
+		}
+
+		peak := first.Close
+		var returns []float64
+		for i, bar := range bars {
+			stats.High = math.Max(stats.High, bar.High)
+			stats.Low = math.Min(stats.Low, bar.Low)
+
+			if i > 0 && bars[i-1].Close != 0 {
+				returns = append(returns, bar.Close/bars[i-1].Close-1)
+			}
+
+			peak = math.Max(peak, bar.Close)
+			if peak > 0 {
+				stats.MaxDrawdown = math.Max(stats.MaxDrawdown, (peak-bar.Close)/peak)
+			}
+		}
+		stats.Volatility = stdDev(returns)
+	}
+
+	for _, indicator := range chart.Indicators {
+		if last := lastIndicatorValues(bars, indicator); last != nil {
+			stats.Indicators = append(stats.Indicators, IndicatorStats{
+				Name:       indicator.Name,
+				Parameters: indicator.Parameters,
+				Last:       last,
+			})
+		}
+	}
+
+	for _, series := range chart.Series {
+		if len(series.Points) > 0 {
+			stats.Series = append(stats.Series, SeriesStats{
+				Name: series.Name,
+				Last: series.Points[len(series.Points)-1].Value,
+			})
+		}
+	}
+
+	return stats
+}
+
+// lastIndicatorValues returns the final values of an indicator, or nil if it
+// cannot be computed
+func lastIndicatorValues(bars []cml.Bar, indicator cml.Indicator) map[string]float64 {
+	if indicator.Name == "seasonal" {
+		seasonal := computeSeasonal(bars, cml.IndicatorSource(indicator.Parameters), seasonalPeriod(indicator.Parameters))
+		if seasonal == nil {
+			return nil
+		}
+		return map[string]float64{"value": seasonal[len(seasonal)-1]}
+	}
+
+	if indicator.Name == "rsi" {
+		period := cml.RSIParams(indicator.Parameters)
+		if len(bars) <= period {
+			return nil
+		}
+		return map[string]float64{"value": computeRSI(bars, period)[len(bars)-1]}
+	}
+
+	if indicator.Name == "macd" {
+		fast, slow, signal := cml.MACDParams(indicator.Parameters)
+		if len(bars) < slow {
+			return nil
+		}
+		macd, signalLine, histogram := computeMACD(bars, fast, slow, signal)
+		n := len(bars) - 1
+		return map[string]float64{"macd": macd[n], "signal": signalLine[n], "histogram": histogram[n]}
+	}
+
+	if indicator.Name == "chandelier" {
+		period, multiplier, _ := cml.ChandelierParams(indicator.Parameters)
+		if len(bars) < period {
+			return nil
+		}
+		long, short := computeChandelier(bars, period, multiplier)
+		return map[string]float64{"long": long[len(bars)-1], "short": short[len(bars)-1]}
+	}
+
+	period, ok := indicator.Parameters["period"].(float64)
+	if !ok || period < 1 || len(bars) < int(period) {
+		return nil
+	}
+	n := len(bars) - 1
+
+	switch indicator.Name {
+	case "ema":
+		return map[string]float64{"value": computeEMA(bars, int(period))[n]}
+	case "sma":
+		return map[string]float64{"value": computeSMA(bars, int(period))[n]}
+	case "bollinger":
+		stddev, ok := indicator.Parameters["stddev"].(float64)
+		if !ok {
+			return nil
+		}
+		upper, middle, lower := computeBollinger(bars, int(period), stddev)
+		return map[string]float64{"upper": upper[n], "middle": middle[n], "lower": lower[n]}
+	case "hhll":
+		highest, lowest := computeHighLow(bars, int(period))
+		return map[string]float64{"high": highest[n], "low": lowest[n]}
+	}
+	return nil
+}
+
+// stdDev returns the population standard deviation of values
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// DailyReturn is the fractional close-to-close change of one calendar day
+type DailyReturn struct {
+	Date   time.Time // Midnight at the start of the day, in the bars' time zone
+	Return float64
+}
+
+// dailyReturns groups bars by calendar day and returns each day's change from
+// the previous day's last close; the first day is measured from its first open
+func dailyReturns(bars []cml.Bar) []DailyReturn {
+	var days []DailyReturn
+	previous := 0.0
+	if len(bars) > 0 {
+		previous = bars[0].Open
+	}
+
+	for i, bar := range bars {
+		// Only the last bar of each day closes it
+		if i+1 < len(bars) && sameDay(bar.DateTime, bars[i+1].DateTime) {
+			continue
+		}
+		year, month, day := bar.DateTime.Date()
+		daily := DailyReturn{Date: time.Date(year, month, day, 0, 0, 0, 0, bar.DateTime.Location())}
+		if previous != 0 {
+			daily.Return = bar.Close/previous - 1
+		}
+		days = append(days, daily)
+		previous = bar.Close
+	}
+	return days
+}
+
+// sameDay reports whether two times fall on the same calendar day
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
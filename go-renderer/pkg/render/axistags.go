@@ -0,0 +1,72 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// axisTag is a price level shown as a colored tag on the Y axis
+type axisTag struct {
+	price float64
+	color color.Color
+}
+
+// addAxisTag queues a tag for a drawing with the axis-tag style. Tags are
+// drawn with the axes so they sit on top of the axis labels.
+func (r *CMLRenderer) addAxisTag(styles map[string]interface{}, price float64, tagColor color.Color) {
+	if r.getStyleString(styles, "axis-tag", "false") != "true" {
+		return
+	}
+	r.axisTags = append(r.axisTags, axisTag{price: price, color: tagColor})
+}
+
+// drawAxisTags draws each queued tag as a label pointing at its level on the
+// left edge of the plot area, or the right edge when the price axis is there
+func (r *CMLRenderer) drawAxisTags() {
+	l := r.layout
+	formatStr := fmt.Sprintf("%%.%df", r.chart.GetYAxisConfig().Precision)
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+
+	// Tags grow away from the edge they point at: leftward from the left edge
+	edge, side := l.Left, -1.0
+	if r.chart.GetYAxisPosition() == "right" {
+		edge, side = l.Right, 1.0
+	}
+
+	for _, tag := range r.axisTags {
+		y := l.Y(tag.price)
+		if y < l.Top || y > l.Bottom {
+			continue
+		}
+
+		text := fmt.Sprintf(formatStr, tag.price)
+		w, h := r.dc.MeasureString(text)
+		pad := 3.0
+		point := h/2 + pad
+		outer := edge + side*(point+w+pad*2)
+
+		// A box with a point touching the plot edge
+		r.dc.SetColor(tag.color)
+		r.dc.MoveTo(outer, y-h/2-pad)
+		r.dc.LineTo(edge+side*point, y-h/2-pad)
+		r.dc.LineTo(edge, y)
+		r.dc.LineTo(edge+side*point, y+h/2+pad)
+		r.dc.LineTo(outer, y+h/2+pad)
+		r.dc.ClosePath()
+		r.dc.Fill()
+
+		r.dc.SetColor(tagTextColor(tag.color))
+		r.dc.DrawStringAnchored(text, math.Min(outer, edge+side*point)+pad, y, 0, 0.35)
+	}
+}
+
+// tagTextColor picks black or white text, whichever reads better on the background
+func tagTextColor(background color.Color) color.Color {
+	red, green, blue, _ := background.RGBA()
+	luminance := (0.299*float64(red) + 0.587*float64(green) + 0.114*float64(blue)) / 0xffff
+	if luminance > 0.5 {
+		return color.Black
+	}
+	return color.White
+}
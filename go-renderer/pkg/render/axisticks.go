@@ -0,0 +1,180 @@
+package render
+
+import (
+	"strconv"
+	"time"
+)
+
+// TickGenerator picks the tick positions of the chart's axes: nicely rounded
+// times along the time axis, and evenly spaced values along the price axis
+// and the subpanels' value axes. The layout computes the ticks once per
+// render, and the grid, the axis labels, and the subpanels all draw from
+// them, so gridlines and labels always line up.
+type TickGenerator struct {
+	MaxTimeTicks   int // Most ticks on the time axis
+	ValueDivisions int // Equal intervals a value axis is divided into, with a tick at each end of each
+}
+
+// Tick generators for the price plot and the subpanels
+var (
+	priceTicks = TickGenerator{MaxTimeTicks: 8, ValueDivisions: 5}
+	panelTicks = TickGenerator{MaxTimeTicks: 8, ValueDivisions: 2}
+)
+
+// ValueTicks returns ValueDivisions+1 evenly spaced values from minValue to
+// maxValue, bottom to top
+func (g TickGenerator) ValueTicks(minValue, maxValue float64) []float64 {
+	if g.ValueDivisions < 1 {
+		return nil
+	}
+	ticks := make([]float64, g.ValueDivisions+1)
+	for i := range ticks {
+		ticks[i] = minValue + (maxValue-minValue)*(float64(i)/float64(g.ValueDivisions))
+	}
+	return ticks
+}
+
+// TimeTicks picks up to MaxTimeTicks evenly spaced, nicely rounded tick
+// times in [minTime, maxTime] for numBars bars spread over that range
+func (g TickGenerator) TimeTicks(minTime, maxTime time.Time, numBars int) []time.Time {
+	timeRange := maxTime.Sub(minTime)
+	if timeRange <= 0 {
+		return nil
+	}
+
+	// Calculate target number of ticks
+	targetTicks := min(6, g.MaxTimeTicks)
+	if numBars < 10 {
+		targetTicks = min(numBars, g.MaxTimeTicks)
+	}
+	if targetTicks < 1 {
+		return nil
+	}
+
+	// Calculate interval to get approximately targetTicks
+	interval := timeRange / time.Duration(targetTicks)
+
+	// Round to nice intervals based on data frequency
+	if timeRange <= 24*time.Hour {
+		// Intraday data
+		if interval <= 5*time.Minute {
+			interval = shortTickInterval(interval)
+		} else if interval <= 15*time.Minute {
+			interval = 15 * time.Minute
+		} else if interval <= 30*time.Minute {
+			interval = 30 * time.Minute
+		} else if interval <= 1*time.Hour {
+			interval = 1 * time.Hour
+		} else if interval <= 2*time.Hour {
+			interval = 2 * time.Hour
+		} else if interval <= 6*time.Hour {
+			interval = 6 * time.Hour
+		} else {
+			interval = 12 * time.Hour
+		}
+	} else if timeRange <= 7*24*time.Hour {
+		// Weekly data
+		interval = 24 * time.Hour // Daily
+	} else if timeRange <= 30*24*time.Hour {
+		// Monthly data
+		interval = 7 * 24 * time.Hour // Weekly
+	} else if timeRange <= 90*24*time.Hour {
+		// Quarterly data
+		interval = 14 * 24 * time.Hour // Bi-weekly
+	} else {
+		// Longer periods
+		interval = 30 * 24 * time.Hour // Monthly
+	}
+
+	// Find the first nice time that's >= minTime
+	startTime := minTime.Truncate(interval)
+	if startTime.Before(minTime) {
+		startTime = startTime.Add(interval)
+	}
+
+	var ticks []time.Time
+	for t := startTime; !t.After(maxTime) && len(ticks) < g.MaxTimeTicks; t = t.Add(interval) {
+		ticks = append(ticks, t)
+	}
+	return ticks
+}
+
+// shortTickIntervals are the tick spacings for charts spanning less than
+// about half an hour, down to the sub-second bars of aggregated ticks
+var shortTickIntervals = []time.Duration{
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute,
+}
+
+// shortTickInterval rounds a tick spacing of at most five minutes up to the
+// nearest short tick interval
+func shortTickInterval(interval time.Duration) time.Duration {
+	for _, nice := range shortTickIntervals {
+		if interval <= nice {
+			return nice
+		}
+	}
+	return 5 * time.Minute
+}
+
+// maxLabelPrecision is the most decimals valueLabels adds to tell ticks apart
+const maxLabelPrecision = 8
+
+// valueLabels formats value ticks with at least precision decimals, adding
+// decimals, up to maxLabelPrecision, until neighboring ticks no longer share
+// a label, as on a narrow 1.25-1.26 axis at the default two decimals
+func valueLabels(ticks []float64, precision int) []string {
+	labels := formatValueLabels(ticks, precision)
+	for ; precision < maxLabelPrecision && !distinctLabels(ticks, labels); precision++ {
+		labels = formatValueLabels(ticks, precision+1)
+	}
+	return labels
+}
+
+// distinctLabels reports whether neighboring ticks of different values all
+// have different labels
+func distinctLabels(ticks []float64, labels []string) bool {
+	for i := 1; i < len(labels); i++ {
+		if labels[i] == labels[i-1] && ticks[i] != ticks[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// formatValueLabels formats values with the given number of decimals, like
+// %.Nf, appending them all to one buffer so a whole axis of labels costs a
+// single string allocation
+func formatValueLabels(values []float64, precision int) []string {
+	buf := make([]byte, 0, len(values)*(precision+8))
+	ends := make([]int, len(values))
+	for i, value := range values {
+		buf = strconv.AppendFloat(buf, value, 'f', precision, 64)
+		ends[i] = len(buf)
+	}
+	return splitLabels(string(buf), ends)
+}
+
+// formatTimeLabels formats tick times with a time layout, sharing one buffer
+// the way formatValueLabels does
+func formatTimeLabels(ticks []time.Time, layout string) []string {
+	buf := make([]byte, 0, len(ticks)*len(layout))
+	ends := make([]int, len(ticks))
+	for i, t := range ticks {
+		buf = t.AppendFormat(buf, layout)
+		ends[i] = len(buf)
+	}
+	return splitLabels(string(buf), ends)
+}
+
+// splitLabels cuts labels out of their joined text at the given end offsets
+func splitLabels(joined string, ends []int) []string {
+	labels := make([]string, len(ends))
+	start := 0
+	for i, end := range ends {
+		labels[i] = joined[start:end]
+		start = end
+	}
+	return labels
+}
@@ -0,0 +1,120 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeTicks(t *testing.T) {
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		span     time.Duration
+		numBars  int
+		interval time.Duration
+		first    time.Time
+	}{
+		{"sub-second", 2 * time.Second, 20, 500 * time.Millisecond, start},
+		{"minutes", 30 * time.Minute, 30, 5 * time.Minute, start},
+		{"quarter hours", 90 * time.Minute, 18, 15 * time.Minute, start},
+		{"hours", 8 * time.Hour, 32, 2 * time.Hour, start},
+		{"whole day", 24 * time.Hour, 24, 6 * time.Hour, start.Add(2 * time.Hour)},
+		{"days", 5 * 24 * time.Hour, 5, 24 * time.Hour, time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"weeks", 60 * 24 * time.Hour, 60, 14 * 24 * time.Hour, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end := start.Add(tt.span)
+			ticks := priceTicks.TimeTicks(start, end, tt.numBars)
+			if len(ticks) < 2 || len(ticks) > priceTicks.MaxTimeTicks {
+				t.Fatalf("got %d ticks, want 2 to %d", len(ticks), priceTicks.MaxTimeTicks)
+			}
+			if !ticks[0].Equal(tt.first) {
+				t.Errorf("first tick %v, want %v", ticks[0], tt.first)
+			}
+			for i, tick := range ticks {
+				if tick.Before(start) || tick.After(end) {
+					t.Errorf("tick %v outside %v to %v", tick, start, end)
+				}
+				if !tick.Truncate(tt.interval).Equal(tick) {
+					t.Errorf("tick %v not on a multiple of %v", tick, tt.interval)
+				}
+				if i > 0 && tick.Sub(ticks[i-1]) != tt.interval {
+					t.Errorf("ticks %v apart, want %v", tick.Sub(ticks[i-1]), tt.interval)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeTicksEmptyRange(t *testing.T) {
+	start := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	if ticks := priceTicks.TimeTicks(start, start, 1); ticks != nil {
+		t.Errorf("got %v for an empty range, want none", ticks)
+	}
+	if ticks := priceTicks.TimeTicks(start, start.Add(time.Hour), 0); ticks != nil {
+		t.Errorf("got %v for no bars, want none", ticks)
+	}
+}
+
+func TestShortTickInterval(t *testing.T) {
+	tests := []struct {
+		interval, want time.Duration
+	}{
+		{50 * time.Millisecond, 100 * time.Millisecond},
+		{333 * time.Millisecond, 500 * time.Millisecond},
+		{time.Second, time.Second},
+		{12 * time.Second, 15 * time.Second},
+		{90 * time.Second, 2 * time.Minute},
+		{5 * time.Minute, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := shortTickInterval(tt.interval); got != tt.want {
+			t.Errorf("shortTickInterval(%v) = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestValueTicks(t *testing.T) {
+	ticks := priceTicks.ValueTicks(100, 150)
+	want := []float64{100, 110, 120, 130, 140, 150}
+	if !reflect.DeepEqual(ticks, want) {
+		t.Errorf("got %v, want %v", ticks, want)
+	}
+
+	if ticks := panelTicks.ValueTicks(0, 100); !reflect.DeepEqual(ticks, []float64{0, 50, 100}) {
+		t.Errorf("panel ticks %v, want [0 50 100]", ticks)
+	}
+	if ticks := (TickGenerator{}).ValueTicks(0, 100); ticks != nil {
+		t.Errorf("got %v with no divisions, want none", ticks)
+	}
+}
+
+func TestValueLabelsNarrowRange(t *testing.T) {
+	// A 1.25-1.26 axis, padded like the layout pads it: two decimals would
+	// label three ticks 1.25 and three 1.26
+	ticks := priceTicks.ValueTicks(1.2495, 1.2605)
+	labels := valueLabels(ticks, 2)
+	want := []string{"1.250", "1.252", "1.254", "1.256", "1.258", "1.260"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+}
+
+func TestValueLabelsKeepPrecision(t *testing.T) {
+	labels := valueLabels(priceTicks.ValueTicks(100, 150), 2)
+	want := []string{"100.00", "110.00", "120.00", "130.00", "140.00", "150.00"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+}
+
+func TestValueLabelsFlatRange(t *testing.T) {
+	// Ticks of the same value share a label without extra decimals
+	labels := valueLabels([]float64{5, 5, 5}, 2)
+	if want := []string{"5.00", "5.00", "5.00"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("got %v, want %v", labels, want)
+	}
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Candle colors for the bar-color rules
+var (
+	barRuleUpColor      = color.RGBA{0, 150, 0, 255}
+	barRuleDownColor    = color.RGBA{200, 0, 0, 255}
+	barRuleNeutralColor = color.RGBA{30, 90, 200, 255}
+)
+
+// barRuleColors returns each bar's color under the chart's bar-color rule, or
+// nil without one. Bars the rule can't color yet, like those before a moving
+// average has enough history, get the zero color and keep their default.
+func (r *CMLRenderer) barRuleColors(bars []cml.Bar) []color.RGBA {
+	config, ok := r.chart.GetBarColorConfig()
+	if !ok {
+		return nil
+	}
+
+	colors := make([]color.RGBA, len(bars))
+	switch config.Rule {
+	case "impulse":
+		for i, state := range computeImpulse(bars, config.EMA, config.Fast, config.Slow, config.Signal) {
+			switch state {
+			case 1:
+				colors[i] = barRuleUpColor
+			case -1:
+				colors[i] = barRuleDownColor
+			default:
+				colors[i] = barRuleNeutralColor
+			}
+		}
+	case "ma":
+		average := computeEMA(bars, config.Period)
+		first := 0
+		if config.Average == "sma" {
+			average = computeSMA(bars, config.Period)
+			first = config.Period - 1
+		}
+		for i := first; i < len(bars); i++ {
+			if bars[i].Close >= average[i] {
+				colors[i] = barRuleUpColor
+			} else {
+				colors[i] = barRuleDownColor
+			}
+		}
+	}
+	return colors
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Calendar heatmap geometry, in pixels
+const (
+	calendarMargin    = 20.0
+	calendarLabelSide = 40.0 // Room for weekday and year labels left of the grid
+	calendarCellGap   = 2.0
+	calendarTop       = 40.0 // Room for the title
+	calendarWeeks     = 53   // Week columns a year can touch
+)
+
+// Colors for days without bars and the ends of the return scale
+var (
+	calendarEmptyColor = color.RGBA{235, 237, 240, 255}
+	calendarDownColor  = color.RGBA{215, 48, 39, 255}
+	calendarUpColor    = color.RGBA{26, 152, 80, 255}
+)
+
+// RenderCalendar renders the chart's daily returns as a calendar heatmap: one
+// block per year with a column per week and a row per weekday, each day shaded
+// from red (down) through white to green (up) relative to the largest move
+func (r *CMLRenderer) RenderCalendar(chart *cml.Chart, outputFile string) error {
+	r.chart = chart
+	r.dc.SetColor(color.White)
+	r.dc.Clear()
+	r.drawCalendar(chart)
+
+	options := chart.GetPNGOptions()
+	if r.PNGOptions != nil {
+		options = *r.PNGOptions
+	}
+	return savePNG(outputFile, r.dc.Image(), options)
+}
+
+// drawCalendar draws the title, year blocks, and legend of a calendar heatmap
+func (r *CMLRenderer) drawCalendar(chart *cml.Chart) {
+	title := r.getMetaValue(chart.Meta, "title")
+	if title == "" {
+		title = "Daily returns"
+	}
+	sizes := calendarFontSizes(chart, r.Width)
+	r.dc.SetColor(color.Black)
+	r.dc.SetFontFace(r.fontFace(sizes.Title))
+	r.dc.DrawStringAnchored(title, float64(r.Width)/2, 20, 0.5, 0.5)
+
+	days := dailyReturns(chart.Bars)
+	if len(days) == 0 {
+		return
+	}
+
+	returns := map[time.Time]float64{}
+	maxMove := 0.0
+	for _, day := range days {
+		returns[day.Date] = day.Return
+		maxMove = math.Max(maxMove, math.Abs(day.Return))
+	}
+	firstYear, lastYear := days[0].Date.Year(), days[len(days)-1].Date.Year()
+	years := lastYear - firstYear + 1
+
+	// Fit the week columns across and each year's 7 rows plus a month label row down
+	labelRow, legendHeight := calendarRows(sizes.Axis)
+	cellWidth := calendarCellWidth(r.Width)
+	cellHeight := (float64(r.Height) - calendarTop - calendarMargin - legendHeight - float64(years)*labelRow) / float64(years*7)
+	cell := math.Max(math.Min(cellWidth, cellHeight), 3)
+
+	r.dc.SetFontFace(r.fontFace(sizes.Axis))
+	left := calendarMargin + calendarLabelSide
+	y := calendarTop
+	for year := firstYear; year <= lastYear; year++ {
+		r.drawCalendarYear(year, days[0].Date.Location(), returns, maxMove, left, y+labelRow, cell)
+		y += labelRow + cell*7
+	}
+	r.drawCalendarLegend(maxMove, left, y+10, cell)
+}
+
+// CalendarHeight returns the canvas height that fits every year of the
+// chart's bars at the full cell size for the given width
+func CalendarHeight(chart *cml.Chart, width int) int {
+	years := 1
+	if days := dailyReturns(chart.Bars); len(days) > 0 {
+		years = days[len(days)-1].Date.Year() - days[0].Date.Year() + 1
+	}
+	labelRow, legendHeight := calendarRows(calendarFontSizes(chart, width).Axis)
+	height := calendarTop + float64(years)*(labelRow+calendarCellWidth(width)*7) + legendHeight + calendarMargin
+	return int(math.Ceil(height))
+}
+
+// calendarCellWidth returns the widest day cell that fits the week columns
+func calendarCellWidth(width int) float64 {
+	return (float64(width) - calendarMargin*2 - calendarLabelSide) / calendarWeeks
+}
+
+// calendarRows returns the heights of a year's month label row and of the legend
+func calendarRows(axisSize float64) (labelRow, legendHeight float64) {
+	return axisSize + 6, axisSize + 20
+}
+
+// calendarFontSizes returns the chart's font sizes, with defaults scaled to
+// the canvas width only since calendars are much wider than they are tall
+func calendarFontSizes(chart *cml.Chart, width int) cml.FontSizes {
+	sizes := chart.GetFontSizes()
+	scale := float64(width) / fontReferenceWidth
+	if sizes.Axis == 0 {
+		sizes.Axis = math.Max(minFontSize, defaultAxisFontSize*scale)
+	}
+	if sizes.Title == 0 {
+		sizes.Title = math.Max(minFontSize, defaultTitleFontSize*scale)
+	}
+	return sizes
+}
+
+// drawCalendarYear draws one year's block with its month and weekday labels;
+// top is the top of the Sunday row
+func (r *CMLRenderer) drawCalendarYear(year int, loc *time.Location, returns map[time.Time]float64, maxMove, left, top, cell float64) {
+	r.dc.SetColor(color.Black)
+	r.dc.DrawStringAnchored(fmt.Sprint(year), left-calendarLabelSide, top-4, 0, 0)
+	for i, name := range []string{"Mon", "Wed", "Fri"} {
+		row := float64(i*2 + 1)
+		r.dc.DrawStringAnchored(name, left-6, top+cell*(row+0.5), 1, 0.35)
+	}
+
+	jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	offset := int(jan1.Weekday()) // Column 0 starts on the Sunday before January 1
+	for day := jan1; day.Year() == year; day = day.AddDate(0, 0, 1) {
+		index := day.YearDay() - 1 + offset
+		x := left + float64(index/7)*cell
+		y := top + float64(index%7)*cell
+
+		if day.Day() == 1 {
+			r.dc.SetColor(color.Black)
+			r.dc.DrawStringAnchored(day.Format("Jan"), x, top-4, 0, 0)
+		}
+
+		dayReturn, ok := returns[day]
+		if !ok {
+			r.dc.SetColor(calendarEmptyColor)
+		} else {
+			r.dc.SetColor(returnColor(dayReturn, maxMove))
+		}
+		r.dc.DrawRectangle(x, y, cell-calendarCellGap, cell-calendarCellGap)
+		r.dc.Fill()
+	}
+}
+
+// drawCalendarLegend draws a row of swatches spanning the return scale,
+// labeled with the largest loss and gain it covers
+func (r *CMLRenderer) drawCalendarLegend(maxMove, left, top, cell float64) {
+	const swatches = 7
+	size := math.Min(cell, 14)
+	low := fmt.Sprintf("%+.1f%%", -maxMove*100)
+	lowWidth, _ := r.dc.MeasureString(low)
+	x := left + lowWidth + 6
+	r.dc.SetColor(color.Black)
+	r.dc.DrawStringAnchored(low, x-6, top+size/2, 1, 0.35)
+	for i := 0; i < swatches; i++ {
+		move := maxMove * (float64(i)/float64(swatches-1)*2 - 1)
+		r.dc.SetColor(returnColor(move, maxMove))
+		r.dc.DrawRectangle(x+float64(i)*size, top, size-calendarCellGap, size-calendarCellGap)
+		r.dc.Fill()
+	}
+	r.dc.SetColor(color.Black)
+	r.dc.DrawStringAnchored(fmt.Sprintf("%+.1f%%", maxMove*100), x+swatches*size+4, top+size/2, 0, 0.35)
+}
+
+// returnColor shades a return from white toward green (up) or red (down),
+// reaching the full color at the largest move
+func returnColor(dayReturn, maxMove float64) color.Color {
+	if maxMove == 0 {
+		return color.White
+	}
+	target := calendarUpColor
+	if dayReturn < 0 {
+		target = calendarDownColor
+	}
+	return tintFromWhite(target, math.Min(math.Abs(dayReturn)/maxMove, 1))
+}
+
+// tintFromWhite blends from white (t=0) to the target color (t=1)
+func tintFromWhite(target color.RGBA, t float64) color.RGBA {
+	mix := func(c uint8) uint8 {
+		return uint8(255 + (float64(c)-255)*t)
+	}
+	return color.RGBA{mix(target.R), mix(target.G), mix(target.B), 255}
+}
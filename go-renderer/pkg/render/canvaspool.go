@@ -0,0 +1,36 @@
+package render
+
+import (
+	"image"
+	"sync"
+)
+
+// canvasPools holds reusable RGBA buffers, one sync.Pool per canvas size
+var canvasPools sync.Map // image.Point -> *sync.Pool
+
+// canvasPool returns the buffer pool for the given canvas size
+func canvasPool(width, height int) *sync.Pool {
+	size := image.Pt(width, height)
+	if pool, ok := canvasPools.Load(size); ok {
+		return pool.(*sync.Pool)
+	}
+
+	pool, _ := canvasPools.LoadOrStore(size, &sync.Pool{
+		New: func() interface{} {
+			return image.NewRGBA(image.Rect(0, 0, width, height))
+		},
+	})
+	return pool.(*sync.Pool)
+}
+
+// acquireCanvas returns an RGBA buffer of the given size, reusing a released one when available.
+// The contents are undefined; callers must clear it before drawing.
+func acquireCanvas(width, height int) *image.RGBA {
+	return canvasPool(width, height).Get().(*image.RGBA)
+}
+
+// releaseCanvas returns a buffer to its pool; the caller must not use it afterwards
+func releaseCanvas(img *image.RGBA) {
+	size := img.Bounds().Size()
+	canvasPool(size.X, size.Y).Put(img)
+}
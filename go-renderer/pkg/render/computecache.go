@@ -0,0 +1,99 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// computeCache keeps indicator values computed for one prepared chart, so
+// rendering that chart at several sizes computes each indicator only once
+type computeCache struct {
+	chart  *cml.Chart
+	values map[string]interface{}
+}
+
+// memo returns the cached value for key, computing and storing it on first
+// use. Without a cache for the chart being rendered it just computes.
+func memo[T any](r *CMLRenderer, key string, compute func() T) T {
+	cache := r.computeCache
+	if cache == nil || cache.chart != r.chart {
+		return compute()
+	}
+
+	if value, ok := cache.values[key]; ok {
+		return value.(T)
+	}
+	value := compute()
+	cache.values[key] = value
+	return value
+}
+
+// Size is a canvas size in pixels
+type Size struct {
+	Width  int
+	Height int
+}
+
+// String formats the size as WIDTHxHEIGHT
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// Scaled returns the size in image pixels of a canvas drawn at the given
+// scale, as by NewScaledCMLRenderer
+func (s Size) Scaled(scale float64) Size {
+	return Size{Width: int(math.Round(float64(s.Width) * scale)), Height: int(math.Round(float64(s.Height) * scale))}
+}
+
+// RenderSizes renders the chart once per size with the renderer's options,
+// writing sizes[i] to outputFiles[i]. Price adjustment, conversion, and
+// indicator math run once and are shared by every size. The renderer keeps
+// the canvas of the last size.
+func (r *CMLRenderer) RenderSizes(chart *cml.Chart, sizes []Size, outputFiles []string) error {
+	if len(sizes) != len(outputFiles) {
+		return fmt.Errorf("got %d sizes but %d output files", len(sizes), len(outputFiles))
+	}
+
+	return r.RenderSizesTo(chart, sizes, func(i int, encode func(w io.Writer) error) error {
+		return writeFile(outputFiles[i], encode)
+	})
+}
+
+// RenderSizesTo is RenderSizes for streams: after drawing sizes[i] it calls
+// write with i and a function that encodes that size as PNG to a writer
+func (r *CMLRenderer) RenderSizesTo(chart *cml.Chart, sizes []Size, write func(i int, encode func(w io.Writer) error) error) error {
+	options := chart.GetPNGOptions()
+	if r.PNGOptions != nil {
+		options = *r.PNGOptions
+	}
+
+	prepared := prepareChart(r.themedChart(chart))
+	r.computeCache = &computeCache{chart: prepared, values: map[string]interface{}{}}
+	defer func() { r.computeCache = nil }()
+	for i, size := range sizes {
+		r.resize(size.Width, size.Height)
+		r.drawPrepared(prepared)
+		encode := func(w io.Writer) error {
+			return encodePNG(w, r.dc.Image(), options)
+		}
+		if err := write(i, encode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resize swaps the renderer's canvas for one of the given size
+func (r *CMLRenderer) resize(width, height int) {
+	if r.Width == width && r.Height == height && r.canvas != nil {
+		return
+	}
+	if r.canvas != nil {
+		releaseCanvas(r.canvas)
+	}
+	r.Width, r.Height = width, height
+	r.useCanvas(acquireCanvas(r.deviceSize(width, height)))
+}
@@ -0,0 +1,211 @@
+package render
+
+import (
+	"sort"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// priceConverter multiplies prices by a fixed rate or by the rate in effect
+// at each time, and volumes by their own rates when it has any
+type priceConverter struct {
+	rate  float64
+	rates []cml.SeriesPoint // Sorted by time; the first rate also covers earlier times
+
+	// volumeRates scale volumes like rates scale prices, e.g. for splits;
+	// volumes are left alone without them
+	volumeRates []cml.SeriesPoint
+}
+
+// convertChart returns a copy of the chart with every price converted by its
+// convert setting, or the chart itself when it has none. Data-only series are
+// left unconverted.
+func convertChart(chart *cml.Chart) *cml.Chart {
+	config, ok := chart.GetConvertConfig()
+	if !ok {
+		return chart
+	}
+
+	c := priceConverter{rate: config.Rate}
+	if config.Series != "" {
+		series, _ := chart.GetSeries(config.Series)
+		c.rates = append([]cml.SeriesPoint(nil), series.Points...)
+		sort.SliceStable(c.rates, func(i, j int) bool { return c.rates[i].DateTime.Before(c.rates[j].DateTime) })
+	}
+	return c.chart(chart)
+}
+
+// chart returns a copy of the chart with every price converted
+func (c priceConverter) chart(chart *cml.Chart) *cml.Chart {
+	converted := *chart
+	converted.Bars = make([]cml.Bar, len(chart.Bars))
+	for i, bar := range chart.Bars {
+		converted.Bars[i] = c.bar(bar)
+	}
+
+	converted.Drawings = make([]cml.Drawing, len(chart.Drawings))
+	for i, drawing := range chart.Drawings {
+		converted.Drawings[i] = c.drawing(drawing)
+	}
+
+	dataSeries := chart.GetDataSeries()
+	converted.Series = make([]cml.Series, len(chart.Series))
+	for i, series := range chart.Series {
+		converted.Series[i] = series
+		if dataSeries[series.Name] {
+			continue
+		}
+		converted.Series[i].Points = make([]cml.SeriesPoint, len(series.Points))
+		for j, point := range series.Points {
+			rate := c.rateAt(point.DateTime)
+			point.Value *= rate
+			point.Lower *= rate
+			point.Upper *= rate
+			converted.Series[i].Points[j] = point
+		}
+	}
+
+	converted.Positions = make([]cml.Position, len(chart.Positions))
+	for i, position := range chart.Positions {
+		rate := c.rateAt(position.DateTime)
+		position.Entry *= rate
+		position.Stop *= rate
+		position.Target *= rate
+		converted.Positions[i] = position
+	}
+
+	converted.Fan = cml.Fan{Percentiles: chart.Fan.Percentiles, Points: make([]cml.FanPoint, len(chart.Fan.Points))}
+	for i, point := range chart.Fan.Points {
+		rate := c.rateAt(point.DateTime)
+		values := make([]float64, len(point.Values))
+		for j, value := range point.Values {
+			values[j] = value * rate
+		}
+		converted.Fan.Points[i] = cml.FanPoint{DateTime: point.DateTime, Values: values}
+	}
+
+	converted.Ticks = make([]cml.Tick, len(chart.Ticks))
+	for i, tick := range chart.Ticks {
+		tick.Price = c.at(tick.DateTime, tick.Price)
+		tick.Size *= c.volumeRateAt(tick.DateTime)
+		converted.Ticks[i] = tick
+	}
+	return &converted
+}
+
+// rateAt returns the rate in effect at a time
+func (c priceConverter) rateAt(t time.Time) float64 {
+	if len(c.rates) == 0 {
+		return c.rate
+	}
+	return rateIn(c.rates, t)
+}
+
+// volumeRateAt returns the volume rate in effect at a time
+func (c priceConverter) volumeRateAt(t time.Time) float64 {
+	if len(c.volumeRates) == 0 {
+		return 1
+	}
+	return rateIn(c.volumeRates, t)
+}
+
+// rateIn returns the rate in effect at a time from rates sorted by time
+func rateIn(rates []cml.SeriesPoint, t time.Time) float64 {
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].DateTime.After(t) })
+	if i == 0 {
+		return rates[0].Value
+	}
+	return rates[i-1].Value
+}
+
+// at converts a price at a time
+func (c priceConverter) at(t time.Time, price float64) float64 {
+	return price * c.rateAt(t)
+}
+
+// bar converts a bar's prices, quotes, price levels, and volumes
+func (c priceConverter) bar(bar cml.Bar) cml.Bar {
+	rate := c.rateAt(bar.DateTime)
+	volumeRate := c.volumeRateAt(bar.DateTime)
+	bar.Open *= rate
+	bar.High *= rate
+	bar.Low *= rate
+	bar.Close *= rate
+	bar.BidPrice *= rate
+	bar.AskPrice *= rate
+	bar.Volume *= volumeRate
+	bar.BidVolume *= volumeRate
+	bar.AskVolume *= volumeRate
+	if len(bar.Levels) > 0 {
+		levels := make([]cml.PriceLevel, len(bar.Levels))
+		for i, level := range bar.Levels {
+			level.Price *= rate
+			level.BidVolume *= volumeRate
+			level.AskVolume *= volumeRate
+			levels[i] = level
+		}
+		bar.Levels = levels
+	}
+	return bar
+}
+
+// drawing converts a drawing's price coordinates, each at its own time;
+// slopes use the rate at their anchor
+func (c priceConverter) drawing(drawing cml.Drawing) cml.Drawing {
+	switch d := drawing.(type) {
+	case cml.Rectangle:
+		d.StartPrice, d.EndPrice = c.at(d.StartTime, d.StartPrice), c.at(d.EndTime, d.EndPrice)
+		return d
+	case cml.Line:
+		d.StartPrice, d.EndPrice = c.at(d.StartTime, d.StartPrice), c.at(d.EndTime, d.EndPrice)
+		return d
+	case cml.ContinuousLine:
+		d.StartPrice, d.EndPrice = c.at(d.StartTime, d.StartPrice), c.at(d.EndTime, d.EndPrice)
+		return d
+	case cml.Curve:
+		d.StartPrice, d.EndPrice = c.at(d.StartTime, d.StartPrice), c.at(d.EndTime, d.EndPrice)
+		return d
+	case cml.Ray:
+		rate := c.rateAt(d.DateTime)
+		d.Price *= rate
+		d.Slope *= rate
+		return d
+	case cml.Cone:
+		rate := c.rateAt(d.DateTime)
+		d.Price *= rate
+		d.SlopeUp *= rate
+		d.SlopeDown *= rate
+		return d
+	case cml.RRBox:
+		rate := c.rateAt(d.StartTime)
+		d.Entry *= rate
+		d.Stop *= rate
+		d.Target *= rate
+		return d
+	case cml.Path:
+		points := make([]cml.PathPoint, len(d.Points))
+		for i, point := range d.Points {
+			points[i] = cml.PathPoint{Time: point.Time, Price: c.at(point.Time, point.Price)}
+		}
+		d.Points = points
+		return d
+	case cml.Trail:
+		points := make([]cml.PathPoint, len(d.Points))
+		for i, point := range d.Points {
+			points[i] = cml.PathPoint{Time: point.Time, Price: c.at(point.Time, point.Price)}
+		}
+		d.Points = points
+		return d
+	case cml.Group:
+		members := make([]cml.Drawing, len(d.Drawings))
+		for i, member := range d.Drawings {
+			members[i] = c.drawing(member)
+		}
+		d.Drawings = members
+		return d
+	}
+
+	// Markers, notes, highlights, and trendlines follow the bars on their own
+	return drawing
+}
@@ -0,0 +1,49 @@
+package render
+
+import "math"
+
+// pixelOffset is where within a pixel a horizontal or vertical line of the
+// given width must sit to cover whole pixels: the center for odd widths and
+// hairlines, or the edge between pixels for even widths. A width of 0, the
+// edge of a fill, also sits between pixels.
+func pixelOffset(width float64) float64 {
+	rounded := math.Round(width)
+	if width > 0 && (rounded <= 1 || int(rounded)%2 == 1) {
+		return 0.5
+	}
+	return 0
+}
+
+// snapToPixels moves the coordinate of a horizontal or vertical line of the
+// given width to the nearest place where it covers whole pixels, so it draws
+// sharp instead of blurred across two rows or columns
+func snapToPixels(v, width float64) float64 {
+	offset := pixelOffset(width)
+	return math.Round(v-offset) + offset
+}
+
+// snapCandle moves a candle's 1px wick to a pixel center and its body edges
+// to where a border of the given width covers whole pixels, keeping the body
+// centered on the wick
+func (s *barShape) snapCandle(barWidth, borderWidth float64) {
+	s.x = snapToPixels(s.x, 1)
+
+	// Edges between pixels are a half-pixel from the wick, borders on pixel
+	// centers a whole pixel
+	shift := 0.5 - pixelOffset(borderWidth)
+	half := math.Round(barWidth/2-shift) + shift
+	if half < 0.5 {
+		half++
+	}
+	s.bodyLeft, s.bodyRight = s.x-half, s.x+half
+	s.bodyTop = snapToPixels(s.bodyTop, borderWidth)
+	s.bodyBottom = snapToPixels(s.bodyBottom, borderWidth)
+}
+
+// snapOHLC moves an OHLC bar's high-low line and its open and close ticks to
+// where lines of the given width cover whole pixels
+func (s *barShape) snapOHLC(lineWidth float64) {
+	s.x = snapToPixels(s.x, lineWidth)
+	s.openY = snapToPixels(s.openY, lineWidth)
+	s.closeY = snapToPixels(s.closeY, lineWidth)
+}
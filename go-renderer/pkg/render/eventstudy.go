@@ -0,0 +1,189 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Event study line colors
+var (
+	eventStudyPathColor  = color.RGBA{31, 119, 180, 255}
+	eventStudyEventColor = color.RGBA{120, 120, 120, 255}
+)
+
+// EventStudy is the average price path around a set of events, as the percent
+// change from the close of each event's bar
+type EventStudy struct {
+	Window  int         // Bars shown before and after each event
+	Events  []time.Time // Events with a full window of bars on both sides
+	Skipped []time.Time // Events too close to either end of the bars, or past them
+
+	// Paths by offset from the event bar, indexed by offset + Window
+	Mean  []float64
+	Lower []float64
+	Upper []float64
+
+	// Percentiles the band spans, e.g. 10 and 90
+	LowerPercentile float64
+	UpperPercentile float64
+}
+
+// ComputeEventStudy aligns the bars around each event and summarizes the paths
+// by offset: their mean and the given percentiles. An event's bar is the first
+// bar at or after its time, and bars must be in time order.
+func ComputeEventStudy(bars []cml.Bar, events []time.Time, window int, lowerPercentile, upperPercentile float64) (EventStudy, error) {
+	study := EventStudy{Window: window, LowerPercentile: lowerPercentile, UpperPercentile: upperPercentile}
+	if window < 1 {
+		return study, fmt.Errorf("window must be at least 1 bar")
+	}
+
+	var paths [][]float64
+	for _, event := range events {
+		i := sort.Search(len(bars), func(i int) bool { return !bars[i].DateTime.Before(event) })
+		if i-window < 0 || i+window >= len(bars) || bars[i].Close == 0 {
+			study.Skipped = append(study.Skipped, event)
+			continue
+		}
+
+		path := make([]float64, 2*window+1)
+		for j := range path {
+			path[j] = (bars[i-window+j].Close/bars[i].Close - 1) * 100
+		}
+		paths = append(paths, path)
+		study.Events = append(study.Events, event)
+	}
+	if len(paths) == 0 {
+		return study, fmt.Errorf("no event has %d bars on both sides", window)
+	}
+
+	size := 2*window + 1
+	study.Mean = make([]float64, size)
+	study.Lower = make([]float64, size)
+	study.Upper = make([]float64, size)
+	values := make([]float64, len(paths))
+	for j := 0; j < size; j++ {
+		sum := 0.0
+		for k, path := range paths {
+			values[k] = path[j]
+			sum += path[j]
+		}
+		sort.Float64s(values)
+		study.Mean[j] = sum / float64(len(paths))
+		study.Lower[j] = percentile(values, lowerPercentile)
+		study.Upper[j] = percentile(values, upperPercentile)
+	}
+	return study, nil
+}
+
+// percentile returns the pth percentile (0-100) of sorted values,
+// interpolating between the two nearest values
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	below := int(math.Floor(rank))
+	if below >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[below] + (sorted[below+1]-sorted[below])*(rank-float64(below))
+}
+
+// eventStudyTime places a bar offset on the time axis. Offsets are laid out
+// as hourly bars from a fixed origin, so the chart's layout, grid, and series
+// drawing apply unchanged.
+func eventStudyTime(offset int) time.Time {
+	return time.Unix(0, 0).UTC().Add(time.Duration(offset) * time.Hour)
+}
+
+// eventStudyTicks returns tick times at round offsets, at most about ten
+// across the window, always including the event itself
+func eventStudyTicks(window int) []time.Time {
+	step := 1
+	for _, s := range []int{1, 2, 5, 10, 20, 50, 100, 200, 500} {
+		step = s
+		if 2*window/s <= 10 {
+			break
+		}
+	}
+
+	var ticks []time.Time
+	for offset := -(window / step) * step; offset <= window; offset += step {
+		ticks = append(ticks, eventStudyTime(offset))
+	}
+	return ticks
+}
+
+// RenderEventStudy renders an event study of the chart's bars: the mean path
+// as a line inside its percentile band, over bar offsets from the event
+func (r *CMLRenderer) RenderEventStudy(chart *cml.Chart, study EventStudy, outputFile string) error {
+	r.drawEventStudy(chart, study)
+
+	options := chart.GetPNGOptions()
+	if r.PNGOptions != nil {
+		options = *r.PNGOptions
+	}
+	return savePNG(outputFile, r.dc.Image(), options)
+}
+
+// drawEventStudy draws the frame, grid, band, mean path, labels, and title of
+// an event study
+func (r *CMLRenderer) drawEventStudy(chart *cml.Chart, study EventStudy) {
+	points := make([]cml.SeriesPoint, len(study.Mean))
+	times := make([]time.Time, len(study.Mean))
+	bars := make([]cml.Bar, len(study.Mean))
+	for i, mean := range study.Mean {
+		times[i] = eventStudyTime(i - study.Window)
+		points[i] = cml.SeriesPoint{DateTime: times[i], Value: mean, Lower: study.Lower[i], Upper: study.Upper[i], HasBounds: true}
+		bars[i] = cml.Bar{DateTime: times[i], Open: mean, High: study.Upper[i], Low: study.Lower[i], Close: mean}
+	}
+
+	r.chart = chart
+	r.bars = bars
+	r.printLines = 0
+	left, right := r.yAxisMargins(r.marginLeft)
+	r.layout = computeLayout(bars, left, r.marginTop, float64(r.Width)-right, float64(r.Height)-r.marginBottom)
+	r.layout.TimeTicks = eventStudyTicks(study.Window)
+	r.layout.Chart = chart
+	l := r.layout
+
+	r.drawBackground()
+	r.drawGrid()
+
+	// Mark the event bar
+	r.dc.SetColor(eventStudyEventColor)
+	r.dc.SetLineWidth(1)
+	r.setLineDash("dashed", 1)
+	r.dc.DrawLine(l.X(eventStudyTime(0)), l.Top, l.X(eventStudyTime(0)), l.Bottom)
+	r.dc.Stroke()
+	r.dc.SetDash()
+
+	style := r.seriesStyle(nil, eventStudyPathColor, 2)
+	r.drawBoundsBand(points, style)
+	r.drawSeries(times, study.Mean, 0, style)
+
+	// Percent change labels beside the plot and offset labels below it
+	r.dc.SetColor(color.Black)
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	for _, change := range l.PriceTicks {
+		r.drawPriceLabel(fmt.Sprintf("%.1f%%", change), l.Left, l.Right, l.Y(change))
+	}
+	for _, t := range l.TimeTicks {
+		label := "0"
+		if offset := int(t.Sub(eventStudyTime(0)) / time.Hour); offset != 0 {
+			label = fmt.Sprintf("%+d", offset)
+		}
+		r.dc.DrawStringAnchored(label, l.X(t), l.Bottom+20, 0.5, 0.0)
+	}
+	r.dc.DrawStringAnchored("Bars from event", (l.Left+l.Right)/2, l.Bottom+40, 0.5, 0.0)
+
+	title := "Event study"
+	if name := r.getMetaValue(chart.Meta, "title"); name != "" {
+		title = name + ": event study"
+	}
+	title += fmt.Sprintf(" (%d events, P%g-P%g band)", len(study.Events), study.LowerPercentile, study.UpperPercentile)
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Title))
+	r.dc.DrawStringAnchored(title, float64(r.Width)/2, 20, 0.5, 0.5)
+}
@@ -0,0 +1,103 @@
+package render
+
+import (
+	"container/list"
+	"sync"
+
+	"golang.org/x/image/font"
+)
+
+// DefaultFaceCacheSize is how many idle font faces the shared cache keeps
+const DefaultFaceCacheSize = 64
+
+// FaceCacheStats counts lookups in the shared font face cache, for monitoring
+// long-running processes
+type FaceCacheStats struct {
+	Hits      int64 // Faces reused from the cache
+	Misses    int64 // Faces built because none of the size was cached
+	Evictions int64 // Idle faces dropped to stay within the cache size
+	Idle      int   // Faces cached now
+}
+
+// HitRate returns the fraction of lookups served from the cache, or 0 before
+// any lookups
+func (s FaceCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// faceCache keeps the font faces of closed renderers so later renderers can
+// reuse them instead of rasterizing glyphs afresh. A face can't be used
+// concurrently, so a renderer takes faces out while it holds them and Close
+// puts them back; the least recently returned are evicted past the limit.
+type faceCache struct {
+	mu    sync.Mutex
+	limit int
+	idle  *list.List // of cachedFace, most recently returned first
+	stats FaceCacheStats
+}
+
+// cachedFace is an idle face and the pixel size it was built for
+type cachedFace struct {
+	size float64
+	face font.Face
+}
+
+// sharedFaces is the process-wide face cache used by every renderer
+var sharedFaces = &faceCache{limit: DefaultFaceCacheSize, idle: list.New()}
+
+// take removes and returns a cached face of the given size, if any
+func (c *faceCache) take(size float64) (font.Face, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for e := c.idle.Front(); e != nil; e = e.Next() {
+		if cached := e.Value.(cachedFace); cached.size == size {
+			c.idle.Remove(e)
+			c.stats.Hits++
+			return cached.face, true
+		}
+	}
+	c.stats.Misses++
+	return nil, false
+}
+
+// put returns a face to the cache, evicting the least recently returned
+// faces if that takes it past its limit
+func (c *faceCache) put(size float64, face font.Face) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.idle.PushFront(cachedFace{size: size, face: face})
+	c.evict()
+}
+
+// evict drops idle faces past the limit; the caller holds the lock
+func (c *faceCache) evict() {
+	for c.idle.Len() > c.limit {
+		c.idle.Remove(c.idle.Back()).(cachedFace).face.Close()
+		c.stats.Evictions++
+	}
+}
+
+// GetFaceCacheStats returns the shared font face cache's counters
+func GetFaceCacheStats() FaceCacheStats {
+	sharedFaces.mu.Lock()
+	defer sharedFaces.mu.Unlock()
+
+	stats := sharedFaces.stats
+	stats.Idle = sharedFaces.idle.Len()
+	return stats
+}
+
+// SetFaceCacheSize sets how many idle font faces the shared cache keeps,
+// evicting any over the new size; 0 disables caching
+func SetFaceCacheSize(size int) {
+	sharedFaces.mu.Lock()
+	defer sharedFaces.mu.Unlock()
+
+	sharedFaces.limit = max(size, 0)
+	sharedFaces.evict()
+}
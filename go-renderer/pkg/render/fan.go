@@ -0,0 +1,71 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// fanBandAlpha is the opacity of each fan band; nested bands overlap, so
+// inner bands come out darker
+const fanBandAlpha = 46
+
+// fanLevels returns the fan's outermost values, so the chart's price range
+// covers the whole fan
+func fanLevels(fan cml.Fan) []float64 {
+	var levels []float64
+	for _, point := range fan.Points {
+		levels = append(levels, point.Values[0], point.Values[len(point.Values)-1])
+	}
+	return levels
+}
+
+// renderFan shades the fan's percentile paths as nested translucent bands,
+// pairing the outermost percentiles first (P10-P90, then P25-P75, ...), and
+// draws the middle percentile of an odd count as a line. A fan starting
+// after the last bar is anchored at its close.
+func (r *CMLRenderer) renderFan(fan cml.Fan) {
+	if len(fan.Points) == 0 || len(r.bars) == 0 {
+		return
+	}
+
+	points := fan.Points
+	if last := r.bars[len(r.bars)-1]; points[0].DateTime.After(last.DateTime) {
+		anchor := cml.FanPoint{DateTime: last.DateTime, Values: make([]float64, len(fan.Percentiles))}
+		for i := range anchor.Values {
+			anchor.Values[i] = last.Close
+		}
+		points = append([]cml.FanPoint{anchor}, points...)
+	}
+
+	l := r.layout
+	fanColor := r.parseColor(r.chart.GetFanColor())
+	red, green, blue, _ := fanColor.RGBA()
+	bandColor := color.NRGBA{uint8(red >> 8), uint8(green >> 8), uint8(blue >> 8), fanBandAlpha}
+
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	// Upper path left to right, then lower path right to left
+	n := len(fan.Percentiles)
+	for lower, upper := 0, n-1; lower < upper; lower, upper = lower+1, upper-1 {
+		for _, point := range points {
+			r.dc.LineTo(l.X(point.DateTime), l.Y(point.Values[upper]))
+		}
+		for i := len(points) - 1; i >= 0; i-- {
+			r.dc.LineTo(l.X(points[i].DateTime), l.Y(points[i].Values[lower]))
+		}
+		r.dc.ClosePath()
+		r.dc.SetColor(bandColor)
+		r.dc.Fill()
+	}
+
+	if n%2 == 1 {
+		for _, point := range points {
+			r.dc.LineTo(l.X(point.DateTime), l.Y(point.Values[n/2]))
+		}
+		r.dc.SetColor(fanColor)
+		r.dc.SetLineWidth(1.5)
+		r.dc.Stroke()
+	}
+}
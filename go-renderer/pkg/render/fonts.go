@@ -0,0 +1,110 @@
+package render
+
+import (
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Canvas size the default font sizes are chosen for; other canvases scale them
+const (
+	fontReferenceWidth  = 800.0
+	fontReferenceHeight = 600.0
+)
+
+// Default font sizes in pixels at the reference canvas size
+const (
+	defaultAxisFontSize  = 11.0
+	defaultTitleFontSize = 14.0
+	defaultNoteFontSize  = 12.0
+)
+
+// Smallest size a scaled default font shrinks to
+const minFontSize = 6.0
+
+// The Go Regular typeface, parsed once on first use
+var (
+	regularFont     *opentype.Font
+	regularFontErr  error
+	regularFontOnce sync.Once
+)
+
+// textFont returns the typeface used for all chart text
+func textFont() (*opentype.Font, error) {
+	regularFontOnce.Do(func() {
+		regularFont, regularFontErr = opentype.Parse(goregular.TTF)
+	})
+	return regularFont, regularFontErr
+}
+
+// fontScale returns how much default font sizes grow or shrink for the canvas
+func (r *CMLRenderer) fontScale() float64 {
+	return math.Min(float64(r.Width)/fontReferenceWidth, float64(r.Height)/fontReferenceHeight)
+}
+
+// fontSizes returns the chart's font sizes, filling in unset ones with
+// defaults scaled to the canvas
+func (r *CMLRenderer) fontSizes() cml.FontSizes {
+	var sizes cml.FontSizes
+	if r.chart != nil {
+		sizes = r.chart.GetFontSizes()
+	}
+
+	scaled := func(size float64) float64 {
+		return math.Max(minFontSize, size*r.fontScale())
+	}
+	if sizes.Axis == 0 {
+		sizes.Axis = scaled(defaultAxisFontSize)
+	}
+	if sizes.Title == 0 {
+		sizes.Title = scaled(defaultTitleFontSize)
+	}
+	if sizes.Note == 0 {
+		sizes.Note = scaled(defaultNoteFontSize)
+	}
+	return sizes
+}
+
+// fontFace returns a face of the given pixel size, held by the renderer until
+// Close and reused from the shared face cache when one is idle. Scaled
+// renderers get a face of the size in image pixels. If the typeface can't be
+// loaded it falls back to the built-in bitmap face.
+func (r *CMLRenderer) fontFace(size float64) font.Face {
+	size *= r.scale
+	if face, ok := r.faces[size]; ok {
+		return face
+	}
+
+	face, ok := sharedFaces.take(size)
+	if !ok {
+		face = basicfont.Face7x13
+		if f, err := textFont(); err == nil {
+			if sized, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull}); err == nil {
+				face = sized
+			}
+		}
+	}
+
+	if r.faces == nil {
+		r.faces = map[float64]font.Face{}
+	}
+	r.faces[size] = face
+	return face
+}
+
+// releaseFaces returns the renderer's faces to the shared face cache. The
+// bitmap fallback is shared already, so it isn't cached.
+func (r *CMLRenderer) releaseFaces() {
+	for size, face := range r.faces {
+		if face != basicfont.Face7x13 {
+			sharedFaces.put(size, face)
+		}
+	}
+	r.faces = nil
+}
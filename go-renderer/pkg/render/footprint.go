@@ -0,0 +1,124 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Footprint colors for buying (ask) and selling (bid) pressure, and bars
+// without order flow
+var (
+	footprintBuyColor  = color.RGBA{0, 150, 0, 255}
+	footprintSellColor = color.RGBA{200, 0, 0, 255}
+	footprintNoneColor = color.RGBA{160, 160, 160, 255}
+)
+
+// renderFootprintBars renders bars colored by their delta volume. Bars with
+// price level data are split into one cell per level, shaded by that level's
+// delta and labeled "bid x ask" when the cell is large enough.
+func (r *CMLRenderer) renderFootprintBars(bars []cml.Bar) {
+	l := r.layout
+	slot := l.barSlot(len(bars))
+	barWidth := slot * 0.6
+	if len(bars) > 1 && l.BarInterval > 0 {
+		slot = l.X(bars[0].DateTime.Add(l.BarInterval)) - l.X(bars[0].DateTime)
+		barWidth = slot * 0.9
+	}
+
+	// Shade relative to the largest bar and level deltas
+	maxDelta, maxLevelDelta := 0.0, 0.0
+	for _, bar := range bars {
+		maxDelta = math.Max(maxDelta, math.Abs(bar.Delta))
+		for _, level := range bar.Levels {
+			maxLevelDelta = math.Max(maxLevelDelta, math.Abs(level.AskVolume-level.BidVolume))
+		}
+	}
+
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Note * 0.8))
+	for _, bar := range bars {
+		x := l.X(bar.DateTime)
+		highY, lowY := l.Y(bar.High), l.Y(bar.Low)
+		if r.CollectRegions {
+			r.addBarRegion(bar, x, highY, lowY, barWidth)
+		}
+
+		// High-low range
+		r.dc.SetColor(r.colors.wick)
+		r.dc.SetLineWidth(1)
+		r.dc.DrawLine(x, highY, x, lowY)
+		r.dc.Stroke()
+
+		if len(bar.Levels) > 0 {
+			r.drawFootprintLevels(bar, x, barWidth, maxLevelDelta)
+			continue
+		}
+
+		// Open-close body shaded by the bar's delta
+		bodyTop := math.Min(l.Y(bar.Open), l.Y(bar.Close))
+		bodyHeight := math.Max(math.Abs(l.Y(bar.Open)-l.Y(bar.Close)), 1)
+		r.dc.SetColor(deltaColor(bar.Delta, maxDelta, bar.HasDelta))
+		r.dc.DrawRectangle(x-barWidth/2, bodyTop, barWidth, bodyHeight)
+		r.dc.Fill()
+		r.dc.SetColor(r.colors.wick)
+		r.dc.DrawRectangle(x-barWidth/2, bodyTop, barWidth, bodyHeight)
+		r.dc.Stroke()
+	}
+}
+
+// drawFootprintLevels draws one cell per price level of a bar, each as tall
+// as the spacing between the bar's levels, outlining the open-close body
+func (r *CMLRenderer) drawFootprintLevels(bar cml.Bar, x, width, maxLevelDelta float64) {
+	l := r.layout
+	levels := append([]cml.PriceLevel(nil), bar.Levels...)
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Price < levels[j].Price })
+
+	tick := math.Inf(1)
+	for i := 1; i < len(levels); i++ {
+		tick = math.Min(tick, levels[i].Price-levels[i-1].Price)
+	}
+	if math.IsInf(tick, 1) || tick <= 0 {
+		tick = math.Max(bar.High-bar.Low, 1/l.pxPerPrice)
+	}
+	cellHeight := math.Max(tick*l.pxPerPrice, 1)
+
+	for _, level := range levels {
+		y := l.Y(level.Price) - cellHeight/2
+		r.dc.SetColor(deltaColor(level.AskVolume-level.BidVolume, maxLevelDelta, true))
+		r.dc.DrawRectangle(x-width/2, y, width, cellHeight)
+		r.dc.Fill()
+
+		text := fmt.Sprintf("%g x %g", level.BidVolume, level.AskVolume)
+		if w, h := r.dc.MeasureString(text); w+4 <= width && h+2 <= cellHeight {
+			r.dc.SetColor(color.Black)
+			r.dc.DrawStringAnchored(text, x, y+cellHeight/2, 0.5, 0.35)
+		}
+	}
+
+	bodyTop := math.Min(l.Y(bar.Open), l.Y(bar.Close)) - cellHeight/2
+	bodyHeight := math.Abs(l.Y(bar.Open)-l.Y(bar.Close)) + cellHeight
+	r.dc.SetColor(r.colors.wick)
+	r.dc.SetLineWidth(1)
+	r.dc.DrawRectangle(x-width/2, bodyTop, width, bodyHeight)
+	r.dc.Stroke()
+}
+
+// deltaColor shades from white toward green for buying or red for selling,
+// reaching the full color at the largest delta; bars without order flow are gray
+func deltaColor(delta, maxDelta float64, hasDelta bool) color.Color {
+	if !hasDelta {
+		return footprintNoneColor
+	}
+	target := footprintBuyColor
+	if delta < 0 {
+		target = footprintSellColor
+	}
+	t := 0.0
+	if maxDelta > 0 {
+		t = 0.2 + 0.8*math.Min(math.Abs(delta)/maxDelta, 1)
+	}
+	return tintFromWhite(target, t)
+}
@@ -0,0 +1,262 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// ImageRegion maps a screen-space bounding box back to the chart element drawn there
+type ImageRegion struct {
+	Kind  string  `json:"kind"`            // "bar", "indicator", "series", or the drawing type
+	ID    string  `json:"id"`              // Stable element ID, e.g. bar-2025-01-15T10:30 or indicator-ema-21
+	Class string  `json:"class"`           // Space-separated CSS classes, e.g. "drawing drawing-line"
+	Label string  `json:"label,omitempty"` // Bar prices or note text
+	Group string  `json:"group,omitempty"` // Label of the enclosing drawing group
+	X0    float64 `json:"x0"`
+	Y0    float64 `json:"y0"`
+	X1    float64 `json:"x1"`
+	Y1    float64 `json:"y1"`
+
+	// Source element and, for line-like elements, the polylines used for hit testing
+	bar       cml.Bar
+	drawing   cml.Drawing
+	paths     [][]screenPoint
+	tolerance float64
+}
+
+// screenPoint is a point in screen coordinates
+type screenPoint struct {
+	x, y float64
+}
+
+// ImageMap is the sidecar written next to a rendered image
+type ImageMap struct {
+	Image       string        `json:"image"`
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	Description string        `json:"description,omitempty"` // Alt text from cml.Describe
+	Regions     []ImageRegion `json:"regions"`
+}
+
+// regionBounds accumulates the bounding box of the element being rendered
+type regionBounds struct {
+	minX, minY, maxX, maxY float64
+	empty                  bool
+	paths                  [][]screenPoint
+	pad                    float64
+}
+
+// Regions returns the element bounding boxes recorded by the last render.
+// Regions are only recorded when CollectRegions is set.
+func (r *CMLRenderer) Regions() []ImageRegion {
+	return r.regions
+}
+
+// ImageMap returns the recorded regions as a sidecar for the given image file
+func (r *CMLRenderer) ImageMap(imageFile string) ImageMap {
+	imageMap := ImageMap{
+		Image:   filepath.Base(imageFile),
+		Width:   r.Width,
+		Height:  r.Height,
+		Regions: r.regions,
+	}
+	if r.chart != nil {
+		imageMap.Description = cml.Describe(r.chart)
+	}
+	return imageMap
+}
+
+// beginRegion starts tracking the bounding box of an element about to be drawn
+func (r *CMLRenderer) beginRegion() {
+	if r.CollectRegions {
+		r.regionBox = &regionBounds{empty: true}
+	}
+}
+
+// endRegion records the tracked element's bounding box under the given
+// identity; line-like elements keep their polylines for precise hit testing
+func (r *CMLRenderer) endRegion(region ImageRegion, lineLike bool) {
+	b := r.regionBox
+	r.regionBox = nil
+	if b == nil || b.empty {
+		return
+	}
+
+	region.X0, region.Y0, region.X1, region.Y1 = b.minX, b.minY, b.maxX, b.maxY
+	if lineLike {
+		region.paths = b.paths
+		region.tolerance = b.pad
+	}
+	r.addRegion(region)
+}
+
+// newRegionPath starts a new polyline in the tracked element, so separate
+// lines (like Bollinger bands) are not joined end to end
+func (r *CMLRenderer) newRegionPath() {
+	if r.regionBox != nil {
+		r.regionBox.paths = append(r.regionBox.paths, nil)
+	}
+}
+
+// markRegion grows the tracked element's bounding box to include a point,
+// padded by pad pixels on every side, and extends its current polyline; it
+// does nothing unless regions are being collected
+func (r *CMLRenderer) markRegion(x, y, pad float64) {
+	b := r.regionBox
+	if b == nil {
+		return
+	}
+	if len(b.paths) == 0 {
+		b.paths = append(b.paths, nil)
+	}
+	last := len(b.paths) - 1
+	b.paths[last] = append(b.paths[last], screenPoint{x, y})
+	b.pad = math.Max(b.pad, pad)
+	if b.empty {
+		b.minX, b.minY, b.maxX, b.maxY = x-pad, y-pad, x+pad, y+pad
+		b.empty = false
+		return
+	}
+	b.minX = math.Min(b.minX, x-pad)
+	b.minY = math.Min(b.minY, y-pad)
+	b.maxX = math.Max(b.maxX, x+pad)
+	b.maxY = math.Max(b.maxY, y+pad)
+}
+
+// isLineLike reports whether a drawing is a stroked line, hit tested by
+// distance to the line rather than by bounding box
+func isLineLike(drawing cml.Drawing) bool {
+	switch drawing.(type) {
+	case cml.Line, cml.ContinuousLine, cml.Ray, cml.Trendline, cml.Curve, cml.Path, cml.Trail:
+		return true
+	}
+	return false
+}
+
+// addRegion records a region, clamped to the canvas and rounded to a tenth of
+// a pixel; repeated IDs get a numeric suffix so every ID is unique
+func (r *CMLRenderer) addRegion(region ImageRegion) {
+	clamp := func(v, limit float64) float64 {
+		return math.Round(math.Max(0, math.Min(v, limit))*10) / 10
+	}
+	region.X0 = clamp(region.X0, float64(r.Width))
+	region.X1 = clamp(region.X1, float64(r.Width))
+	region.Y0 = clamp(region.Y0, float64(r.Height))
+	region.Y1 = clamp(region.Y1, float64(r.Height))
+	if region.X0 == region.X1 || region.Y0 == region.Y1 {
+		return
+	}
+
+	if r.regionIDs == nil {
+		r.regionIDs = map[string]int{}
+	}
+	r.regionIDs[region.ID]++
+	if n := r.regionIDs[region.ID]; n > 1 {
+		region.ID = fmt.Sprintf("%s-%d", region.ID, n)
+	}
+	r.regions = append(r.regions, region)
+}
+
+// elementTime formats a timestamp for element IDs, e.g. 2025-01-15T10:30
+func elementTime(t time.Time) string {
+	if t.Nanosecond() != 0 {
+		return t.Format("2006-01-02T15:04:05.999999999")
+	}
+	if t.Second() != 0 {
+		return t.Format("2006-01-02T15:04:05")
+	}
+	return t.Format("2006-01-02T15:04")
+}
+
+// indicatorElementID builds an indicator's element ID from its name and
+// calculation parameters, e.g. indicator-ema-21 or indicator-bollinger-20-2
+func indicatorElementID(indicator cml.Indicator) string {
+	id := "indicator-" + indicator.Name
+	for _, key := range []string{"period", "stddev", "fast", "slow", "signal"} {
+		if value, ok := indicator.Parameters[key]; ok {
+			id += "-" + fmt.Sprint(value)
+		}
+	}
+	return id
+}
+
+// classToken turns a label into a CSS class name token
+func classToken(label string) string {
+	return strings.Map(func(c rune) rune {
+		if c == '-' || c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			return c
+		}
+		return '-'
+	}, label)
+}
+
+// addBarRegion records the bounding box of a bar
+func (r *CMLRenderer) addBarRegion(bar cml.Bar, x, highY, lowY, barWidth float64) {
+	class := "bar bar-up"
+	if bar.Close < bar.Open {
+		class = "bar bar-down"
+	}
+	if bar.Tag != "" {
+		class += " bar-tag-" + classToken(bar.Tag)
+	}
+
+	r.addRegion(ImageRegion{
+		Kind:  "bar",
+		bar:   bar,
+		ID:    "bar-" + elementTime(bar.DateTime),
+		Class: class,
+		Label: fmt.Sprintf("O %g H %g L %g C %g", bar.Open, bar.High, bar.Low, bar.Close),
+		X0:    x - barWidth/2,
+		Y0:    highY,
+		X1:    x + barWidth/2,
+		Y1:    lowY,
+	})
+}
+
+// WriteJSON writes the image map as indented JSON
+func (m ImageMap) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteHTML writes the image map as an HTML fragment: the image, with the
+// description as its alt text, plus a <map> whose areas carry each element's
+// id and a title for tooltips
+func (m ImageMap) WriteHTML(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<img src=\"%s\" width=\"%d\" height=\"%d\" usemap=\"#cml-chart\" alt=\"%s\">\n",
+		html.EscapeString(m.Image), m.Width, m.Height, html.EscapeString(m.Description))
+	b.WriteString("<map name=\"cml-chart\">\n")
+
+	// Later regions are drawn on top, and browsers pick the first matching area
+	for i := len(m.Regions) - 1; i >= 0; i-- {
+		region := m.Regions[i]
+		title := region.ID
+		if region.Label != "" {
+			title += ": " + region.Label
+		}
+		fmt.Fprintf(&b, "  <area shape=\"rect\" coords=\"%d,%d,%d,%d\" id=\"%s\" class=\"%s\" data-kind=\"%s\"",
+			int(math.Floor(region.X0)), int(math.Floor(region.Y0)), int(math.Ceil(region.X1)), int(math.Ceil(region.Y1)),
+			html.EscapeString(region.ID), html.EscapeString(region.Class), html.EscapeString(region.Kind))
+		if region.Group != "" {
+			fmt.Fprintf(&b, " data-group=\"%s\"", html.EscapeString(region.Group))
+		}
+		fmt.Fprintf(&b, " title=\"%s\">\n", html.EscapeString(title))
+	}
+
+	b.WriteString("</map>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
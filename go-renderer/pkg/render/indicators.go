@@ -0,0 +1,423 @@
+package render
+
+import (
+	"math"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// computeEMA returns the exponential moving average of bar closes, seeded
+// with the first close
+func computeEMA(bars []cml.Bar, period int) []float64 {
+	ema := make([]float64, len(bars))
+	if len(bars) == 0 {
+		return ema
+	}
+
+	alpha := 2.0 / float64(period+1)
+	ema[0] = bars[0].Close
+
+	for i := 1; i < len(bars); i++ {
+		ema[i] = alpha*bars[i].Close + (1-alpha)*ema[i-1]
+	}
+
+	return ema
+}
+
+// computeHighLow returns the highest high and lowest low of the last period
+// bars at each bar; values before index period-1 are left at zero
+func computeHighLow(bars []cml.Bar, period int) (highest, lowest []float64) {
+	highest = make([]float64, len(bars))
+	lowest = make([]float64, len(bars))
+	for i := period - 1; i < len(bars); i++ {
+		highest[i], lowest[i] = bars[i].High, bars[i].Low
+		for j := i - period + 1; j < i; j++ {
+			highest[i] = math.Max(highest[i], bars[j].High)
+			lowest[i] = math.Min(lowest[i], bars[j].Low)
+		}
+	}
+	return highest, lowest
+}
+
+// computeBreakouts returns 1 for bars closing above the previous bar's
+// highest high, -1 for bars closing below its lowest low, and 0 otherwise
+func computeBreakouts(bars []cml.Bar, highest, lowest []float64, period int) []int {
+	breakouts := make([]int, len(bars))
+	for i := period; i < len(bars); i++ {
+		if bars[i].Close > highest[i-1] {
+			breakouts[i] = 1
+		} else if bars[i].Close < lowest[i-1] {
+			breakouts[i] = -1
+		}
+	}
+	return breakouts
+}
+
+// computeMACD returns the MACD line (fast EMA minus slow EMA of closes), its
+// signal line, and their difference, the histogram
+func computeMACD(bars []cml.Bar, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	emaFast, emaSlow := computeEMA(bars, fast), computeEMA(bars, slow)
+	macd = make([]float64, len(bars))
+	signalLine = make([]float64, len(bars))
+	histogram = make([]float64, len(bars))
+	if len(bars) == 0 {
+		return macd, signalLine, histogram
+	}
+
+	alpha := 2.0 / float64(signal+1)
+	for i := range bars {
+		macd[i] = emaFast[i] - emaSlow[i]
+		if i == 0 {
+			signalLine[i] = macd[i]
+		} else {
+			signalLine[i] = alpha*macd[i] + (1-alpha)*signalLine[i-1]
+		}
+		histogram[i] = macd[i] - signalLine[i]
+	}
+	return macd, signalLine, histogram
+}
+
+// computeRSI returns Wilder's Relative Strength Index of bar closes, from 0
+// to 100; values before index period are left at zero
+func computeRSI(bars []cml.Bar, period int) []float64 {
+	rsi := make([]float64, len(bars))
+	if period < 1 || len(bars) <= period {
+		return rsi
+	}
+
+	// Seed the averages with the simple mean of the first period changes
+	avgGain, avgLoss := 0.0, 0.0
+	for i := 1; i < len(bars); i++ {
+		change := bars[i].Close - bars[i-1].Close
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		if i <= period {
+			avgGain += gain / float64(period)
+			avgLoss += loss / float64(period)
+			if i < period {
+				continue
+			}
+		} else {
+			avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		}
+
+		if avgLoss == 0 {
+			rsi[i] = 100
+		} else {
+			rsi[i] = 100 - 100/(1+avgGain/avgLoss)
+		}
+	}
+	return rsi
+}
+
+// computeHeikinAshi returns the Heikin-Ashi candles of the bars: each close is
+// the bar's OHLC average and each open the midpoint of the previous candle's
+// body, with the wicks widened to cover both. Other bar fields are kept.
+func computeHeikinAshi(bars []cml.Bar) []cml.Bar {
+	candles := make([]cml.Bar, len(bars))
+	for i, bar := range bars {
+		candle := bar
+		candle.Close = (bar.Open + bar.High + bar.Low + bar.Close) / 4
+		if i == 0 {
+			candle.Open = (bar.Open + bar.Close) / 2
+		} else {
+			candle.Open = (candles[i-1].Open + candles[i-1].Close) / 2
+		}
+		candle.High = math.Max(bar.High, math.Max(candle.Open, candle.Close))
+		candle.Low = math.Min(bar.Low, math.Min(candle.Open, candle.Close))
+		candles[i] = candle
+	}
+	return candles
+}
+
+// computeImpulse returns Elder's impulse state of each bar: 1 when both the
+// EMA and the MACD histogram rise, -1 when both fall, and 0 otherwise
+func computeImpulse(bars []cml.Bar, emaPeriod, fast, slow, signal int) []int {
+	ema := computeEMA(bars, emaPeriod)
+	_, _, histogram := computeMACD(bars, fast, slow, signal)
+	impulse := make([]int, len(bars))
+	for i := 1; i < len(bars); i++ {
+		emaChange, histogramChange := ema[i]-ema[i-1], histogram[i]-histogram[i-1]
+		if emaChange > 0 && histogramChange > 0 {
+			impulse[i] = 1
+		} else if emaChange < 0 && histogramChange < 0 {
+			impulse[i] = -1
+		}
+	}
+	return impulse
+}
+
+// computeSMA returns the simple moving average of bar closes; values before
+// index period-1 are left at zero
+func computeSMA(bars []cml.Bar, period int) []float64 {
+	sma := make([]float64, len(bars))
+	for i := period - 1; i < len(bars); i++ {
+		sum := 0.0
+		for j := i - period + 1; j <= i; j++ {
+			sum += bars[j].Close
+		}
+		sma[i] = sum / float64(period)
+	}
+
+	return sma
+}
+
+// computeBollinger returns the upper, middle, and lower Bollinger bands of
+// bar closes; values before index period-1 are left at zero
+func computeBollinger(bars []cml.Bar, period int, stddev float64) (upper, middle, lower []float64) {
+	middle = computeSMA(bars, period)
+	upper = make([]float64, len(bars))
+	lower = make([]float64, len(bars))
+
+	for i := period - 1; i < len(bars); i++ {
+		// Calculate standard deviation
+		variance := 0.0
+		for j := i - period + 1; j <= i; j++ {
+			variance += (bars[j].Close - middle[i]) * (bars[j].Close - middle[i])
+		}
+		std := math.Sqrt(variance / float64(period))
+
+		upper[i] = middle[i] + std*stddev
+		lower[i] = middle[i] - std*stddev
+	}
+
+	return upper, middle, lower
+}
+
+// computeSeasonal returns the average historical path of bar prices through a
+// cycle ("year", "month", "week", or "day"). Each cycle is normalized to
+// returns from its first price, the returns are averaged across cycles at each
+// point in the cycle, and the average is scaled back from the first price of
+// each bar's own cycle. It returns nil with fewer than two cycles.
+func computeSeasonal(bars []cml.Bar, source, period string) []float64 {
+	price := func(bar cml.Bar) float64 { return sourcePrice(bar, source) }
+
+	// First price of every cycle, and each bar's return from it
+	bases := map[int]float64{}
+	returns := make([]float64, len(bars))
+	for i, bar := range bars {
+		cycle, _ := seasonalPosition(bar.DateTime, period)
+		base, ok := bases[cycle]
+		if !ok {
+			base = price(bar)
+			bases[cycle] = base
+		}
+		if base != 0 {
+			returns[i] = price(bar)/base - 1
+		}
+	}
+	if len(bases) < 2 {
+		return nil
+	}
+
+	// Average the returns at each point in the cycle
+	sums := map[int]float64{}
+	counts := map[int]int{}
+	for i, bar := range bars {
+		_, slot := seasonalPosition(bar.DateTime, period)
+		sums[slot] += returns[i]
+		counts[slot]++
+	}
+
+	seasonal := make([]float64, len(bars))
+	for i, bar := range bars {
+		cycle, slot := seasonalPosition(bar.DateTime, period)
+		seasonal[i] = bases[cycle] * (1 + sums[slot]/float64(counts[slot]))
+	}
+	return seasonal
+}
+
+// sourcePrice returns a bar's open, high, low, or close by name, defaulting to close
+func sourcePrice(bar cml.Bar, source string) float64 {
+	switch source {
+	case "open":
+		return bar.Open
+	case "high":
+		return bar.High
+	case "low":
+		return bar.Low
+	}
+	return bar.Close
+}
+
+// computeATR returns Wilder's average true range over period bars; values
+// before index period-1 are left at zero
+func computeATR(bars []cml.Bar, period int) []float64 {
+	atr := make([]float64, len(bars))
+	if period < 1 || len(bars) < period {
+		return atr
+	}
+
+	trueRange := func(i int) float64 {
+		tr := bars[i].High - bars[i].Low
+		if i > 0 {
+			tr = math.Max(tr, math.Max(math.Abs(bars[i].High-bars[i-1].Close), math.Abs(bars[i].Low-bars[i-1].Close)))
+		}
+		return tr
+	}
+
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += trueRange(i)
+	}
+	atr[period-1] = sum / float64(period)
+	for i := period; i < len(bars); i++ {
+		atr[i] = (atr[i-1]*float64(period-1) + trueRange(i)) / float64(period)
+	}
+	return atr
+}
+
+// computeChandelier returns the chandelier exits: the highest high of the
+// last period bars less multiplier average true ranges for longs, and the
+// lowest low plus multiplier ATRs for shorts; values before index period-1
+// are left at zero
+func computeChandelier(bars []cml.Bar, period int, multiplier float64) (long, short []float64) {
+	highest, lowest := computeHighLow(bars, period)
+	atr := computeATR(bars, period)
+	long = make([]float64, len(bars))
+	short = make([]float64, len(bars))
+	for i := period - 1; i < len(bars); i++ {
+		long[i] = highest[i] - multiplier*atr[i]
+		short[i] = lowest[i] + multiplier*atr[i]
+	}
+	return long, short
+}
+
+// computeBands returns bands around a series of values at the given times:
+// width rolling standard deviations of the values ("stddev"), width average
+// true ranges of the bars at or before each time ("atr"), or width percent of
+// each value ("percent"). first is the index of the first banded value.
+func computeBands(times []time.Time, values []float64, bars []cml.Bar, period int, width float64, bandType string) (upper, lower []float64, first int) {
+	upper = make([]float64, len(values))
+	lower = make([]float64, len(values))
+	first = len(values)
+
+	var atr []float64
+	if bandType == "atr" {
+		atr = computeATR(bars, period)
+	}
+
+	j := -1
+	for i, value := range values {
+		var offset float64
+		switch bandType {
+		case "stddev":
+			if i < period-1 {
+				continue
+			}
+			mean := 0.0
+			for _, v := range values[i-period+1 : i+1] {
+				mean += v
+			}
+			mean /= float64(period)
+			variance := 0.0
+			for _, v := range values[i-period+1 : i+1] {
+				variance += (v - mean) * (v - mean)
+			}
+			offset = width * math.Sqrt(variance/float64(period))
+		case "atr":
+			for j+1 < len(bars) && !bars[j+1].DateTime.After(times[i]) {
+				j++
+			}
+			if j < period-1 {
+				continue
+			}
+			offset = width * atr[j]
+		case "percent":
+			offset = math.Abs(value) * width / 100
+		default:
+			return upper, lower, len(values)
+		}
+
+		upper[i], lower[i] = value+offset, value-offset
+		if first == len(values) {
+			first = i
+		}
+	}
+	return upper, lower, first
+}
+
+// seasonalPeriod returns a seasonal indicator's cycle length, defaulting to year
+func seasonalPeriod(params map[string]interface{}) string {
+	if period, ok := params["period"].(string); ok {
+		return period
+	}
+	return "year"
+}
+
+// seasonalPosition identifies the cycle a time falls in and its slot within
+// the cycle: the day of the year or month, or the minute of the week or day
+func seasonalPosition(t time.Time, period string) (cycle, slot int) {
+	minute := t.Hour()*60 + t.Minute()
+	switch period {
+	case "month":
+		return t.Year()*12 + int(t.Month()), t.Day()
+	case "week":
+		year, week := t.ISOWeek()
+		weekday := (int(t.Weekday()) + 6) % 7 // Monday first
+		return year*100 + week, weekday*24*60 + minute
+	case "day":
+		return t.Year()*1000 + t.YearDay(), minute
+	}
+	return t.Year(), t.YearDay()
+}
+
+// smoothSeries returns a display copy of values[first:] passed through a
+// centered moving average of the given window; the input is left unchanged
+func smoothSeries(values []float64, first, window int) []float64 {
+	smoothed := make([]float64, len(values))
+	copy(smoothed, values)
+	if window <= 1 || first >= len(values) {
+		return smoothed
+	}
+
+	half := window / 2
+	for i := first; i < len(values); i++ {
+		// Shrink the window near the edges so it stays centered
+		reach := half
+		if i-first < reach {
+			reach = i - first
+		}
+		if len(values)-1-i < reach {
+			reach = len(values) - 1 - i
+		}
+
+		sum := 0.0
+		for j := i - reach; j <= i+reach; j++ {
+			sum += values[j]
+		}
+		smoothed[i] = sum / float64(2*reach+1)
+	}
+
+	return smoothed
+}
+
+// computeRelativeStrength returns the ratio of each bar's close to the
+// benchmark's latest value at or before the bar, and the index of the first
+// bar with a benchmark value. Benchmark points must be in time order.
+func computeRelativeStrength(bars []cml.Bar, benchmark []cml.SeriesPoint) ([]float64, int) {
+	ratios := make([]float64, len(bars))
+	first := len(bars)
+	j := -1
+	for i, bar := range bars {
+		for j+1 < len(benchmark) && !benchmark[j+1].DateTime.After(bar.DateTime) {
+			j++
+		}
+		if j < 0 {
+			continue
+		}
+		if benchmark[j].Value == 0 {
+			// Hold the last ratio rather than dividing by zero
+			if i > first {
+				ratios[i] = ratios[i-1]
+			}
+			continue
+		}
+		ratios[i] = bar.Close / benchmark[j].Value
+		if first == len(bars) {
+			first = i
+		}
+	}
+	return ratios, first
+}
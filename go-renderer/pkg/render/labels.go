@@ -0,0 +1,88 @@
+package render
+
+// labelBox is the screen-space box taken by a piece of text
+type labelBox struct {
+	x0, y0, x1, y1 float64
+}
+
+// overlaps reports whether two boxes intersect
+func (b labelBox) overlaps(o labelBox) bool {
+	return b.x0 < o.x1 && o.x0 < b.x1 && b.y0 < o.y1 && o.y0 < b.y1
+}
+
+// Gap in pixels between a marker and its label, and between stacked labels
+const labelGap = 3.0
+
+// placeLabel picks a free spot for a w by h label next to a marker centered
+// at x and spanning markerTop to markerBottom. It tries centered on the
+// marker, then beside it, moving further out from the preferred side (above
+// or below) until it finds a spot inside the plot area that nothing already
+// placed is in the way of, then tries the other side the same way. If every
+// spot is taken it uses the first, kept inside the plot area.
+func (r *CMLRenderer) placeLabel(x, markerTop, markerBottom, w, h float64, above bool) labelBox {
+	first := labelBox{x - w/2, markerBottom + labelGap, x + w/2, markerBottom + labelGap + h}
+	if above {
+		first = labelBox{x - w/2, markerTop - labelGap - h, x + w/2, markerTop - labelGap}
+	}
+
+	for _, up := range []bool{above, !above} {
+		for step := 0; step < 4; step++ {
+			top := markerBottom + labelGap + float64(step)*(h+labelGap)
+			if up {
+				top = markerTop - labelGap - h - float64(step)*(h+labelGap)
+			}
+
+			for _, left := range []float64{x - w/2, x + labelGap, x - labelGap - w} {
+				box := labelBox{left, top, left + w, top + h}
+				if r.insidePlot(box) && !r.labelTaken(box) {
+					return box
+				}
+			}
+		}
+	}
+
+	l := r.layout
+	if first.y1 > l.Bottom {
+		first.y0, first.y1 = l.Bottom-h, l.Bottom
+	}
+	if first.y0 < l.Top {
+		first.y0, first.y1 = l.Top, l.Top+h
+	}
+	return first
+}
+
+// insidePlot reports whether a box lies entirely within the plot area
+func (r *CMLRenderer) insidePlot(box labelBox) bool {
+	l := r.layout
+	return box.x0 >= l.Left && box.x1 <= l.Right && box.y0 >= l.Top && box.y1 <= l.Bottom
+}
+
+// labelTaken reports whether a box overlaps any text already drawn
+func (r *CMLRenderer) labelTaken(box labelBox) bool {
+	for _, placed := range r.labelBoxes {
+		if box.overlaps(placed) {
+			return true
+		}
+	}
+	return false
+}
+
+// drawMarkerLabel draws a marker's label above or below a marker centered at
+// x and spanning top to bottom, avoiding labels and notes drawn earlier
+func (r *CMLRenderer) drawMarkerLabel(text string, x, top, bottom float64, above bool, styles map[string]interface{}) {
+	if text == "" {
+		return
+	}
+
+	fontSize := r.getStyleFloat(styles, "font-size", r.fontSizes().Note)
+	r.dc.SetFontFace(r.fontFace(fontSize))
+	r.dc.SetColor(r.getStyleColor(styles, "font-color", r.colors.text))
+
+	w, h := r.dc.MeasureString(text)
+	box := r.placeLabel(x, top, bottom, w, h, above)
+	r.labelBoxes = append(r.labelBoxes, box)
+	r.dc.DrawStringAnchored(text, box.x0, box.y0, 0, 1)
+
+	r.markRegion(box.x0, box.y0, 1)
+	r.markRegion(box.x1, box.y1, 1)
+}
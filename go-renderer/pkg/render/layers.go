@@ -0,0 +1,154 @@
+package render
+
+import (
+	"fmt"
+
+	"github.com/fogleman/gg"
+)
+
+// Layer draws one stage of a chart. Layers run in order, each painting over
+// the ones before it.
+type Layer interface {
+	Draw(ctx *Layout, dc *gg.Context)
+}
+
+// LayerFunc adapts an ordinary function to the Layer interface
+type LayerFunc func(ctx *Layout, dc *gg.Context)
+
+// Draw calls f(ctx, dc)
+func (f LayerFunc) Draw(ctx *Layout, dc *gg.Context) {
+	f(ctx, dc)
+}
+
+// Names of the built-in layers, in drawing order
+const (
+	LayerBackground = "background" // White canvas and plot area frame
+	LayerGrid       = "grid"       // Price and time grid lines
+	LayerHighlights = "highlights" // Drawings shaded behind the bars, like highlight-bar
+	LayerBars       = "bars"       // OHLC bars and volume
+	LayerIndicators = "indicators" // Indicators, user-supplied series, and the fan
+	LayerPositions  = "positions"  // Positions and working orders
+	LayerDrawings   = "drawings"   // Lines, shapes, markers, and notes
+	LayerOverlays   = "overlays"   // Bar tag legend, corporate action and roll markers
+	LayerAxes       = "axes"       // Axis labels, axis tags, and title
+)
+
+// namedLayer is a layer in the rendering pipeline
+type namedLayer struct {
+	name  string
+	layer Layer
+}
+
+// defaultLayers returns the built-in rendering pipeline
+func (r *CMLRenderer) defaultLayers() []namedLayer {
+	return []namedLayer{
+		{LayerBackground, LayerFunc(func(*Layout, *gg.Context) { r.drawBackground() })},
+		{LayerGrid, LayerFunc(func(*Layout, *gg.Context) { r.drawGrid() })},
+		{LayerHighlights, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			for i, drawing := range ctx.Chart.Drawings {
+				r.renderDrawing(drawing, fmt.Sprintf("drawing-%d", i+1), "", true)
+			}
+		})},
+		{LayerBars, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			if len(ctx.Chart.Bars) > 0 {
+				r.renderSpreadBand(ctx.Chart.Bars)
+				r.renderBars(ctx.Chart.Bars)
+				r.renderVolume(ctx.Chart.Bars)
+			}
+		})},
+		{LayerIndicators, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			r.renderFan(ctx.Chart.Fan)
+			if len(ctx.Chart.Indicators) > 0 {
+				r.renderIndicators(ctx.Chart.Indicators)
+			}
+			dataSeries := ctx.Chart.GetDataSeries()
+			for _, series := range ctx.Chart.Series {
+				if !dataSeries[series.Name] {
+					r.renderSeries(series)
+				}
+			}
+			r.renderTickStrip(ctx.Chart.Ticks)
+		})},
+		{LayerPositions, LayerFunc(func(ctx *Layout, _ *gg.Context) { r.renderPositions(ctx.Chart.Positions) })},
+		{LayerDrawings, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			for i, drawing := range ctx.Chart.Drawings {
+				r.renderDrawing(drawing, fmt.Sprintf("drawing-%d", i+1), "", false)
+			}
+		})},
+		{LayerOverlays, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			r.renderBarTagLegend()
+			r.renderActionMarkers(ctx.Chart.CorporateActions)
+			r.renderRollMarkers(ctx.Chart.Contracts)
+		})},
+		{LayerAxes, LayerFunc(func(ctx *Layout, _ *gg.Context) {
+			if len(ctx.Chart.Bars) > 0 {
+				r.drawAxisLabels()
+				r.drawAxisTags()
+			}
+			r.drawTitle(ctx.Chart)
+		})},
+	}
+}
+
+// pipeline returns the layers to draw, in order
+func (r *CMLRenderer) pipeline() []namedLayer {
+	if r.layers == nil {
+		r.layers = r.defaultLayers()
+	}
+	return r.layers
+}
+
+// Layers returns the names of the rendering pipeline's layers, in drawing order
+func (r *CMLRenderer) Layers() []string {
+	names := make([]string, 0, len(r.pipeline()))
+	for _, l := range r.pipeline() {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// InsertLayer adds a custom layer to the rendering pipeline right after the
+// named layer, or at the very end when after is empty. Layer names must be unique.
+func (r *CMLRenderer) InsertLayer(after, name string, layer Layer) error {
+	layers := r.pipeline()
+	for _, l := range layers {
+		if l.name == name {
+			return fmt.Errorf("layer %q already exists", name)
+		}
+	}
+
+	at := len(layers)
+	if after != "" {
+		at = r.layerIndex(after)
+		if at < 0 {
+			return fmt.Errorf("unknown layer %q", after)
+		}
+		at++
+	}
+
+	layers = append(layers, namedLayer{})
+	copy(layers[at+1:], layers[at:])
+	layers[at] = namedLayer{name, layer}
+	r.layers = layers
+	return nil
+}
+
+// RemoveLayer drops a layer, built-in or custom, from the rendering pipeline
+func (r *CMLRenderer) RemoveLayer(name string) error {
+	at := r.layerIndex(name)
+	if at < 0 {
+		return fmt.Errorf("unknown layer %q", name)
+	}
+	r.layers = append(r.layers[:at], r.layers[at+1:]...)
+	return nil
+}
+
+// layerIndex returns the position of the named layer, or -1
+func (r *CMLRenderer) layerIndex(name string) int {
+	for i, l := range r.pipeline() {
+		if l.name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,362 @@
+package render
+
+import (
+	"math"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Layout holds the chart geometry computed once per render
+type Layout struct {
+	// Plot area in screen coordinates
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+
+	// Subpanels stacked below the plot area, sharing its X axis
+	Panels []Panel
+
+	// The time scale, shared by the plot area and every subpanel
+	TimeAxis
+
+	// Visible price range, including padding
+	MinPrice float64
+	MaxPrice float64
+
+	// Price-axis tick values, bottom to top, shared by the grid and the labels
+	PriceTicks []float64
+
+	// Axis labels of PriceTicks and TimeTicks, formatted once per layout
+	priceLabels []string
+	timeLabels  []string
+
+	// Precomputed scale factor for the price transform
+	pxPerPrice float64
+
+	// The chart being rendered
+	Chart *cml.Chart
+
+	// Element regions recorded during rendering, for hit testing
+	regions []ImageRegion
+}
+
+// TimeAxis is the time scale computed once per render and shared by the
+// plot area, every subpanel, the grid, and the axis labels, so that a time
+// falls at the same X everywhere
+type TimeAxis struct {
+	// Visible time range, including padding
+	MinTime time.Time
+	MaxTime time.Time
+
+	// Most common spacing between bars; for a lone bar, a guess from its time
+	BarInterval time.Duration
+
+	// X-axis tick times shared by the grid, the axis labels, and the subpanels
+	TimeTicks []time.Time
+
+	// Screen span of the axis and its precomputed scale factor
+	left, right float64
+	pxPerSecond float64
+
+	// Width the bars spread over when the time axis extends past them
+	barsWidth float64
+}
+
+// Panel is a subpanel below the plot area with its own value scale
+type Panel struct {
+	Name   string // e.g. "relative-strength-SPY" or "ticks"
+	Top    float64
+	Bottom float64
+}
+
+// Height returns the height of the panel
+func (p Panel) Height() float64 {
+	return p.Bottom - p.Top
+}
+
+// Element is a chart element found by hit testing
+type Element struct {
+	ImageRegion
+	Bar     cml.Bar     // The bar, when Kind is "bar"
+	Drawing cml.Drawing // The drawing, when Kind is a drawing type
+}
+
+// computeLayout derives ranges, transforms, and ticks for the given bars and
+// plot area; the price range also covers any extra levels given
+func computeLayout(bars []cml.Bar, left, top, right, bottom float64, levels ...float64) Layout {
+	l := Layout{Left: left, Top: top, Right: right, Bottom: bottom}
+	l.left, l.right = left, right
+	if len(bars) == 0 {
+		return l
+	}
+
+	// Calculate time and price ranges in a single pass
+	l.MinTime = bars[0].DateTime
+	l.MaxTime = bars[0].DateTime
+	l.MinPrice = bars[0].Low
+	l.MaxPrice = bars[0].High
+	for _, bar := range bars[1:] {
+		if bar.DateTime.Before(l.MinTime) {
+			l.MinTime = bar.DateTime
+		}
+		if bar.DateTime.After(l.MaxTime) {
+			l.MaxTime = bar.DateTime
+		}
+		if bar.Low < l.MinPrice {
+			l.MinPrice = bar.Low
+		}
+		if bar.High > l.MaxPrice {
+			l.MaxPrice = bar.High
+		}
+	}
+	for _, level := range levels {
+		l.MinPrice = math.Min(l.MinPrice, level)
+		l.MaxPrice = math.Max(l.MaxPrice, level)
+	}
+
+	// Add some padding
+	priceRange := l.MaxPrice - l.MinPrice
+	if priceRange > 0 {
+		l.MinPrice -= priceRange * 0.05
+		l.MaxPrice += priceRange * 0.05
+	} else {
+		l.MinPrice -= 1.0
+		l.MaxPrice += 1.0
+	}
+
+	// Add one extra interval on each side. A lone bar (or bars all at one
+	// time) has no interval, so it gets a synthetic one and wider padding,
+	// which centers it at a normal width.
+	padding := 1
+	l.BarInterval = cml.BarInterval(bars)
+	if l.BarInterval == 0 {
+		l.BarInterval = singleBarInterval(l.MinTime)
+		padding = singleBarPadding
+	}
+	l.MinTime = l.MinTime.Add(-time.Duration(padding) * l.BarInterval)
+	l.MaxTime = l.MaxTime.Add(time.Duration(padding) * l.BarInterval)
+
+	if timeRange := l.MaxTime.Sub(l.MinTime).Seconds(); timeRange > 0 {
+		l.pxPerSecond = (right - left) / timeRange
+	}
+	if priceRange := l.MaxPrice - l.MinPrice; priceRange > 0 {
+		l.pxPerPrice = l.Height() / priceRange
+	}
+
+	l.PriceTicks = priceTicks.ValueTicks(l.MinPrice, l.MaxPrice)
+	l.TimeTicks = priceTicks.TimeTicks(l.MinTime, l.MaxTime, max(len(bars), 2*padding))
+	return l
+}
+
+// singleBarPadding is how many synthetic intervals pad each side of a lone bar
+const singleBarPadding = 5
+
+// singleBarInterval guesses the interval of a lone bar from how its time is
+// rounded: a bar at midnight is taken as daily, one on the hour as hourly,
+// and so on down to milliseconds
+func singleBarInterval(t time.Time) time.Duration {
+	for _, interval := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if t.Truncate(interval).Equal(t) {
+			return interval
+		}
+	}
+	return time.Millisecond
+}
+
+// barSlot returns the width each of numBars bars gets: an even share of the
+// bars' width, or for a lone bar the width of its synthetic interval
+func (l TimeAxis) barSlot(numBars int) float64 {
+	if numBars == 1 && l.pxPerSecond > 0 {
+		return l.BarInterval.Seconds() * l.pxPerSecond
+	}
+	return l.BarsWidth() / float64(numBars)
+}
+
+// extendTo widens the time axis to one bar interval past end, leaving room
+// for projections beyond the last bar. The bars keep their spacing and move
+// left to make room.
+func (l *TimeAxis) extendTo(end time.Time, numBars int) {
+	end = end.Add(l.BarInterval)
+	if l.pxPerSecond == 0 || !end.After(l.MaxTime) {
+		return
+	}
+	l.barsWidth = l.BarsWidth() * l.MaxTime.Sub(l.MinTime).Seconds() / end.Sub(l.MinTime).Seconds()
+	l.MaxTime = end
+	l.pxPerSecond = (l.right - l.left) / end.Sub(l.MinTime).Seconds()
+	l.TimeTicks = priceTicks.TimeTicks(l.MinTime, l.MaxTime, numBars)
+}
+
+// BarsWidth returns the width the bars spread over: the plot area, less any
+// room left for projections past the last bar
+func (l TimeAxis) BarsWidth() float64 {
+	if l.barsWidth > 0 {
+		return l.barsWidth
+	}
+	return l.right - l.left
+}
+
+// Width returns the width of the plot area
+func (l Layout) Width() float64 {
+	return l.Right - l.Left
+}
+
+// Height returns the height of the plot area
+func (l Layout) Height() float64 {
+	return l.Bottom - l.Top
+}
+
+// Panel returns the subpanel with the given name
+func (l Layout) Panel(name string) (Panel, bool) {
+	for _, panel := range l.Panels {
+		if panel.Name == name {
+			return panel, true
+		}
+	}
+	return Panel{}, false
+}
+
+// AxisBottom returns the bottom of the lowest panel, where the time axis sits
+func (l Layout) AxisBottom() float64 {
+	if len(l.Panels) > 0 {
+		return l.Panels[len(l.Panels)-1].Bottom
+	}
+	return l.Bottom
+}
+
+// formatPriceLabels formats the price tick labels with at least the given
+// decimals, and more where the ticks are too close to tell apart with them
+func (l *Layout) formatPriceLabels(precision int) {
+	l.priceLabels = valueLabels(l.PriceTicks, precision)
+}
+
+// formatTimeLabels formats the time tick labels; call it once the time
+// ticks are final
+func (l *Layout) formatTimeLabels() {
+	l.timeLabels = formatTimeLabels(l.TimeTicks, l.timeLabelFormat())
+}
+
+// panelLayout returns a layout for drawing in a subpanel: the same TimeAxis,
+// with the given value range spread over the subpanel's height
+func (l Layout) panelLayout(p Panel, minValue, maxValue float64) Layout {
+	panel := l
+	panel.Top, panel.Bottom = p.Top, p.Bottom
+	panel.MinPrice, panel.MaxPrice = minValue, maxValue
+	panel.PriceTicks = panelTicks.ValueTicks(minValue, maxValue)
+	panel.pxPerPrice = 0
+	if maxValue > minValue {
+		panel.pxPerPrice = panel.Height() / (maxValue - minValue)
+	}
+	return panel
+}
+
+// X converts a time to a screen X coordinate
+func (l TimeAxis) X(t time.Time) float64 {
+	if l.pxPerSecond == 0 {
+		return (l.left + l.right) / 2
+	}
+	return l.left + t.Sub(l.MinTime).Seconds()*l.pxPerSecond
+}
+
+// TimeAt converts a screen X coordinate to a time; it is the inverse of X
+func (l TimeAxis) TimeAt(x float64) time.Time {
+	if l.pxPerSecond == 0 {
+		return l.MinTime
+	}
+	return l.MinTime.Add(time.Duration((x - l.left) / l.pxPerSecond * float64(time.Second)))
+}
+
+// Y converts a price to a screen Y coordinate (higher prices at top)
+func (l Layout) Y(price float64) float64 {
+	if l.pxPerPrice == 0 {
+		return l.Top + l.Height()/2
+	}
+	return l.Bottom - (price-l.MinPrice)*l.pxPerPrice
+}
+
+// ScreenToTimePrice converts screen coordinates back to a time and price.
+// It is the inverse of X and Y and is not limited to the plot area.
+func (l Layout) ScreenToTimePrice(x, y float64) (time.Time, float64) {
+	t := l.TimeAt(x)
+	price := l.PriceAt(0.5)
+	if l.pxPerPrice != 0 {
+		price = l.MinPrice + (l.Bottom-y)/l.pxPerPrice
+	}
+	return t, price
+}
+
+// HitTest returns the topmost element drawn at the given screen coordinates.
+// Line-like drawings are hit within a few pixels of the line; other elements
+// anywhere in their bounding box. It needs a render with CollectRegions set.
+func (l Layout) HitTest(x, y float64) (Element, bool) {
+	// Later regions were drawn on top
+	for i := len(l.regions) - 1; i >= 0; i-- {
+		region := l.regions[i]
+		if x < region.X0 || x > region.X1 || y < region.Y0 || y > region.Y1 {
+			continue
+		}
+		if region.paths != nil && !nearPaths(region.paths, x, y, region.tolerance) {
+			continue
+		}
+		return Element{ImageRegion: region, Bar: region.bar, Drawing: region.drawing}, true
+	}
+	return Element{}, false
+}
+
+// nearPaths reports whether a point lies within tolerance of any of the polylines
+func nearPaths(paths [][]screenPoint, x, y, tolerance float64) bool {
+	for _, points := range paths {
+		if len(points) > 0 && nearPolyline(points, x, y, tolerance) {
+			return true
+		}
+	}
+	return false
+}
+
+// nearPolyline reports whether a point lies within tolerance of any segment
+// between consecutive points
+func nearPolyline(points []screenPoint, x, y, tolerance float64) bool {
+	if len(points) == 1 {
+		return math.Hypot(x-points[0].x, y-points[0].y) <= tolerance
+	}
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := b.x-a.x, b.y-a.y
+
+		// Project onto the segment, clamped to its ends
+		t := 0.0
+		if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+			t = math.Max(0, math.Min(1, ((x-a.x)*dx+(y-a.y)*dy)/lengthSq))
+		}
+		if math.Hypot(x-(a.x+t*dx), y-(a.y+t*dy)) <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceAt returns the price the given fraction of the way up the visible range
+func (l Layout) PriceAt(fraction float64) float64 {
+	return l.MinPrice + (l.MaxPrice-l.MinPrice)*fraction
+}
+
+// timeLabelFormat returns the time layout for the x-axis labels: seconds when
+// ticks are less than a minute apart, tenths of a second when they are less
+// than a second apart, otherwise hours and minutes within a day and the date
+// beyond
+func (l TimeAxis) timeLabelFormat() string {
+	spacing := l.MaxTime.Sub(l.MinTime)
+	if len(l.TimeTicks) > 1 {
+		spacing = l.TimeTicks[1].Sub(l.TimeTicks[0])
+	}
+	switch {
+	case spacing < time.Second:
+		return "15:04:05.0"
+	case spacing < time.Minute:
+		return "15:04:05"
+	case l.MaxTime.Sub(l.MinTime) <= 24*time.Hour:
+		return "15:04"
+	}
+	return "01/02"
+}
@@ -0,0 +1,118 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// RSI levels above which a market is overbought and below which it is oversold
+const (
+	rsiOverbought = 70.0
+	rsiOversold   = 30.0
+)
+
+// Default colors of the MACD signal line, histogram, and RSI level guides
+var (
+	macdSignalColor    = color.RGBA{255, 140, 0, 255}
+	macdHistogramColor = color.RGBA{150, 150, 150, 160}
+	macdAboveColor     = color.NRGBA{0, 150, 0, 120}
+	macdBelowColor     = color.NRGBA{200, 0, 0, 120}
+	rsiGuideColor      = color.RGBA{150, 150, 150, 255}
+)
+
+// oscillatorPanel names an RSI or MACD indicator's subpanel, like "rsi-14"
+func oscillatorPanel(indicator cml.Indicator) string {
+	return strings.TrimPrefix(indicatorElementID(indicator), "indicator-")
+}
+
+// renderRSI draws the Relative Strength Index in its subpanel on a fixed 0-100
+// scale, with dashed guides at the overbought and oversold levels
+func (r *CMLRenderer) renderRSI(indicator cml.Indicator, style SeriesStyle) {
+	l := r.layout
+	area, ok := l.Panel(oscillatorPanel(indicator))
+	if !ok {
+		return
+	}
+
+	period := cml.RSIParams(indicator.Parameters)
+	panel := l.panelLayout(area, 0, 100)
+	r.drawPanelLabels(panel, fmt.Sprintf("RSI(%d)", period), "%.0f")
+	if len(r.bars) <= period {
+		return
+	}
+	rsi := memo(r, fmt.Sprint("rsi:", period), func() []float64 { return computeRSI(r.bars, period) })
+
+	r.clipToPanel(panel)
+	defer r.dc.ResetClip()
+	r.dc.SetColor(rsiGuideColor)
+	r.dc.SetLineWidth(1)
+	r.setLineDash("dashed", 1)
+	for _, level := range []float64{rsiOverbought, rsiOversold} {
+		r.dc.DrawLine(panel.Left, panel.Y(level), panel.Right, panel.Y(level))
+	}
+	r.dc.Stroke()
+	r.dc.SetDash()
+
+	r.layout = panel
+	r.strokeSeries(rsi, period, style)
+	r.layout = l
+}
+
+// renderMACD draws the MACD line, its signal line, and the histogram of their
+// difference in its subpanel, scaled to their range and zero
+func (r *CMLRenderer) renderMACD(indicator cml.Indicator, style SeriesStyle) {
+	l := r.layout
+	area, ok := l.Panel(oscillatorPanel(indicator))
+	if !ok {
+		return
+	}
+
+	fast, slow, signal := cml.MACDParams(indicator.Parameters)
+	title := fmt.Sprintf("MACD(%d,%d,%d)", fast, slow, signal)
+	if len(r.bars) < slow {
+		r.drawPanelLabels(l.panelLayout(area, -1, 1), title, "%.4g")
+		return
+	}
+	lines := memo(r, fmt.Sprint("macd:", fast, ":", slow, ":", signal), func() [3][]float64 {
+		macd, signalLine, histogram := computeMACD(r.bars, fast, slow, signal)
+		return [3][]float64{macd, signalLine, histogram}
+	})
+
+	// Until the slow EMA has seen a full period the lines are mostly noise
+	first := slow - 1
+	minValue, maxValue := 0.0, 0.0
+	for _, values := range lines {
+		for _, value := range values[first:] {
+			minValue = math.Min(minValue, value)
+			maxValue = math.Max(maxValue, value)
+		}
+	}
+	padding := (maxValue - minValue) * 0.05
+	if padding == 0 {
+		padding = 1
+	}
+	panel := l.panelLayout(area, minValue-padding, maxValue+padding)
+	r.drawPanelLabels(panel, title, "%.4g")
+
+	histogramStyle := SeriesStyle{Render: "histogram", Color: macdHistogramColor}
+	if !r.printMode() {
+		histogramStyle.AboveColor, histogramStyle.BelowColor = macdAboveColor, macdBelowColor
+	}
+	signalParams := map[string]interface{}{}
+	if signalColor, ok := indicator.Parameters["signal-color"]; ok {
+		signalParams["color"] = signalColor
+	}
+	signalStyle := r.seriesStyle(signalParams, macdSignalColor, style.LineWidth)
+
+	r.clipToPanel(panel)
+	defer r.dc.ResetClip()
+	r.layout = panel
+	r.strokeSeries(lines[2], first, histogramStyle)
+	r.strokeSeries(lines[0], first, style)
+	r.strokeSeries(lines[1], first, signalStyle)
+	r.layout = l
+}
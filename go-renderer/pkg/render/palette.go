@@ -0,0 +1,49 @@
+package render
+
+import (
+	"hash/fnv"
+	"image/color"
+	"math/rand"
+)
+
+// autoPalette is the set of distinct colors handed out to elements that
+// don't specify their own color when auto-color is enabled
+var autoPalette = []color.RGBA{
+	{31, 119, 180, 255},  // Blue
+	{255, 127, 14, 255},  // Orange
+	{44, 160, 44, 255},   // Green
+	{214, 39, 40, 255},   // Red
+	{148, 103, 189, 255}, // Purple
+	{140, 86, 75, 255},   // Brown
+	{227, 119, 194, 255}, // Pink
+	{127, 127, 127, 255}, // Gray
+	{188, 189, 34, 255},  // Olive
+	{23, 190, 207, 255},  // Cyan
+}
+
+// ColorCycler hands out palette colors in a deterministic order derived from
+// a seed string, so the same chart always gets the same colors
+type ColorCycler struct {
+	order []int
+	next  int
+}
+
+// NewColorCycler creates a color cycler seeded by the given string (usually
+// the chart title)
+func NewColorCycler(seed string) *ColorCycler {
+	hash := fnv.New64a()
+	hash.Write([]byte(seed))
+
+	rng := rand.New(rand.NewSource(int64(hash.Sum64())))
+	return &ColorCycler{
+		order: rng.Perm(len(autoPalette)),
+	}
+}
+
+// Next returns the next color in the cycle, wrapping around once every
+// palette color has been used
+func (c *ColorCycler) Next() color.Color {
+	col := autoPalette[c.order[c.next%len(c.order)]]
+	c.next++
+	return col
+}
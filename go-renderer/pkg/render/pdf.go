@@ -0,0 +1,127 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+)
+
+// pdfWriter writes a PDF whose pages are each one full-page image, streaming
+// each page out as it is added
+type pdfWriter struct {
+	w       io.Writer
+	offset  int   // Bytes written so far
+	offsets []int // Byte offset of each object, by object number - 1
+	pages   []int // Object numbers of the page objects
+	width   float64
+	height  float64
+	err     error
+}
+
+// Object numbers reserved for the catalog and page tree, which are written
+// last since the page tree lists every page
+const (
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+)
+
+// newPDFWriter starts a PDF with pages of the given size in points
+func newPDFWriter(w io.Writer, width, height float64) *pdfWriter {
+	p := &pdfWriter{w: w, width: width, height: height, offsets: make([]int, 2)}
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+// printf writes to the PDF, keeping track of the offset and the first error
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.offset += n
+	p.err = err
+}
+
+// write writes raw bytes to the PDF
+func (p *pdfWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += n
+	p.err = err
+}
+
+// beginObject starts the next object, or a reserved one when number is not
+// zero, and returns its number
+func (p *pdfWriter) beginObject(number int) int {
+	if number == 0 {
+		p.offsets = append(p.offsets, 0)
+		number = len(p.offsets)
+	}
+	p.offsets[number-1] = p.offset
+	p.printf("%d 0 obj\n", number)
+	return number
+}
+
+// stream writes a stream object with the given dictionary entries
+func (p *pdfWriter) stream(dictionary string, data []byte) int {
+	number := p.beginObject(0)
+	p.printf("<< %s /Length %d >>\nstream\n", dictionary, len(data))
+	p.write(data)
+	p.printf("\nendstream\nendobj\n")
+	return number
+}
+
+// AddPage adds a page showing the image scaled to fill it
+func (p *pdfWriter) AddPage(img image.Image) error {
+	// Uncompressed 8-bit RGB samples, top row first
+	bounds := img.Bounds()
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	row := make([]byte, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			red, green, blue, _ := img.At(x, y).RGBA()
+			i := (x - bounds.Min.X) * 3
+			row[i], row[i+1], row[i+2] = byte(red>>8), byte(green>>8), byte(blue>>8)
+		}
+		zw.Write(row)
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	imageObject := p.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+		bounds.Dx(), bounds.Dy()), compressed.Bytes())
+	contents := p.stream("", []byte(fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", p.width, p.height)))
+
+	page := p.beginObject(0)
+	p.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n",
+		pdfPagesObject, p.width, p.height, imageObject, contents)
+	p.pages = append(p.pages, page)
+	return p.err
+}
+
+// Close writes the page tree, catalog, and cross-reference table
+func (p *pdfWriter) Close() error {
+	p.beginObject(pdfPagesObject)
+	p.printf("<< /Type /Pages /Count %d /Kids [", len(p.pages))
+	for _, page := range p.pages {
+		p.printf(" %d 0 R", page)
+	}
+	p.printf(" ] >>\nendobj\n")
+
+	p.beginObject(pdfCatalogObject)
+	p.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pdfPagesObject)
+
+	xref := p.offset
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, offset := range p.offsets {
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, pdfCatalogObject, xref)
+	return p.err
+}
@@ -0,0 +1,440 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// pngSignature is the fixed eight-byte header of every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngHeaderSize is the length of the signature plus the IHDR chunk, which
+// always comes first
+const pngHeaderSize = 8 + 4 + 4 + 13 + 4
+
+// srgbChromaticities holds the cHRM values for sRGB (white point, then red,
+// green, and blue primaries) in units of 1/100000
+var srgbChromaticities = []uint32{31270, 32900, 64000, 33000, 30000, 60000, 15000, 6000}
+
+// adam7Passes lists the x/y start offsets and steps of the seven interlace passes
+var adam7Passes = []struct{ x, y, dx, dy int }{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// savePNG writes an image to a file using the given encoding options
+func savePNG(path string, img image.Image, options cml.PNGOptions) error {
+	return writeFile(path, func(w io.Writer) error {
+		return encodePNG(w, img, options)
+	})
+}
+
+// writeFile creates a file and fills it through a buffered writer
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	if err := write(w); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// encodePNG encodes an image as PNG, optionally quantized to a palette and interlaced
+func encodePNG(w io.Writer, img image.Image, options cml.PNGOptions) error {
+	level, err := pngCompressionLevel(options.Compression)
+	if err != nil {
+		return err
+	}
+
+	if options.Colors != 0 && (options.Colors < 2 || options.Colors > 256) {
+		return fmt.Errorf("invalid PNG palette size %d (want 2-256, or 0 for truecolor)", options.Colors)
+	}
+	if options.Colors > 0 {
+		img = quantizeImage(img, options.Colors)
+	}
+
+	if options.Interlace {
+		return encodeInterlacedPNG(w, img, level)
+	}
+
+	// Encode to memory so the color space chunks can follow the header
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: level}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return err
+	}
+	encoded := buf.Bytes()
+
+	if _, err := w.Write(encoded[:pngHeaderSize]); err != nil {
+		return err
+	}
+	if err := writeSRGBChunks(w); err != nil {
+		return err
+	}
+	_, err = w.Write(encoded[pngHeaderSize:])
+	return err
+}
+
+// writeSRGBChunks marks the image as sRGB. Canvas colors are sRGB values, and
+// without these chunks some viewers assume a different gamma or display
+// profile and shift the colors slightly. gAMA and cHRM carry the equivalent
+// values for decoders that do not understand sRGB.
+func writeSRGBChunks(w io.Writer) error {
+	// Rendering intent 0 (perceptual)
+	if err := writePNGChunk(w, "sRGB", []byte{0}); err != nil {
+		return err
+	}
+
+	gamma := make([]byte, 4)
+	binary.BigEndian.PutUint32(gamma, 45455)
+	if err := writePNGChunk(w, "gAMA", gamma); err != nil {
+		return err
+	}
+
+	chromaticities := make([]byte, 4*len(srgbChromaticities))
+	for i, v := range srgbChromaticities {
+		binary.BigEndian.PutUint32(chromaticities[i*4:], v)
+	}
+	return writePNGChunk(w, "cHRM", chromaticities)
+}
+
+// pngCompressionLevel maps a compression setting to the encoder's level
+func pngCompressionLevel(name string) (png.CompressionLevel, error) {
+	switch name {
+	case "", "default":
+		return png.DefaultCompression, nil
+	case "none":
+		return png.NoCompression, nil
+	case "fast":
+		return png.BestSpeed, nil
+	case "best":
+		return png.BestCompression, nil
+	}
+	return 0, fmt.Errorf("unknown PNG compression level: %s", name)
+}
+
+// zlibLevel maps a PNG compression level to the equivalent zlib level
+func zlibLevel(level png.CompressionLevel) int {
+	switch level {
+	case png.NoCompression:
+		return zlib.NoCompression
+	case png.BestSpeed:
+		return zlib.BestSpeed
+	case png.BestCompression:
+		return zlib.BestCompression
+	}
+	return zlib.DefaultCompression
+}
+
+// quantizeImage reduces an image to at most maxColors colors using median cut.
+// Charts are mostly flat color, so pixels are mapped without dithering.
+func quantizeImage(img image.Image, maxColors int) *image.Paletted {
+	bounds := img.Bounds()
+
+	// Build a histogram of the distinct colors
+	counts := map[color.NRGBA]int{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+		}
+	}
+
+	entries := make([]colorCount, 0, len(counts))
+	for c, n := range counts {
+		entries = append(entries, colorCount{c, n})
+	}
+	palette := medianCut(entries, maxColors)
+
+	// Map each distinct color to its nearest palette entry once
+	indexes := make(map[color.NRGBA]uint8, len(counts))
+	for c := range counts {
+		indexes[c] = uint8(palette.Index(c))
+	}
+
+	out := image.NewPaletted(bounds, palette)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			out.SetColorIndex(x, y, indexes[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)])
+		}
+	}
+	return out
+}
+
+// colorCount is a histogram entry used by the quantizer
+type colorCount struct {
+	color color.NRGBA
+	count int
+}
+
+// medianCut splits the color histogram into at most maxColors boxes and
+// returns the count-weighted average color of each
+func medianCut(entries []colorCount, maxColors int) color.Palette {
+	// Sort for a deterministic palette regardless of map iteration order
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].color, entries[j].color
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		if a.B != b.B {
+			return a.B < b.B
+		}
+		return a.A < b.A
+	})
+
+	boxes := [][]colorCount{entries}
+	for len(boxes) < maxColors {
+		// Split the box with the widest channel range
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, span := widestChannel(box)
+			if span > bestRange {
+				best, bestChannel, bestRange = i, channel, span
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channelValue(box[i].color, bestChannel) < channelValue(box[j].color, bestChannel)
+		})
+
+		// Cut at the weighted median
+		total := 0
+		for _, entry := range box {
+			total += entry.count
+		}
+		cut, seen := 1, 0
+		for i, entry := range box[:len(box)-1] {
+			seen += entry.count
+			cut = i + 1
+			if seen*2 >= total {
+				break
+			}
+		}
+
+		boxes[best] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b, a, n int
+		for _, entry := range box {
+			r += int(entry.color.R) * entry.count
+			g += int(entry.color.G) * entry.count
+			b += int(entry.color.B) * entry.count
+			a += int(entry.color.A) * entry.count
+			n += entry.count
+		}
+		palette = append(palette, color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+	}
+	return palette
+}
+
+// widestChannel returns the channel (0=R, 1=G, 2=B, 3=A) with the largest range in a box
+func widestChannel(box []colorCount) (int, int) {
+	channel, span := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := 255, 0
+		for _, entry := range box {
+			v := channelValue(entry.color, ch)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > span {
+			channel, span = ch, hi-lo
+		}
+	}
+	return channel, span
+}
+
+// channelValue returns one channel of a color by index
+func channelValue(c color.NRGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	case 2:
+		return int(c.B)
+	}
+	return int(c.A)
+}
+
+// encodeInterlacedPNG writes an Adam7-interlaced PNG. The standard library
+// encoder cannot interlace, so this writes the chunks directly: paletted
+// images as 8-bit indexed color, everything else as 8-bit RGBA.
+func encodeInterlacedPNG(w io.Writer, img image.Image, level png.CompressionLevel) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	paletted, isPaletted := img.(*image.Paletted)
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	// IHDR: dimensions, bit depth 8, color type, deflate, adaptive filtering, Adam7
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:4], uint32(width))
+	binary.BigEndian.PutUint32(header[4:8], uint32(height))
+	header[8] = 8
+	header[9] = 6 // Truecolor with alpha
+	if isPaletted {
+		header[9] = 3 // Indexed color
+	}
+	header[12] = 1
+	if err := writePNGChunk(w, "IHDR", header); err != nil {
+		return err
+	}
+	if err := writeSRGBChunks(w); err != nil {
+		return err
+	}
+
+	bytesPerPixel := 4
+	if isPaletted {
+		bytesPerPixel = 1
+		if err := writePNGPalette(w, paletted.Palette); err != nil {
+			return err
+		}
+	}
+
+	// Compress the scanlines of every pass into a single IDAT stream
+	var data bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&data, zlibLevel(level))
+	if err != nil {
+		return err
+	}
+	for _, pass := range adam7Passes {
+		passWidth := (width - pass.x + pass.dx - 1) / pass.dx
+		passHeight := (height - pass.y + pass.dy - 1) / pass.dy
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+
+		row := make([]byte, 1+passWidth*bytesPerPixel)
+		for py := 0; py < passHeight; py++ {
+			y := bounds.Min.Y + pass.y + py*pass.dy
+			for px := 0; px < passWidth; px++ {
+				x := bounds.Min.X + pass.x + px*pass.dx
+				if isPaletted {
+					row[1+px] = paletted.ColorIndexAt(x, y)
+					continue
+				}
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				copy(row[1+px*4:], []byte{c.R, c.G, c.B, c.A})
+			}
+
+			// Indexed rows are left unfiltered; RGBA rows use the Sub filter
+			row[0] = 0
+			if !isPaletted {
+				subFilter(row, bytesPerPixel)
+			}
+			if _, err := zw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	if err := writePNGChunk(w, "IDAT", data.Bytes()); err != nil {
+		return err
+	}
+	return writePNGChunk(w, "IEND", nil)
+}
+
+// subFilter applies the PNG Sub filter in place to a scanline whose first byte
+// is the filter type, storing each byte as the difference from the byte one
+// pixel to its left
+func subFilter(row []byte, bytesPerPixel int) {
+	row[0] = 1
+	for i := len(row) - 1; i > bytesPerPixel; i-- {
+		row[i] -= row[i-bytesPerPixel]
+	}
+}
+
+// writePNGPalette writes the PLTE chunk and, if any entry is translucent, a tRNS chunk
+func writePNGPalette(w io.Writer, palette color.Palette) error {
+	plte := make([]byte, 0, len(palette)*3)
+	trns := make([]byte, 0, len(palette))
+	opaque := true
+	for _, c := range palette {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		plte = append(plte, n.R, n.G, n.B)
+		trns = append(trns, n.A)
+		if n.A != 255 {
+			opaque = false
+		}
+	}
+
+	if err := writePNGChunk(w, "PLTE", plte); err != nil {
+		return err
+	}
+	if opaque {
+		return nil
+	}
+	return writePNGChunk(w, "tRNS", trns)
+}
+
+// writePNGChunk writes one length-prefixed, CRC-terminated PNG chunk
+func writePNGChunk(w io.Writer, chunkType string, data []byte) error {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	if _, err := io.WriteString(w, chunkType); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	_, err := w.Write(sum[:])
+	return err
+}
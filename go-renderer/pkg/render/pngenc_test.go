@@ -0,0 +1,23 @@
+package render
+
+import (
+	"image"
+	"io"
+	"testing"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+func TestEncodePNGPaletteSize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for _, colors := range []int{0, 2, 256} {
+		if err := encodePNG(io.Discard, img, cml.PNGOptions{Colors: colors}); err != nil {
+			t.Errorf("%d colors: %v", colors, err)
+		}
+	}
+	for _, colors := range []int{-1, 1, 257, 1000} {
+		if err := encodePNG(io.Discard, img, cml.PNGOptions{Colors: colors}); err == nil {
+			t.Errorf("%d colors: no error", colors)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Colors for position zones, labels, and lines
+var (
+	positionStopColor   = color.RGBA{220, 40, 40, 255}
+	positionTargetColor = color.RGBA{30, 160, 60, 255}
+	positionEntryColor  = color.RGBA{30, 90, 200, 255}
+)
+
+// Alpha of the stop-loss and take-profit zones (about 15% opacity)
+const positionZoneAlpha = 40
+
+// positionLevels returns every price a position draws at, so the chart's
+// price range can include them
+func positionLevels(positions []cml.Position) []float64 {
+	var levels []float64
+	for _, position := range positions {
+		levels = append(levels, position.Entry)
+		if position.Stop != 0 {
+			levels = append(levels, position.Stop)
+		}
+		if position.Target != 0 {
+			levels = append(levels, position.Target)
+		}
+	}
+	return levels
+}
+
+// renderPositions draws every position and working order
+func (r *CMLRenderer) renderPositions(positions []cml.Position) {
+	if len(r.bars) == 0 {
+		return
+	}
+
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+	for i, position := range positions {
+		r.beginRegion()
+		r.renderPosition(position)
+		r.endRegion(ImageRegion{
+			Kind:  "position",
+			ID:    fmt.Sprintf("position-%d", i+1),
+			Class: "position position-" + position.Side,
+		}, false)
+	}
+}
+
+// renderPosition draws a position's stop-loss and take-profit zones from its
+// entry time to the right edge, its entry line, and labels with R-multiples
+func (r *CMLRenderer) renderPosition(position cml.Position) {
+	l := r.layout
+	x0 := math.Max(l.X(position.DateTime), l.Left)
+	x1 := l.Right
+	entryY := l.Y(position.Entry)
+	formatStr := fmt.Sprintf("%%.%df", r.chart.GetYAxisConfig().Precision)
+
+	// Risk per unit is the distance to the stop; R-multiples need a stop
+	risk := 0.0
+	if position.Stop != 0 {
+		risk = math.Abs(position.Entry - position.Stop)
+	}
+
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	if position.Stop != 0 {
+		r.drawTradeZone(x0, x1, position.Entry, position.Stop, positionStopColor, fmt.Sprintf("SL "+formatStr+" (-1R)", position.Stop))
+	}
+	if position.Target != 0 {
+		label := fmt.Sprintf("TP "+formatStr, position.Target)
+		if risk > 0 {
+			label += fmt.Sprintf(" (%+.1fR)", math.Abs(position.Target-position.Entry)/risk)
+		}
+		r.drawTradeZone(x0, x1, position.Entry, position.Target, positionTargetColor, label)
+	}
+
+	// Entry line, dashed for orders that haven't filled
+	r.dc.SetColor(positionEntryColor)
+	r.dc.SetLineWidth(1.5)
+	if position.Order {
+		r.dc.SetDash(6, 4)
+	}
+	r.dc.DrawLine(x0, entryY, x1, entryY)
+	r.dc.Stroke()
+	r.dc.SetDash()
+	r.markRegion(x0, entryY, 3)
+	r.markRegion(x1, entryY, 3)
+
+	r.dc.DrawStringAnchored(r.positionLabel(position, formatStr, risk), x0+4, entryY, 0, -0.3)
+}
+
+// renderRRBox draws a risk/reward box: red from entry to stop, green from
+// entry to target, and the reward-to-risk ratio on the entry line
+func (r *CMLRenderer) renderRRBox(box cml.RRBox) {
+	l := r.layout
+	x0, x1 := l.X(box.StartTime), l.X(box.EndTime)
+	entryY := l.Y(box.Entry)
+	formatStr := fmt.Sprintf("%%.%df", r.chart.GetYAxisConfig().Precision)
+	ratio := math.Abs(box.Target-box.Entry) / math.Abs(box.Entry-box.Stop)
+
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	r.drawTradeZone(x0, x1, box.Entry, box.Stop, positionStopColor, fmt.Sprintf(formatStr+" (-1R)", box.Stop))
+	r.drawTradeZone(x0, x1, box.Entry, box.Target, positionTargetColor, fmt.Sprintf(formatStr+" (%+.1fR)", box.Target, ratio))
+
+	entryColor := r.getStyleColor(box.Styles, "border-color", positionEntryColor)
+	r.dc.SetColor(entryColor)
+	r.dc.SetLineWidth(r.getStyleFloat(box.Styles, "line-width", 1.5))
+	r.dc.DrawLine(x0, entryY, x1, entryY)
+	r.dc.Stroke()
+	r.dc.DrawStringAnchored(fmt.Sprintf("R:R %.2f", ratio), x0+4, entryY, 0, -0.3)
+}
+
+// drawTradeZone shades the zone between an entry and a stop or target level
+// from x0 to x1, labeling it inside the zone next to the level
+func (r *CMLRenderer) drawTradeZone(x0, x1, entry, level float64, zoneColor color.RGBA, label string) {
+	entryY, y := r.layout.Y(entry), r.layout.Y(level)
+	r.dc.SetColor(color.NRGBA{zoneColor.R, zoneColor.G, zoneColor.B, positionZoneAlpha})
+	r.dc.DrawRectangle(x0, math.Min(entryY, y), x1-x0, math.Abs(y-entryY))
+	r.dc.Fill()
+	r.markRegion(x0, y, 0)
+	r.markRegion(x1, y, 0)
+
+	anchorY := 1.2
+	if y > entryY {
+		anchorY = -0.2
+	}
+	r.dc.SetColor(zoneColor)
+	r.dc.DrawStringAnchored(label, x1-4, y, 1, anchorY)
+}
+
+// positionLabel describes a position's entry, e.g. "LONG 100 @ 1.2520 (+0.8R)"
+// with the open R-multiple at the last close, or "BUY ORDER 100 @ 1.2520"
+func (r *CMLRenderer) positionLabel(position cml.Position, formatStr string, risk float64) string {
+	side := strings.ToUpper(position.Side)
+	if position.Order {
+		side = "BUY ORDER"
+		if position.Side == "short" {
+			side = "SELL ORDER"
+		}
+	}
+
+	label := side
+	if position.Size != 0 {
+		label += fmt.Sprintf(" %g", position.Size)
+	}
+	label += fmt.Sprintf(" @ "+formatStr, position.Entry)
+
+	if !position.Order && risk > 0 {
+		move := r.bars[len(r.bars)-1].Close - position.Entry
+		if position.Side == "short" {
+			move = -move
+		}
+		label += fmt.Sprintf(" (%+.1fR)", move/risk)
+	}
+	return label
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// printLineStyles tell lines apart without color in print mode, in the order
+// indicators and series take them
+var printLineStyles = []struct {
+	dash  string
+	color color.Color
+}{
+	{"solid", color.Black},
+	{"dashed", color.Black},
+	{"dotted", color.Black},
+	{"solid", color.RGBA{110, 110, 110, 255}},
+	{"dashed", color.RGBA{110, 110, 110, 255}},
+	{"dotted", color.RGBA{110, 110, 110, 255}},
+}
+
+// printFrameWidth is the width of the plot area frame in print mode
+const printFrameWidth = 2.0
+
+// printMode reports whether the chart renders for black and white printing,
+// either because the caller asked or the chart's print-mode setting is on
+func (r *CMLRenderer) printMode() bool {
+	return r.PrintMode || (r.chart != nil && r.chart.GetPrintMode())
+}
+
+// applyPrintStyle gives a line without an explicit color or line style the
+// next black or gray dash pattern, so lines stay distinct in grayscale
+func (r *CMLRenderer) applyPrintStyle(params map[string]interface{}, style *SeriesStyle) {
+	next := printLineStyles[r.printLines%len(printLineStyles)]
+	r.printLines++
+	if _, ok := params["color"].(string); !ok {
+		style.Color = next.color
+	}
+	if _, ok := params["style"].(string); !ok {
+		style.Dash = next.dash
+	}
+}
+
+// printCandleColor returns a candle body's fill in print mode: hollow (white)
+// for up bars and filled black for down bars
+func printCandleColor(bar cml.Bar, opacity uint8) color.Color {
+	if bar.Close >= bar.Open {
+		return color.NRGBA{255, 255, 255, opacity}
+	}
+	return color.RGBA{0, 0, 0, opacity}
+}
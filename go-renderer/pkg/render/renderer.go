@@ -0,0 +1,2273 @@
+// Package render draws parsed CML charts to PNG images, along with calendars,
+// event studies, image maps, and PDF reports.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// CMLRenderer handles rendering of CML charts
+type CMLRenderer struct {
+	Width  int
+	Height int
+	dc     *scaledContext
+	canvas *image.RGBA
+
+	// Image pixels per chart pixel, e.g. 2 for high-density displays
+	scale float64
+
+	// Height of each horizontal strip when rendering tiled (0 renders in one pass)
+	tileHeight int
+
+	// Custom rendering pipeline (nil uses the built-in layers)
+	layers []namedLayer
+
+	// CollectRegions records element bounding boxes for image maps (see Regions)
+	CollectRegions bool
+	regions        []ImageRegion
+	regionBox      *regionBounds
+	regionIDs      map[string]int
+
+	// PNGOptions overrides the chart's png-* settings when set
+	PNGOptions *cml.PNGOptions
+
+	// PrintMode renders for black and white printing, as if the chart's
+	// print-mode setting were on
+	PrintMode  bool
+	printLines int // Lines styled so far in print mode
+
+	// Theme overrides the chart's theme setting when set; print mode always
+	// uses the light theme
+	Theme  *cml.Theme
+	colors themeColors // Colors of the theme being drawn
+
+	// Chart geometry, computed once per render in setupChart
+	layout Layout
+
+	// Margins
+	marginLeft   float64
+	marginRight  float64
+	marginTop    float64
+	marginBottom float64
+
+	// Chart data
+	bars     []cml.Bar
+	chart    *cml.Chart
+	barIndex map[int64]int // bar position keyed by Unix nanoseconds
+
+	// Font faces by pixel size
+	faces map[float64]font.Face
+
+	// Text boxes drawn so far, so marker labels can avoid them
+	labelBoxes []labelBox
+
+	// Price levels tagged on the Y axis by drawings
+	axisTags []axisTag
+
+	// Auto-assigned colors (nil unless auto-color is enabled)
+	palette   *ColorCycler
+	autoColor color.Color
+
+	// Indicator values shared between renders of one chart, if any
+	computeCache *computeCache
+}
+
+// NewCMLRenderer creates a new CML renderer. Its canvas comes from a shared
+// pool; call Close once the rendered image is no longer needed.
+func NewCMLRenderer(width, height int) *CMLRenderer {
+	r := newRenderer(width, height)
+	r.useCanvas(acquireCanvas(width, height))
+	return r
+}
+
+// newRenderer creates a renderer with default margins and no canvas
+func newRenderer(width, height int) *CMLRenderer {
+	return &CMLRenderer{
+		Width:  width,
+		Height: height,
+		scale:  1,
+
+		// Set default margins
+		marginLeft:   60.0,
+		marginRight:  20.0,
+		marginTop:    40.0,
+		marginBottom: 60.0,
+	}
+}
+
+// useCanvas clears a canvas to white and makes it the drawing target
+func (r *CMLRenderer) useCanvas(canvas *image.RGBA) {
+	r.canvas = canvas
+	r.dc = newScaledContext(canvas, r.scale)
+	r.dc.SetColor(color.White)
+	r.dc.Clear()
+}
+
+// Render renders a chart to a PNG file
+func (r *CMLRenderer) Render(chart *cml.Chart, outputFile string) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return r.RenderTo(chart, w, "png")
+	})
+}
+
+// RenderImage renders a chart and returns the image. The image is the
+// renderer's canvas, so it is only valid until the renderer draws again or is
+// closed. Tiled renderers never hold the whole image; use RenderTo instead.
+func (r *CMLRenderer) RenderImage(chart *cml.Chart) (image.Image, error) {
+	if r.tileHeight > 0 {
+		return nil, fmt.Errorf("a tiled renderer can't render to an image; use RenderTo")
+	}
+
+	r.draw(chart)
+	return r.dc.Image(), nil
+}
+
+// RenderTo renders a chart and encodes it to w as "png" or "jpeg", so servers
+// can stream charts without temp files. PNG output uses the chart's png-*
+// settings unless PNGOptions overrides them.
+func (r *CMLRenderer) RenderTo(chart *cml.Chart, w io.Writer, format string) error {
+	switch format {
+	case "png":
+		// Use options from settings unless the caller overrides them
+		options := chart.GetPNGOptions()
+		if r.PNGOptions != nil {
+			options = *r.PNGOptions
+		}
+
+		if r.tileHeight > 0 {
+			return r.renderTiled(chart, w, options)
+		}
+		r.draw(chart)
+		return encodePNG(w, r.dc.Image(), options)
+	case "jpeg", "jpg":
+		img, err := r.RenderImage(chart)
+		if err != nil {
+			return err
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return fmt.Errorf("unsupported image format %q (want png or jpeg)", format)
+}
+
+// jpegQuality is the quality of JPEG output, high enough to keep text and
+// thin lines legible
+const jpegQuality = 90
+
+// draw renders every chart element onto the current context, one layer at a time
+func (r *CMLRenderer) draw(chart *cml.Chart) {
+	r.drawPrepared(prepareChart(r.themedChart(chart)))
+}
+
+// chartTransforms are the stages between parsing and rendering that rewrite
+// a chart's series, in the order they run; each returns the chart itself when
+// the chart doesn't ask for it
+var chartTransforms = []func(*cml.Chart) *cml.Chart{
+	AdjustChart,  // Back-adjust for corporate actions
+	convertChart, // Convert prices to another unit
+	renkoChart,   // Replace the bars with renko bricks
+}
+
+// prepareChart returns the chart with every transform applied
+func prepareChart(chart *cml.Chart) *cml.Chart {
+	for _, transform := range chartTransforms {
+		chart = transform(chart)
+	}
+	return chart
+}
+
+// drawPrepared renders an already prepared chart onto the current context
+func (r *CMLRenderer) drawPrepared(chart *cml.Chart) {
+	r.regions = nil
+	r.regionIDs = nil
+	r.labelBoxes = nil
+	r.axisTags = nil
+
+	// Start from the same drawing state every time, so a renderer that draws
+	// repeatedly, such as one serving requests, gives the same image each time
+	r.dc.Push()
+	defer r.dc.Pop()
+	r.dc.ClearPath()
+
+	r.setupChart(chart)
+	r.layout.Chart = chart
+
+	// There's nothing to scale the axes to without bars, so say so rather
+	// than leave a blank canvas
+	if len(chart.Bars) == 0 {
+		r.drawNoData(chart)
+		return
+	}
+
+	for _, l := range r.pipeline() {
+		l.layer.Draw(&r.layout, r.dc.Context)
+	}
+
+	// Make the recorded regions available for hit testing
+	r.layout.regions = r.regions
+}
+
+// Distance between the plot edge and the price labels
+const priceLabelGap = 10.0
+
+// yAxisMargins returns the left and right margins, giving the price label
+// margin to whichever sides the y-axis-position setting draws labels on
+func (r *CMLRenderer) yAxisMargins(labelMargin float64) (left, right float64) {
+	switch r.chart.GetYAxisPosition() {
+	case "right":
+		return r.marginRight, labelMargin
+	case "both":
+		return labelMargin, labelMargin
+	}
+	return labelMargin, r.marginRight
+}
+
+// priceLabelWidth measures the widest price axis label of a layout
+func (r *CMLRenderer) priceLabelWidth(l Layout) float64 {
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	widest := 0.0
+	for _, label := range l.priceLabels {
+		w, _ := r.dc.MeasureString(label)
+		widest = math.Max(widest, w)
+	}
+	if convert, ok := r.chart.GetConvertConfig(); ok && convert.Unit != "" {
+		w, _ := r.dc.MeasureString(convert.Unit)
+		widest = math.Max(widest, w)
+	}
+	return widest
+}
+
+// drawPriceLabel draws a price axis label at y outside the plot edges left
+// and right, on the sides the y-axis-position setting asks for
+func (r *CMLRenderer) drawPriceLabel(text string, left, right, y float64) {
+	position := r.chart.GetYAxisPosition()
+	if position != "right" {
+		r.dc.DrawStringAnchored(text, left-priceLabelGap, y, 1.0, 0.5)
+	}
+	if position != "left" {
+		r.dc.DrawStringAnchored(text, right+priceLabelGap, y, 0.0, 0.5)
+	}
+}
+
+// drawTitle draws the chart title from meta
+func (r *CMLRenderer) drawTitle(chart *cml.Chart) {
+	title := r.getMetaValue(chart.Meta, "title")
+	if title != "" {
+		r.dc.SetColor(r.colors.text)
+		r.dc.SetFontFace(r.fontFace(r.fontSizes().Title))
+		r.dc.DrawStringAnchored(title, float64(r.Width)/2, 20, 0.5, 0.5)
+	}
+}
+
+// noDataMessage is shown in place of a chart with no bars
+const noDataMessage = "No data"
+
+// drawNoData draws the placeholder for a chart with no bars: the title over
+// a message centered in the plot area
+func (r *CMLRenderer) drawNoData(chart *cml.Chart) {
+	r.dc.SetColor(r.colors.background)
+	r.dc.Clear()
+
+	l := r.layout
+	r.dc.SetColor(color.Gray{Y: 128})
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Title))
+	r.dc.DrawStringAnchored(noDataMessage, (l.Left+l.Right)/2, (l.Top+l.Bottom)/2, 0.5, 0.5)
+
+	r.drawTitle(chart)
+}
+
+// Layout returns the geometry of the last render, for converting between
+// screen coordinates and chart data
+func (r *CMLRenderer) Layout() Layout {
+	return r.layout
+}
+
+// Close returns the renderer's canvas and font faces to their shared pools. The renderer must not be used afterwards.
+func (r *CMLRenderer) Close() {
+	r.releaseFaces()
+	if r.canvas == nil {
+		return
+	}
+	releaseCanvas(r.canvas)
+	r.canvas = nil
+	r.dc = nil
+}
+
+// buildBarIndex indexes bars by timestamp so markers can find their bar without scanning
+func (r *CMLRenderer) buildBarIndex() {
+	r.barIndex = make(map[int64]int, len(r.bars))
+	for i, bar := range r.bars {
+		key := bar.DateTime.UnixNano()
+		if _, exists := r.barIndex[key]; !exists {
+			r.barIndex[key] = i
+		}
+	}
+}
+
+// barAt returns the bar at exactly the given time, if there is one
+func (r *CMLRenderer) barAt(t time.Time) (cml.Bar, bool) {
+	i, ok := r.barIndex[t.UnixNano()]
+	if !ok {
+		return cml.Bar{}, false
+	}
+	return r.bars[i], true
+}
+
+// setupChart stores the chart and computes its layout
+func (r *CMLRenderer) setupChart(chart *cml.Chart) {
+	// Store chart and bars for later use
+	r.chart = chart
+	r.bars = chart.Bars
+	r.colors = r.parseTheme(chart.GetTheme())
+	r.buildBarIndex()
+
+	// A renderer draws many charts, so clear the last chart's palette
+	if chart.GetAutoColor() {
+		r.palette = NewColorCycler(r.getMetaValue(chart.Meta, "title"))
+	} else {
+		r.palette = nil
+	}
+
+	// Print mode styles lines in black and gray instead of auto colors
+	r.printLines = 0
+	if r.printMode() {
+		r.palette = nil
+	}
+
+	// Without bars there are no ranges to compute, only the plot area
+	if len(chart.Bars) == 0 {
+		r.layout = computeLayout(nil, r.marginLeft, r.marginTop, float64(r.Width)-r.marginRight, float64(r.Height)-r.marginBottom)
+		return
+	}
+
+	// Give the bottom of the plot area to the subpanels, if any
+	bottom, panels := layoutPanels(subpanels(chart), chart.GetPricePaneHeight(), r.marginTop, float64(r.Height)-r.marginBottom)
+
+	// Compute ranges, transforms, and ticks once for the whole render
+	levels := append(positionLevels(chart.Positions), quoteLevels(chart.Bars)...)
+	levels = append(levels, fanLevels(chart.Fan)...)
+	candles := r.candles(chart.Bars)
+	precision := chart.GetYAxisConfig().Precision
+	left, right := r.yAxisMargins(r.marginLeft)
+	r.layout = computeLayout(candles, left, r.marginTop, float64(r.Width)-right, bottom, levels...)
+	r.layout.formatPriceLabels(precision)
+
+	// Widen the price label margin when the widest label doesn't fit, like
+	// six-figure index values or five-decimal FX rates
+	if margin := math.Ceil(r.priceLabelWidth(r.layout)) + priceLabelGap + 4; margin > r.marginLeft {
+		left, right = r.yAxisMargins(margin)
+		r.layout = computeLayout(candles, left, r.marginTop, float64(r.Width)-right, bottom, levels...)
+		r.layout.formatPriceLabels(precision)
+	}
+	// Leave room past the last bar for the fan's projection
+	if end, ok := chart.Fan.End(); ok {
+		r.layout.extendTo(end, len(chart.Bars))
+	}
+	r.layout.formatTimeLabels()
+	if chart.GetBarType() == "renko" {
+		// Renko bricks sit in slots, not at times, so number them instead
+		r.layout.TimeTicks, r.layout.timeLabels = renkoTicks(len(chart.Bars))
+	}
+	r.layout.Panels = panels
+}
+
+// drawBackground clears the canvas and frames the plot area
+func (r *CMLRenderer) drawBackground() {
+	r.dc.SetColor(r.colors.background)
+	r.dc.Clear()
+	if len(r.bars) == 0 {
+		return
+	}
+
+	frame := r.chart.GetFrame()
+	if frame == "none" {
+		return
+	}
+
+	width := r.chart.GetFrameWidth()
+	if width == 0 {
+		width = 1
+		if r.printMode() {
+			width = printFrameWidth
+		}
+	}
+
+	l := r.layout
+	r.dc.SetColor(r.parseColor(r.chart.GetFrameColor()))
+	r.dc.SetLineWidth(width)
+	r.drawFrame(frame, l.Top, l.Bottom, width)
+	for _, panel := range l.Panels {
+		r.drawFrame(frame, panel.Top, panel.Bottom, width)
+	}
+	r.dc.Stroke()
+}
+
+// drawFrame adds the outline of a panel spanning top to bottom to the current
+// path: a full rectangle, or only the left and bottom axis lines for "axes"
+func (r *CMLRenderer) drawFrame(frame string, top, bottom, width float64) {
+	left, right := r.layout.Left, r.layout.Right
+	if r.chart.GetCrispLines() {
+		left, right = snapToPixels(left, width), snapToPixels(right, width)
+		top, bottom = snapToPixels(top, width), snapToPixels(bottom, width)
+	}
+	if frame == "axes" {
+		r.dc.MoveTo(left, top)
+		r.dc.LineTo(left, bottom)
+		r.dc.LineTo(right, bottom)
+		return
+	}
+	r.dc.DrawRectangle(left, top, right-left, bottom-top)
+}
+
+// drawGrid draws the configurable price and time grid lines
+func (r *CMLRenderer) drawGrid() {
+	if len(r.bars) == 0 {
+		return
+	}
+
+	l := r.layout
+	gridConfig := r.chart.GetGridConfig()
+	if gridConfig.Enabled {
+		r.dc.SetColor(r.withOpacity(r.parseColor(gridConfig.Color), gridConfig.Opacity))
+		r.dc.SetLineWidth(gridConfig.LineWidth)
+		snap := func(v float64) float64 { return v }
+		if r.chart.GetCrispLines() {
+			snap = func(v float64) float64 { return snapToPixels(v, gridConfig.LineWidth) }
+		}
+
+		// Horizontal grid lines (price levels)
+		for _, price := range l.PriceTicks {
+			y := snap(l.Y(price))
+			r.dc.DrawLine(l.Left, y, l.Right, y)
+		}
+
+		// Vertical grid lines (time levels) - match X-axis ticks exactly
+		for _, t := range l.TimeTicks {
+			x := snap(l.X(t))
+			r.dc.DrawLine(x, l.Top, x, l.Bottom)
+			for _, panel := range l.Panels {
+				r.dc.DrawLine(x, panel.Top, x, panel.Bottom)
+			}
+		}
+
+		// Subpanel midlines
+		for _, panel := range l.Panels {
+			y := snap((panel.Top + panel.Bottom) / 2)
+			r.dc.DrawLine(l.Left, y, l.Right, y)
+		}
+
+		r.dc.Stroke()
+	}
+}
+
+// renderBars renders OHLC bars
+func (r *CMLRenderer) renderBars(bars []cml.Bar) {
+	if len(bars) == 0 {
+		return
+	}
+
+	if r.chart.GetBarType() == "footprint" {
+		r.renderFootprintBars(bars)
+		return
+	}
+
+	// Heikin-Ashi charts draw smoothed candles in place of the bars, keeping
+	// the bars themselves for hit testing
+	raw := bars
+	bars = r.candles(bars)
+
+	// Calculate bar width
+	l := r.layout
+	barWidth := l.barSlot(len(bars)) * 0.6
+
+	// Bar opacity is the same for every bar
+	barOpacityConfig := r.chart.GetBarOpacityConfig()
+	opacity := uint8(255 * barOpacityConfig.Opacity)
+
+	// Outlines swamp bodies narrower than a few pixels, so "auto" drops them
+	border := r.chart.GetCandleBorder()
+	if border.Auto && barWidth < 3 {
+		border.Width = 0
+	}
+	wickColor := r.parseColor(r.chart.GetWickColor())
+	ruleColors := memo(r, "bar-color", func() []color.RGBA { return r.barRuleColors(raw) })
+
+	// Print mode tells up from down by hollow and filled bodies, so they need
+	// outlines; OHLC bars tell them apart by where the ticks sit
+	ohlc := r.chart.GetBarType() == "ohlc"
+	printing := r.printMode()
+	if printing {
+		border.Width = math.Max(border.Width, 1)
+	}
+
+	// OHLC bars' lines thicken with the bars. Crisp lines snap bars to whole
+	// pixels, which takes whole-pixel line widths.
+	lineWidth := math.Min(math.Max(barWidth/5, 1), 3)
+	crisp := r.chart.GetCrispLines()
+	if crisp {
+		lineWidth = math.Round(lineWidth)
+		border.Width = math.Round(border.Width)
+	}
+
+	// Bars are drawn in batches of neighbors: within a batch each kind of
+	// line (upper and lower wicks) is one stroke, the bodies one fill per
+	// color, and the borders one more stroke, or for OHLC bars one stroke per
+	// color, instead of several strokes and fills per bar. Bars don't overlap, so this looks
+	// the same as drawing bar by bar, except that where crowded bars touch,
+	// borders now sit on top of the neighboring body. Batches stay small
+	// because the rasterizer slows down on paths spanning many bars.
+	shapes := make([]barShape, 0, barBatchSize)
+	var bodyColors []color.Color
+	bodiesByColor := map[color.Color][]int{}
+	for start := 0; start < len(bars); start += barBatchSize {
+		batch := bars[start:min(start+barBatchSize, len(bars))]
+		shapes = shapes[:0]
+		bodyColors = bodyColors[:0]
+		clear(bodiesByColor)
+
+		for i, bar := range batch {
+			// Convert prices to screen coordinates
+			shape := barShape{x: l.X(bar.DateTime), highY: l.Y(bar.High), lowY: l.Y(bar.Low), openY: l.Y(bar.Open), closeY: l.Y(bar.Close)}
+			if r.CollectRegions {
+				r.addBarRegion(raw[start+i], shape.x, shape.highY, shape.lowY, barWidth)
+			}
+			shape.bodyTop = math.Min(shape.openY, shape.closeY)
+			shape.bodyBottom = math.Max(shape.openY, shape.closeY)
+			shape.bodyLeft, shape.bodyRight = shape.x-barWidth/2, shape.x+barWidth/2
+			if crisp && ohlc {
+				shape.snapOHLC(lineWidth)
+			} else if crisp {
+				shape.snapCandle(barWidth, border.Width)
+			}
+			shapes = append(shapes, shape)
+
+			bodyColor := r.barBodyColor(bar, start+i, ruleColors, opacity, printing)
+			if ohlc {
+				bodyColor = r.barLineColor(bar, start+i, ruleColors, opacity, printing)
+			}
+			if _, seen := bodiesByColor[bodyColor]; !seen {
+				bodyColors = append(bodyColors, bodyColor)
+			}
+			bodiesByColor[bodyColor] = append(bodiesByColor[bodyColor], i)
+		}
+
+		if ohlc {
+			r.drawOHLCBars(shapes, bodyColors, bodiesByColor, barWidth, lineWidth)
+		} else {
+			r.drawCandles(shapes, bodyColors, bodiesByColor, wickColor, border.Width)
+		}
+	}
+}
+
+// drawCandles draws a batch of candlesticks: the wicks as one stroke each
+// above and below the bodies, the bodies as one fill per color in the order
+// the colors first appear, and then their borders
+func (r *CMLRenderer) drawCandles(shapes []barShape, bodyColors []color.Color, bodiesByColor map[color.Color][]int, wickColor color.Color, borderWidth float64) {
+	r.dc.SetColor(wickColor)
+	r.dc.SetLineWidth(1)
+
+	// Upper wicks (from high to body top)
+	for _, shape := range shapes {
+		if shape.highY < shape.bodyTop {
+			r.dc.DrawLine(shape.x, shape.highY, shape.x, shape.bodyTop)
+		}
+	}
+	r.dc.Stroke()
+
+	// Lower wicks (from low to body bottom)
+	for _, shape := range shapes {
+		if shape.lowY > shape.bodyBottom {
+			r.dc.DrawLine(shape.x, shape.lowY, shape.x, shape.bodyBottom)
+		}
+	}
+	r.dc.Stroke()
+
+	for _, bodyColor := range bodyColors {
+		r.dc.SetColor(bodyColor)
+		for _, i := range bodiesByColor[bodyColor] {
+			r.drawBarBody(shapes[i])
+		}
+		r.dc.Fill()
+	}
+
+	if borderWidth > 0 {
+		r.dc.SetColor(r.colors.wick)
+		r.dc.SetLineWidth(borderWidth)
+		for _, shape := range shapes {
+			r.drawBarBody(shape)
+		}
+		r.dc.Stroke()
+	}
+}
+
+// drawOHLCBars draws a batch of classic OHLC bars, one stroke per color: a
+// high-low line with the open ticked to its left and the close to its right
+func (r *CMLRenderer) drawOHLCBars(shapes []barShape, barColors []color.Color, barsByColor map[color.Color][]int, barWidth, lineWidth float64) {
+	tick := math.Max(barWidth/3, lineWidth)
+	r.dc.SetLineWidth(lineWidth)
+	r.dc.SetLineCap(gg.LineCapButt)
+	defer r.dc.SetLineCap(gg.LineCapRound)
+
+	for _, barColor := range barColors {
+		r.dc.SetColor(barColor)
+		for _, i := range barsByColor[barColor] {
+			shape := shapes[i]
+			// Extend the range by half the line width so the ticks meet its ends
+			r.dc.DrawLine(shape.x, shape.highY-lineWidth/2, shape.x, math.Max(shape.lowY, shape.highY+1)+lineWidth/2)
+			r.dc.DrawLine(shape.x-tick, shape.openY, shape.x, shape.openY)
+			r.dc.DrawLine(shape.x, shape.closeY, shape.x+tick, shape.closeY)
+		}
+		r.dc.Stroke()
+	}
+}
+
+// barShape is a bar's screen x, the screen y of its prices, and its body edges
+type barShape struct {
+	x, highY, lowY, openY, closeY float64
+	bodyTop, bodyBottom           float64
+	bodyLeft, bodyRight           float64
+}
+
+// drawBarBody adds a bar's open-close body rectangle to the current path,
+// at least a pixel tall so flat bars stay visible
+func (r *CMLRenderer) drawBarBody(shape barShape) {
+	r.dc.DrawRectangle(shape.bodyLeft, shape.bodyTop, shape.bodyRight-shape.bodyLeft, math.Max(shape.bodyBottom-shape.bodyTop, 1))
+}
+
+// candles returns the bars as drawn: their Heikin-Ashi candles when the
+// bar-type is heikin-ashi, otherwise the bars themselves
+func (r *CMLRenderer) candles(bars []cml.Bar) []cml.Bar {
+	if r.chart.GetBarType() != "heikin-ashi" {
+		return bars
+	}
+	return memo(r, "heikin-ashi", func() []cml.Bar { return computeHeikinAshi(bars) })
+}
+
+// barBatchSize is how many neighboring bars renderBars draws per batch
+const barBatchSize = 64
+
+// barBodyColor chooses a bar's body color: its explicit or tag color, then
+// its bar-color rule color, then print mode's hollow or filled body, then
+// green or red by open vs close, all at the bar opacity
+func (r *CMLRenderer) barBodyColor(bar cml.Bar, i int, ruleColors []color.RGBA, opacity uint8, printing bool) color.Color {
+	if override := r.barColorOverride(bar); override != "" {
+		return barOpacity(r.parseColor(override), opacity)
+	}
+	if ruleColors != nil && ruleColors[i].A != 0 {
+		ruleColor := ruleColors[i]
+		ruleColor.A = opacity
+		return ruleColor
+	}
+	if printing {
+		return printCandleColor(bar, opacity)
+	}
+	if bar.Close >= bar.Open {
+		return barOpacity(r.colors.up, opacity)
+	}
+	return barOpacity(r.colors.down, opacity)
+}
+
+// barOpacity applies the bar opacity to a bar color, giving it alpha × bar
+// opacity like withOpacity
+func barOpacity(c color.Color, opacity uint8) color.Color {
+	return withAlpha(c, opacity)
+}
+
+// barLineColor chooses an OHLC bar's color like barBodyColor, except that in
+// print mode bars without an explicit or rule color are black
+func (r *CMLRenderer) barLineColor(bar cml.Bar, i int, ruleColors []color.RGBA, opacity uint8, printing bool) color.Color {
+	if printing && r.barColorOverride(bar) == "" && (ruleColors == nil || ruleColors[i].A == 0) {
+		return color.RGBA{0, 0, 0, opacity}
+	}
+	return r.barBodyColor(bar, i, ruleColors, opacity, false)
+}
+
+// barColorOverride returns a bar's explicit color, or its tag's color from
+// the bar-tags setting, or "" when the bar uses the default up/down colors
+func (r *CMLRenderer) barColorOverride(bar cml.Bar) string {
+	if bar.Color != "" {
+		return bar.Color
+	}
+	if bar.Tag != "" {
+		return r.chart.GetBarTagsConfig().Colors[bar.Tag]
+	}
+	return ""
+}
+
+// renderBarTagLegend draws a tag -> color legend in the top-left corner of
+// the chart for tags that are both declared and used
+func (r *CMLRenderer) renderBarTagLegend() {
+	if r.chart == nil {
+		return
+	}
+
+	tagsConfig := r.chart.GetBarTagsConfig()
+	used := map[string]bool{}
+	for _, bar := range r.bars {
+		used[bar.Tag] = true
+	}
+
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	x := r.layout.Left + 10
+	y := r.layout.Top + 10
+	for _, tag := range tagsConfig.Order {
+		if !used[tag] {
+			continue
+		}
+
+		r.dc.SetColor(r.parseColor(tagsConfig.Colors[tag]))
+		r.dc.DrawRectangle(x, y, 10, 10)
+		r.dc.Fill()
+		r.dc.SetColor(r.colors.text)
+		r.dc.SetLineWidth(1)
+		r.dc.DrawRectangle(x, y, 10, 10)
+		r.dc.Stroke()
+
+		r.dc.DrawStringAnchored(tag, x+16, y+5, 0.0, 0.5)
+		y += 16
+	}
+}
+
+// renderDrawing renders one drawing; id identifies it in image map regions
+// unless it has an id style, and group is the enclosing group's label.
+// Drawings shaded behind the bars are only rendered when behind is set, and
+// all others only when it isn't.
+func (r *CMLRenderer) renderDrawing(drawing cml.Drawing, id, group string, behind bool) {
+	if g, ok := drawing.(cml.Group); ok {
+		if r.chart.GetHiddenGroups()[g.Label] {
+			return
+		}
+		for i, member := range g.Drawings {
+			r.renderDrawing(member, fmt.Sprintf("%s.%d", id, i+1), g.Label, behind)
+		}
+		return
+	}
+
+	if _, highlight := drawing.(cml.HighlightBar); highlight != behind {
+		return
+	}
+
+	// Track the drawing's bounding box while it renders
+	if r.CollectRegions {
+		r.beginRegion()
+		defer func() {
+			region := ImageRegion{Kind: drawing.GetType(), ID: id, Group: group, drawing: drawing}
+			region.Class = "drawing drawing-" + drawing.GetType()
+			if group != "" {
+				region.Class += " group-" + classToken(group)
+			}
+			if styleID, ok := cml.DrawingStyles(drawing)["id"]; ok {
+				region.ID = fmt.Sprint(styleID)
+			}
+			if note, ok := drawing.(cml.Note); ok {
+				region.Label = note.Text
+			}
+			r.endRegion(region, isLineLike(drawing))
+		}()
+	}
+
+	// Line-like drawings without a border color take the next auto color
+	r.autoColor = nil
+	if r.palette != nil {
+		switch d := drawing.(type) {
+		case cml.Line:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.ContinuousLine:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.Ray:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.Trendline:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.Curve:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.Path:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		case cml.Trail:
+			r.autoColor = r.nextAutoColor(d.Styles)
+		}
+	}
+
+	switch d := drawing.(type) {
+	case cml.Rectangle:
+		r.renderRectangle(d)
+	case cml.Line:
+		r.renderLine(d)
+	case cml.ContinuousLine:
+		r.renderContinuousLine(d)
+	case cml.Curve:
+		r.renderCurve(d)
+	case cml.Ray:
+		r.renderRay(d)
+	case cml.Trendline:
+		r.renderTrendline(d)
+	case cml.Path:
+		r.renderPath(d)
+	case cml.Trail:
+		r.renderTrail(d)
+	case cml.Triangle:
+		r.renderTriangle(d)
+	case cml.Circle:
+		r.renderCircle(d)
+	case cml.Note:
+		r.renderNote(d)
+	case cml.HighlightBar:
+		r.renderHighlightBar(d)
+	case cml.RRBox:
+		r.renderRRBox(d)
+	case cml.Cone:
+		r.renderCone(d)
+	}
+}
+
+// renderHighlightBar shades the full height of a bar's slot
+func (r *CMLRenderer) renderHighlightBar(highlight cml.HighlightBar) {
+	l := r.layout
+	fillColor := r.getStyleColor(highlight.Styles, "fill-color", color.RGBA{255, 235, 59, 255})
+	fillOpacity := r.getStyleFloat(highlight.Styles, "fill-opacity", 0.3)
+
+	// A slot spans one bar interval centered on the bar
+	slot := l.Width() / float64(max(len(r.bars), 1))
+	if l.BarInterval > 0 {
+		slot = l.X(highlight.DateTime.Add(l.BarInterval)) - l.X(highlight.DateTime)
+	}
+	x := l.X(highlight.DateTime)
+	r.markRegion(x-slot/2, l.Top, 0)
+	r.markRegion(x+slot/2, l.Bottom, 0)
+
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+	r.dc.SetColor(r.withOpacity(fillColor, fillOpacity))
+	r.dc.DrawRectangle(x-slot/2, l.Top, slot, l.Height())
+	r.dc.Fill()
+}
+
+// renderRectangle renders a rectangle
+func (r *CMLRenderer) renderRectangle(rect cml.Rectangle) {
+	// Convert coordinates to screen space
+	x1, y1 := r.timePriceToScreen(rect.StartTime, rect.StartPrice)
+	x2, y2 := r.timePriceToScreen(rect.EndTime, rect.EndPrice)
+
+	// Get styles
+	borderColor := r.getStyleColor(rect.Styles, "border-color", color.RGBA{0, 0, 0, 255})
+	fillColor := r.getStyleColor(rect.Styles, "fill-color", color.RGBA{170, 170, 170, 128})
+	lineWidth := r.getStyleFloat(rect.Styles, "line-width", 1.0)
+	fillOpacity := r.getStyleFloat(rect.Styles, "fill-opacity", 0.3)
+	lineOpacity := r.getStyleFloat(rect.Styles, "line-opacity", 1.0)
+
+	// Don't apply opacity here - will be handled in NRGBA conversion
+
+	// Ensure proper rectangle dimensions (handle inverted Y coordinates)
+	rectX := math.Min(x1, x2)
+	rectY := math.Min(y1, y2)
+	rectWidth := math.Abs(x2 - x1)
+	rectHeight := math.Abs(y2 - y1)
+	r.markRegion(x1, y1, lineWidth/2)
+	r.markRegion(x2, y2, lineWidth/2)
+
+	// Draw rectangle with fill opacity
+	r.dc.SetColor(r.withOpacity(fillColor, fillOpacity))
+	r.dc.DrawRectangle(rectX, rectY, rectWidth, rectHeight)
+	r.dc.Fill()
+
+	// Draw border with line opacity
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+
+	r.dc.SetLineWidth(lineWidth)
+	r.dc.DrawRectangle(rectX, rectY, rectWidth, rectHeight)
+	r.dc.Stroke()
+}
+
+// renderLine renders a line
+func (r *CMLRenderer) renderLine(line cml.Line) {
+	// Convert coordinates to screen space
+	x1, y1 := r.timePriceToScreen(line.StartTime, line.StartPrice)
+	x2, y2 := r.timePriceToScreen(line.EndTime, line.EndPrice)
+
+	// Get styles
+	borderColor := r.getStyleColor(line.Styles, "border-color", color.RGBA{0, 0, 255, 255})
+	lineWidth := r.getStyleFloat(line.Styles, "line-width", 2.0)
+	lineOpacity := r.getStyleFloat(line.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(line.Styles, "style", "solid")
+
+	// Apply opacity to border color
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+
+	// Set line style
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5) // Small dots with even larger gaps
+	default: // solid
+		r.dc.SetDash() // Reset to solid
+	}
+
+	// Draw line
+	r.markRegion(x1, y1, lineWidth/2+3)
+	r.markRegion(x2, y2, lineWidth/2+3)
+	r.dc.DrawLine(x1, y1, x2, y2)
+	r.dc.Stroke()
+
+	// Add arrow if specified
+	if line.Arrow == "left-arrow" {
+		r.drawArrow(x1, y1, x2, y2, borderColor, "left")
+	} else if line.Arrow == "right-arrow" {
+		r.drawArrow(x1, y1, x2, y2, borderColor, "right")
+	} else if line.Arrow == "both-arrows" {
+		r.drawArrow(x1, y1, x2, y2, borderColor, "left")
+		r.drawArrow(x1, y1, x2, y2, borderColor, "right")
+	}
+}
+
+// renderContinuousLine renders a continuous line
+func (r *CMLRenderer) renderContinuousLine(line cml.ContinuousLine) {
+	// For continuous lines, extend to full chart width
+	x1 := r.layout.Left
+	x2 := r.layout.Right
+	y1 := r.layout.Y(line.StartPrice)
+	y2 := r.layout.Y(line.EndPrice)
+
+	// Get styles
+	borderColor := r.getStyleColor(line.Styles, "border-color", color.RGBA{0, 128, 0, 255})
+	lineWidth := r.getStyleFloat(line.Styles, "line-width", 1.0)
+	lineOpacity := r.getStyleFloat(line.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(line.Styles, "style", "solid")
+
+	// Apply opacity to border color
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+
+	// Set line style
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5) // Small dots with even larger gaps
+	default: // solid
+		r.dc.SetDash() // Reset to solid
+	}
+
+	r.markRegion(x1, y1, lineWidth/2+3)
+	r.markRegion(x2, y2, lineWidth/2+3)
+	r.dc.DrawLine(x1, y1, x2, y2)
+	r.dc.Stroke()
+
+	// The tag shows the level where the line meets the price axis
+	r.addAxisTag(line.Styles, line.StartPrice, borderColor)
+}
+
+// renderCurve renders a quadratic bezier connector
+func (r *CMLRenderer) renderCurve(curve cml.Curve) {
+	// Convert coordinates to screen space
+	x1, y1 := r.timePriceToScreen(curve.StartTime, curve.StartPrice)
+	x2, y2 := r.timePriceToScreen(curve.EndTime, curve.EndPrice)
+
+	// Get styles
+	borderColor := r.getStyleColor(curve.Styles, "border-color", color.RGBA{0, 0, 255, 255})
+	lineWidth := r.getStyleFloat(curve.Styles, "line-width", 2.0)
+	lineOpacity := r.getStyleFloat(curve.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(curve.Styles, "style", "solid")
+
+	// Place the control point on the perpendicular bisector of the chord,
+	// offset by curvature * chord length (positive bends to the left of travel)
+	dx := x2 - x1
+	dy := y2 - y1
+	cx := (x1+x2)/2 + dy*curve.Curvature
+	cy := (y1+y2)/2 - dx*curve.Curvature
+
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5)
+	default: // solid
+		r.dc.SetDash()
+	}
+
+	r.dc.MoveTo(x1, y1)
+	r.dc.QuadraticTo(cx, cy, x2, y2)
+	r.dc.Stroke()
+
+	// The curve's apex lies halfway between the chord midpoint and the control point
+	r.markRegion(x1, y1, lineWidth/2+3)
+	r.markRegion((x1+x2)/4+cx/2, (y1+y2)/4+cy/2, lineWidth/2+3)
+	r.markRegion(x2, y2, lineWidth/2+3)
+	r.dc.SetDash()
+
+	// Arrow heads follow the curve tangent at each end
+	if r.getStyleString(curve.Styles, "left-arrow", "false") == "true" {
+		r.drawArrow(x1, y1, cx, cy, borderColor, "left")
+	}
+	if r.getStyleString(curve.Styles, "right-arrow", "false") == "true" {
+		r.drawArrow(cx, cy, x2, y2, borderColor, "right")
+	}
+}
+
+// barsAhead counts the bars from t to the right edge of the chart, for slopes
+// given in price per bar: the bars after t, so weekends and session gaps don't
+// count, then bar intervals across the padding past the last bar
+func (r *CMLRenderer) barsAhead(t time.Time) float64 {
+	if len(r.bars) == 0 {
+		return 0
+	}
+	count := 0
+	last := r.bars[0].DateTime
+	for _, bar := range r.bars {
+		if bar.DateTime.After(t) {
+			count++
+		}
+		if bar.DateTime.After(last) {
+			last = bar.DateTime
+		}
+	}
+
+	ahead := float64(count)
+	if r.layout.BarInterval > 0 {
+		if t.After(last) {
+			last = t
+		}
+		ahead += float64(r.layout.MaxTime.Sub(last)) / float64(r.layout.BarInterval)
+	}
+	return ahead
+}
+
+// renderRay renders an anchor + slope trendline projected to the right edge
+func (r *CMLRenderer) renderRay(ray cml.Ray) {
+	// Project the slope (price per bar) out to the right edge of the chart
+	endTime := r.layout.MaxTime
+	endPrice := ray.Price + ray.Slope*r.barsAhead(ray.DateTime)
+
+	x1, y1 := r.timePriceToScreen(ray.DateTime, ray.Price)
+	x2, y2 := r.timePriceToScreen(endTime, endPrice)
+
+	// Get styles
+	borderColor := r.getStyleColor(ray.Styles, "border-color", color.RGBA{0, 0, 255, 255})
+	lineWidth := r.getStyleFloat(ray.Styles, "line-width", 1.0)
+	lineOpacity := r.getStyleFloat(ray.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(ray.Styles, "style", "solid")
+
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5)
+	default: // solid
+		r.dc.SetDash()
+	}
+
+	r.markRegion(x1, y1, lineWidth/2+3)
+	r.markRegion(x2, y2, lineWidth/2+3)
+
+	// A steep ray leaves the plot area before the right edge
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	r.dc.DrawLine(x1, y1, x2, y2)
+	r.dc.Stroke()
+	r.dc.SetDash()
+}
+
+// renderCone renders a projection cone: both edges projected from the anchor
+// to the right edge of the chart, with a translucent fill between them
+func (r *CMLRenderer) renderCone(cone cml.Cone) {
+	endTime := r.layout.MaxTime
+	barsAhead := r.barsAhead(cone.DateTime)
+
+	x0, y0 := r.timePriceToScreen(cone.DateTime, cone.Price)
+	x1, upperY := r.timePriceToScreen(endTime, cone.Price+cone.SlopeUp*barsAhead)
+	_, lowerY := r.timePriceToScreen(endTime, cone.Price+cone.SlopeDown*barsAhead)
+
+	// Get styles
+	borderColor := r.getStyleColor(cone.Styles, "border-color", color.RGBA{100, 149, 237, 255})
+	fillColor := r.getStyleColor(cone.Styles, "fill-color", borderColor)
+	fillOpacity := r.getStyleFloat(cone.Styles, "fill-opacity", 0.2)
+	lineWidth := r.getStyleFloat(cone.Styles, "line-width", 1.0)
+	lineOpacity := r.getStyleFloat(cone.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(cone.Styles, "style", "solid")
+
+	r.markRegion(x0, y0, lineWidth/2)
+	r.markRegion(x1, upperY, lineWidth/2)
+	r.markRegion(x1, lowerY, lineWidth/2)
+
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	r.dc.MoveTo(x0, y0)
+	r.dc.LineTo(x1, upperY)
+	r.dc.LineTo(x1, lowerY)
+	r.dc.ClosePath()
+	r.dc.SetColor(r.withOpacity(fillColor, fillOpacity))
+	r.dc.Fill()
+
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+	r.setLineDash(lineStyle, lineWidth)
+	r.dc.DrawLine(x0, y0, x1, upperY)
+	r.dc.DrawLine(x0, y0, x1, lowerY)
+	r.dc.Stroke()
+	r.dc.SetDash()
+}
+
+// renderTrendline fits a least-squares line to bar prices within the
+// trendline's range and draws it
+func (r *CMLRenderer) renderTrendline(trendline cml.Trendline) {
+	// Collect (seconds since start, price) samples within the range
+	var xs, ys []float64
+	for _, bar := range r.bars {
+		if bar.DateTime.Before(trendline.StartTime) || bar.DateTime.After(trendline.EndTime) {
+			continue
+		}
+
+		price := bar.Close
+		switch trendline.Fit {
+		case "high":
+			price = bar.High
+		case "low":
+			price = bar.Low
+		}
+
+		xs = append(xs, bar.DateTime.Sub(trendline.StartTime).Seconds())
+		ys = append(ys, price)
+	}
+
+	// A line needs at least two distinct points
+	if len(xs) < 2 {
+		return
+	}
+
+	slope, intercept, ok := leastSquares(xs, ys)
+	if !ok {
+		return
+	}
+
+	startTime := trendline.StartTime
+	endTime := trendline.EndTime
+	if trendline.Extend == "left" || trendline.Extend == "both" {
+		startTime = r.layout.MinTime
+	}
+	if trendline.Extend == "right" || trendline.Extend == "both" {
+		endTime = r.layout.MaxTime
+	}
+
+	startOffset := startTime.Sub(trendline.StartTime).Seconds()
+	endOffset := endTime.Sub(trendline.StartTime).Seconds()
+	x1, y1 := r.timePriceToScreen(startTime, intercept+slope*startOffset)
+	x2, y2 := r.timePriceToScreen(endTime, intercept+slope*endOffset)
+
+	// Get styles
+	borderColor := r.getStyleColor(trendline.Styles, "border-color", color.RGBA{0, 0, 255, 255})
+	lineWidth := r.getStyleFloat(trendline.Styles, "line-width", 1.0)
+	lineOpacity := r.getStyleFloat(trendline.Styles, "line-opacity", 1.0)
+	lineStyle := r.getStyleString(trendline.Styles, "style", "solid")
+
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5)
+	default: // solid
+		r.dc.SetDash()
+	}
+
+	r.markRegion(x1, y1, lineWidth/2+3)
+	r.markRegion(x2, y2, lineWidth/2+3)
+	r.dc.DrawLine(x1, y1, x2, y2)
+	r.dc.Stroke()
+	r.dc.SetDash()
+}
+
+// leastSquares returns the slope and intercept of the ordinary least-squares
+// fit of ys against xs; ok is false when the xs have no spread
+func leastSquares(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, 0, false
+	}
+
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
+
+// renderPath renders a freeform polyline, optionally closed and filled
+func (r *CMLRenderer) renderPath(path cml.Path) {
+	if len(path.Points) < 2 {
+		return
+	}
+
+	// Get styles
+	borderColor := r.getStyleColor(path.Styles, "border-color", color.RGBA{0, 0, 255, 255})
+	fillColor := r.getStyleColor(path.Styles, "fill-color", color.RGBA{170, 170, 170, 255})
+	lineWidth := r.getStyleFloat(path.Styles, "line-width", 2.0)
+	lineOpacity := r.getStyleFloat(path.Styles, "line-opacity", 1.0)
+	fillOpacity := r.getStyleFloat(path.Styles, "fill-opacity", 0.3)
+	lineStyle := r.getStyleString(path.Styles, "style", "solid")
+
+	// Only closed paths are filled, and only when a fill color is given
+	if path.Closed {
+		if _, ok := path.Styles["fill-color"]; ok {
+			r.tracePath(path.Points, true)
+			r.dc.SetColor(r.withOpacity(fillColor, fillOpacity))
+			r.dc.Fill()
+		}
+	}
+
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+
+	// Apply line style (dashed/dotted)
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5)
+	default: // solid
+		r.dc.SetDash()
+	}
+
+	r.tracePath(path.Points, path.Closed)
+	r.dc.Stroke()
+	r.dc.SetDash()
+}
+
+// renderTrail renders a trailing stop as a step line: each stop price holds
+// until the next point's time, then steps to the next stop
+func (r *CMLRenderer) renderTrail(trail cml.Trail) {
+	if len(trail.Points) == 0 {
+		return
+	}
+
+	borderColor := r.getStyleColor(trail.Styles, "border-color", positionStopColor)
+	lineWidth := r.getStyleFloat(trail.Styles, "line-width", 1.5)
+	lineOpacity := r.getStyleFloat(trail.Styles, "line-opacity", 1.0)
+	r.dc.SetColor(r.withOpacity(borderColor, lineOpacity))
+	r.dc.SetLineWidth(lineWidth)
+	r.setLineDash(r.getStyleString(trail.Styles, "style", "solid"), lineWidth)
+
+	r.dc.NewSubPath()
+	r.newRegionPath()
+	for i, point := range trail.Points {
+		x, y := r.timePriceToScreen(point.Time, point.Price)
+		if i == 0 {
+			r.dc.MoveTo(x, y)
+		} else {
+			_, prevY := r.timePriceToScreen(trail.Points[i-1].Time, trail.Points[i-1].Price)
+			r.dc.LineTo(x, prevY)
+			r.markRegion(x, prevY, 3)
+			r.dc.LineTo(x, y)
+		}
+		r.markRegion(x, y, 3)
+	}
+	if trail.Extend {
+		last := trail.Points[len(trail.Points)-1]
+		_, y := r.timePriceToScreen(last.Time, last.Price)
+		r.dc.LineTo(r.layout.Right, y)
+		r.markRegion(r.layout.Right, y, 3)
+	}
+	r.dc.Stroke()
+	r.dc.SetDash()
+}
+
+// tracePath builds (but does not stroke or fill) a polyline through the
+// given time/price points
+func (r *CMLRenderer) tracePath(points []cml.PathPoint, closed bool) {
+	r.dc.NewSubPath()
+	r.newRegionPath()
+	for i, point := range points {
+		x, y := r.timePriceToScreen(point.Time, point.Price)
+		r.markRegion(x, y, 3)
+		if i == 0 {
+			r.dc.MoveTo(x, y)
+		} else {
+			r.dc.LineTo(x, y)
+		}
+	}
+	if closed {
+		r.dc.ClosePath()
+		if len(points) > 0 {
+			x, y := r.timePriceToScreen(points[0].Time, points[0].Price)
+			r.markRegion(x, y, 3)
+		}
+	}
+}
+
+// renderTriangle renders a triangle marker
+func (r *CMLRenderer) renderTriangle(triangle cml.Triangle) {
+	// Find the price at this time by looking at the bars
+	var price float64
+	found := false
+
+	// Try to find the exact bar at this time
+	if bar, ok := r.barAt(triangle.DateTime); ok {
+		if triangle.Direction == "uptick" {
+			price = bar.Low // Place uptick triangle below the price (at low)
+		} else {
+			price = bar.High // Place downtick triangle above the price (at high)
+		}
+		found = true
+	}
+
+	// If not found, use a reasonable default
+	if !found {
+		if triangle.Direction == "uptick" {
+			price = r.layout.PriceAt(0.1) // Near the bottom
+		} else {
+			price = r.layout.PriceAt(0.9) // Near the top
+		}
+	}
+
+	x, y := r.timePriceToScreen(triangle.DateTime, price)
+
+	borderColor := r.getStyleColor(triangle.Styles, "border-color", color.RGBA{0, 0, 0, 255})
+	fillColor := r.getStyleColor(triangle.Styles, "fill-color", color.RGBA{170, 170, 170, 255})
+
+	// Draw triangle
+	size := 8.0
+	if triangle.Direction == "uptick" {
+		// Upward triangle - positioned below the price
+		r.dc.SetColor(fillColor)
+		r.dc.DrawRegularPolygon(3, x, y+size, size, 0)
+		r.dc.Fill()
+		r.dc.SetColor(borderColor)
+		r.dc.DrawRegularPolygon(3, x, y+size, size, 0)
+		r.dc.Stroke()
+		r.markRegion(x, y+size, size)
+	} else {
+		// Downward triangle - positioned above the price
+		r.dc.SetColor(fillColor)
+		r.dc.DrawRegularPolygon(3, x, y-size, size, math.Pi)
+		r.dc.Fill()
+		r.dc.SetColor(borderColor)
+		r.dc.DrawRegularPolygon(3, x, y-size, size, math.Pi)
+		r.dc.Stroke()
+		r.markRegion(x, y-size, size)
+	}
+
+	// Labels go on the far side of the triangle from the bar
+	if triangle.Direction == "uptick" {
+		r.drawMarkerLabel(triangle.Label, x, y, y+size*2, false, triangle.Styles)
+	} else {
+		r.drawMarkerLabel(triangle.Label, x, y-size*2, y, true, triangle.Styles)
+	}
+
+	_ = found // Suppress unused variable warning
+}
+
+// renderCircle renders a circle marker
+func (r *CMLRenderer) renderCircle(circle cml.Circle) {
+	// Find the price at this time by looking at the bars
+	var price float64
+	found := false
+
+	// Try to find the exact bar at this time
+	if bar, ok := r.barAt(circle.DateTime); ok {
+		price = (bar.High + bar.Low) / 2 // Use middle of the bar
+		found = true
+	}
+
+	// If not found, use a reasonable default
+	if !found {
+		price = r.layout.PriceAt(0.5) // Middle of price range
+	}
+
+	x, y := r.timePriceToScreen(circle.DateTime, price)
+
+	borderColor := r.getStyleColor(circle.Styles, "border-color", color.RGBA{0, 0, 0, 255})
+	fillColor := r.getStyleColor(circle.Styles, "fill-color", color.RGBA{255, 255, 0, 255})
+	lineWidth := r.getStyleFloat(circle.Styles, "line-width", 1.0)
+
+	radius := 6.0
+	r.markRegion(x, y, radius)
+
+	// Draw circle
+	r.dc.SetColor(fillColor)
+	r.dc.DrawCircle(x, y, radius)
+	r.dc.Fill()
+
+	r.dc.SetColor(borderColor)
+	r.dc.SetLineWidth(lineWidth)
+	r.dc.DrawCircle(x, y, radius)
+	r.dc.Stroke()
+
+	r.drawMarkerLabel(circle.Label, x, y-radius, y+radius, circle.Position == "over", circle.Styles)
+}
+
+// renderNote renders a text note
+func (r *CMLRenderer) renderNote(note cml.Note) {
+	// Find the price at this time by looking at the bars
+	var price float64
+	found := false
+
+	// Try to find the exact bar at this time
+	if bar, ok := r.barAt(note.DateTime); ok {
+		if note.Position == "over" {
+			price = bar.High // Place over note at the high
+		} else {
+			price = bar.Low // Place under note at the low
+		}
+		found = true
+	}
+
+	// If not found, use a reasonable default
+	if !found {
+		if note.Position == "over" {
+			price = r.layout.PriceAt(0.9) // Near the top
+		} else {
+			price = r.layout.PriceAt(0.1) // Near the bottom
+		}
+	}
+
+	x, y := r.timePriceToScreen(note.DateTime, price)
+
+	fontSize := r.getStyleFloat(note.Styles, "font-size", r.fontSizes().Note)
+	fontColor := r.getStyleColor(note.Styles, "font-color", r.colors.text)
+
+	// Set font
+	r.dc.SetColor(fontColor)
+	r.dc.SetFontFace(r.fontFace(fontSize))
+
+	// Draw text with proper positioning
+	offset := 15.0
+	textWidth, textHeight := r.dc.MeasureString(note.Text)
+	if note.Position == "over" {
+		r.dc.DrawStringAnchored(note.Text, x, y-offset, 0.5, 1.0)
+		r.markRegion(x-textWidth/2, y-offset-textHeight, 2)
+		r.markRegion(x+textWidth/2, y-offset, 2)
+		r.labelBoxes = append(r.labelBoxes, labelBox{x - textWidth/2, y - offset - textHeight, x + textWidth/2, y - offset})
+	} else {
+		r.dc.DrawStringAnchored(note.Text, x, y+offset, 0.5, 0.0)
+		r.markRegion(x-textWidth/2, y+offset, 2)
+		r.markRegion(x+textWidth/2, y+offset+textHeight, 2)
+		r.labelBoxes = append(r.labelBoxes, labelBox{x - textWidth/2, y + offset, x + textWidth/2, y + offset + textHeight})
+	}
+}
+
+// drawAxisLabels draws price labels on Y-axis and datetime labels on X-axis
+func (r *CMLRenderer) drawAxisLabels() {
+	// Set font for labels
+	r.dc.SetColor(r.colors.text)
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+
+	l := r.layout
+
+	// Draw Y-axis price labels beside the chart, level with their grid lines
+	for i, label := range l.priceLabels {
+		r.drawPriceLabel(label, l.Left, l.Right, l.Y(l.PriceTicks[i]))
+	}
+
+	// Name the unit prices were converted to above the price labels
+	if convert, ok := r.chart.GetConvertConfig(); ok && convert.Unit != "" {
+		r.drawPriceLabel(convert.Unit, l.Left, l.Right, l.Top-14)
+	}
+
+	// Draw X-axis datetime labels below the chart at the layout's tick times
+	for i, label := range l.timeLabels {
+		r.dc.DrawStringAnchored(label, l.X(l.TimeTicks[i]), l.AxisBottom()+20, 0.5, 0.0)
+	}
+}
+
+// renderIndicators renders technical indicators
+func (r *CMLRenderer) renderIndicators(indicators []cml.Indicator) {
+	if len(indicators) == 0 || len(r.bars) == 0 {
+		return
+	}
+
+	// Calculate and render each indicator, on the price scale or in its subpanel
+	for _, indicator := range indicators {
+		r.beginRegion()
+		switch indicator.Name {
+		case "ema":
+			if period, ok := indicator.Parameters["period"].(float64); ok {
+				r.renderEMA(int(period), r.seriesStyle(indicator.Parameters, color.RGBA{255, 0, 0, 200}, 2)) // Red
+			}
+		case "sma":
+			if period, ok := indicator.Parameters["period"].(float64); ok {
+				r.renderSMA(int(period), r.seriesStyle(indicator.Parameters, color.RGBA{0, 255, 0, 200}, 2)) // Green
+			}
+		case "bollinger":
+			if period, ok := indicator.Parameters["period"].(float64); ok {
+				if stddev, ok := indicator.Parameters["stddev"].(float64); ok {
+					r.renderBollingerBands(int(period), stddev, r.seriesStyle(indicator.Parameters, color.RGBA{0, 0, 255, 150}, 1)) // Blue
+				}
+			}
+		case "seasonal":
+			style := r.seriesStyle(indicator.Parameters, color.RGBA{128, 0, 128, 200}, 1.5) // Purple
+			if style.Dash == "" {
+				style.Dash = "dashed"
+			}
+			r.renderSeasonal(cml.IndicatorSource(indicator.Parameters), seasonalPeriod(indicator.Parameters), style)
+		case "hhll":
+			if period, ok := indicator.Parameters["period"].(float64); ok {
+				style := r.seriesStyle(indicator.Parameters, color.RGBA{0, 128, 128, 255}, 1.5) // Teal
+				if style.Render == "" {
+					style.Render = "step"
+				}
+				r.renderHighLow(int(period), indicator.Parameters["markers"] == "true", style)
+			}
+		case "bands":
+			r.renderBands(indicator.Parameters, r.seriesStyle(indicator.Parameters, color.RGBA{70, 70, 180, 255}, 1)) // Slate blue
+		case "chandelier":
+			style := r.seriesStyle(indicator.Parameters, color.RGBA{180, 30, 30, 255}, 1.5) // Dark red
+			if style.Render == "" {
+				style.Render = "step"
+			}
+			r.renderChandelier(indicator.Parameters, style)
+		case "relative-strength":
+			r.renderRelativeStrength(indicator, r.seriesStyle(indicator.Parameters, color.RGBA{255, 140, 0, 255}, 1.5)) // Orange
+		case "rsi":
+			r.renderRSI(indicator, r.seriesStyle(indicator.Parameters, color.RGBA{128, 0, 128, 255}, 1.5)) // Purple
+		case "macd":
+			r.renderMACD(indicator, r.seriesStyle(indicator.Parameters, color.RGBA{0, 90, 200, 255}, 1.5)) // Blue
+		}
+		r.endRegion(ImageRegion{
+			Kind:  "indicator",
+			ID:    indicatorElementID(indicator),
+			Class: "indicator indicator-" + classToken(indicator.Name),
+		}, true)
+	}
+}
+
+// renderHighLow renders the rolling highest-high and lowest-low lines, with
+// a triangle at each bar that closes beyond the previous bar's lines when
+// markers is set
+func (r *CMLRenderer) renderHighLow(period int, markers bool, style SeriesStyle) {
+	if period < 1 || len(r.bars) < period {
+		return
+	}
+
+	lines := memo(r, fmt.Sprint("hhll:", period), func() [2][]float64 {
+		highest, lowest := computeHighLow(r.bars, period)
+		return [2][]float64{highest, lowest}
+	})
+	highest, lowest := lines[0], lines[1]
+	r.strokeSeries(highest, period-1, style)
+	r.strokeSeries(lowest, period-1, style)
+	if !markers {
+		return
+	}
+
+	size := 5.0
+	for i, breakout := range computeBreakouts(r.bars, highest, lowest, period) {
+		x := r.layout.X(r.bars[i].DateTime)
+		switch breakout {
+		case 1:
+			r.dc.SetColor(barRuleUpColor)
+			r.dc.DrawRegularPolygon(3, x, r.layout.Y(r.bars[i].Low)+size*2, size, 0)
+		case -1:
+			r.dc.SetColor(barRuleDownColor)
+			r.dc.DrawRegularPolygon(3, x, r.layout.Y(r.bars[i].High)-size*2, size, math.Pi)
+		default:
+			continue
+		}
+		r.dc.Fill()
+	}
+}
+
+// renderChandelier renders the chandelier exit below the highs for longs,
+// above the lows for shorts, or both
+func (r *CMLRenderer) renderChandelier(params map[string]interface{}, style SeriesStyle) {
+	period, multiplier, side := cml.ChandelierParams(params)
+	if len(r.bars) < period {
+		return
+	}
+
+	exits := memo(r, fmt.Sprint("chandelier:", period, ":", multiplier), func() [2][]float64 {
+		long, short := computeChandelier(r.bars, period, multiplier)
+		return [2][]float64{long, short}
+	})
+	long, short := exits[0], exits[1]
+	if side != "short" {
+		r.strokeSeries(long, period-1, style)
+	}
+	if side != "long" {
+		r.strokeSeries(short, period-1, style)
+	}
+}
+
+// renderBands renders upper and lower bands around a bar price or a series
+func (r *CMLRenderer) renderBands(params map[string]interface{}, style SeriesStyle) {
+	period, width, bandType := cml.BandsParams(params)
+	times, values := r.bandsSource(params)
+	key := fmt.Sprint("bands:", cml.IndicatorSource(params), ":", period, ":", width, ":", bandType)
+	bands := memo(r, key, func() bandsResult {
+		upper, lower, first := computeBands(times, values, r.bars, period, width, bandType)
+		return bandsResult{upper, lower, first}
+	})
+	upper, lower, first := bands.upper, bands.lower, bands.first
+	if first >= len(values) {
+		return
+	}
+	r.drawSeries(times, upper, first, style)
+	r.drawSeries(times, lower, first, style)
+}
+
+// bandsResult holds computed bands for the compute cache
+type bandsResult struct {
+	upper, lower []float64
+	first        int
+}
+
+// bandsSource returns the times and values a bands indicator surrounds: a
+// bar price, or the points of the named series
+func (r *CMLRenderer) bandsSource(params map[string]interface{}) ([]time.Time, []float64) {
+	source := cml.IndicatorSource(params)
+	var times []time.Time
+	var values []float64
+	if cml.IsPriceSource(source) {
+		for _, bar := range r.bars {
+			times = append(times, bar.DateTime)
+			values = append(values, sourcePrice(bar, source))
+		}
+	} else if series, ok := r.chart.GetSeries(source); ok {
+		for _, point := range series.Points {
+			times = append(times, point.DateTime)
+			values = append(values, point.Value)
+		}
+	}
+	return times, values
+}
+
+// renderEMA renders Exponential Moving Average
+func (r *CMLRenderer) renderEMA(period int, style SeriesStyle) {
+	if len(r.bars) < period {
+		return
+	}
+
+	ema := memo(r, fmt.Sprint("ema:", period), func() []float64 { return computeEMA(r.bars, period) })
+	r.strokeSeries(ema, 0, style)
+}
+
+// renderSMA renders Simple Moving Average
+func (r *CMLRenderer) renderSMA(period int, style SeriesStyle) {
+	if len(r.bars) < period {
+		return
+	}
+
+	sma := memo(r, fmt.Sprint("sma:", period), func() []float64 { return computeSMA(r.bars, period) })
+	r.strokeSeries(sma, period-1, style)
+}
+
+// renderBollingerBands renders Bollinger Bands
+func (r *CMLRenderer) renderBollingerBands(period int, stddev float64, style SeriesStyle) {
+	if len(r.bars) < period {
+		return
+	}
+
+	bands := memo(r, fmt.Sprint("bollinger:", period, ":", stddev), func() [3][]float64 {
+		upper, middle, lower := computeBollinger(r.bars, period, stddev)
+		return [3][]float64{upper, middle, lower}
+	})
+	upper, middle, lower := bands[0], bands[1], bands[2]
+	r.strokeSeries(upper, period-1, style)
+	r.strokeSeries(middle, period-1, style)
+	r.strokeSeries(lower, period-1, style)
+}
+
+// renderSeasonal renders the average seasonal path of the bars
+func (r *CMLRenderer) renderSeasonal(source, period string, style SeriesStyle) {
+	seasonal := memo(r, "seasonal:"+source+":"+period, func() []float64 { return computeSeasonal(r.bars, source, period) })
+	if seasonal != nil {
+		r.strokeSeries(seasonal, 0, style)
+	}
+}
+
+// SeriesStyle describes how a computed series is drawn
+type SeriesStyle struct {
+	Color     color.Color
+	LineWidth float64
+	Smooth    int     // Display-only moving-average window (0 or 1 disables)
+	Render    string  // "line" (default), "step", "histogram", "points", "error-bars", or "band"
+	Baseline  float64 // Value histogram columns grow from
+	PointSize float64 // Marker radius in points mode
+	Shape     string  // Marker shape in points mode
+	Dash      string  // Line style: "solid" (default), "dashed", or "dotted"
+
+	// Baseline coloring: parts above/below Baseline use these colors when set
+	AboveColor  color.Color
+	BelowColor  color.Color
+	FillOpacity float64 // Fills the area between the line and Baseline when > 0
+}
+
+// seriesStyle builds the series style for an indicator or series from its
+// parameters, falling back to the given defaults
+func (r *CMLRenderer) seriesStyle(params map[string]interface{}, defaultColor color.Color, defaultWidth float64) SeriesStyle {
+	style := SeriesStyle{
+		Color:     r.paramColor(params, defaultColor),
+		LineWidth: defaultWidth,
+		PointSize: 3.0,
+		Shape:     "circle",
+	}
+
+	if width, ok := params["line-width"].(float64); ok {
+		style.LineWidth = width
+	}
+	if smooth, ok := params["display-smooth"].(float64); ok {
+		style.Smooth = int(smooth)
+	}
+	if render, ok := params["render"].(string); ok {
+		style.Render = render
+	}
+	if baseline, ok := params["baseline"].(float64); ok {
+		style.Baseline = baseline
+	}
+	if size, ok := params["point-size"].(float64); ok {
+		style.PointSize = size
+	}
+	if shape, ok := params["point-shape"].(string); ok {
+		style.Shape = shape
+	}
+	if above, ok := params["above-color"].(string); ok {
+		style.AboveColor = r.parseColor(above)
+	}
+	if below, ok := params["below-color"].(string); ok {
+		style.BelowColor = r.parseColor(below)
+	}
+	if opacity, ok := params["fill-opacity"].(float64); ok {
+		style.FillOpacity = opacity
+	}
+	if dash, ok := params["style"].(string); ok {
+		style.Dash = dash
+	}
+	if r.printMode() {
+		r.applyPrintStyle(params, &style)
+	}
+
+	return style
+}
+
+// setLineDash applies a named line style, scaled to the line width
+func (r *CMLRenderer) setLineDash(lineStyle string, lineWidth float64) {
+	switch lineStyle {
+	case "dashed":
+		r.dc.SetDash(lineWidth*2, lineWidth*2)
+	case "dotted":
+		r.dc.SetDash(lineWidth*0.5, lineWidth*2.5)
+	default: // solid
+		r.dc.SetDash()
+	}
+}
+
+// renderSeries renders a user-supplied data series
+func (r *CMLRenderer) renderSeries(series cml.Series) {
+	times := make([]time.Time, len(series.Points))
+	values := make([]float64, len(series.Points))
+	for i, point := range series.Points {
+		times[i] = point.DateTime
+		values[i] = point.Value
+	}
+
+	style := r.seriesStyle(series.Parameters, color.RGBA{0, 0, 255, 200}, 2)
+	r.beginRegion()
+	switch style.Render {
+	case "error-bars":
+		r.drawErrorBars(series.Points, style)
+	case "band":
+		// fill-opacity shades the band, not the area under the line
+		r.drawBoundsBand(series.Points, style)
+		style.Render, style.FillOpacity = "line", 0
+		r.drawSeries(times, values, 0, style)
+	default:
+		r.drawSeries(times, values, 0, style)
+	}
+	r.endRegion(ImageRegion{Kind: "series", ID: "series-" + series.Name, Class: "series"}, true)
+}
+
+// strokeSeries draws a bar-aligned series, starting at the first valid index
+func (r *CMLRenderer) strokeSeries(values []float64, first int, style SeriesStyle) {
+	times := make([]time.Time, len(r.bars))
+	for i, bar := range r.bars {
+		times[i] = bar.DateTime
+	}
+
+	r.drawSeries(times, values, first, style)
+}
+
+// drawSeries draws a series of time/value points in the style's render mode
+func (r *CMLRenderer) drawSeries(times []time.Time, values []float64, first int, style SeriesStyle) {
+	if first < 0 {
+		first = 0
+	}
+	if len(values) > len(times) {
+		values = values[:len(times)]
+	}
+
+	// Keep series inside the chart area
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	switch style.Render {
+	case "histogram":
+		r.drawHistogram(times, values, first, style)
+		return
+	case "points":
+		r.drawPoints(times, values, first, style)
+		return
+	}
+
+	if len(values)-first < 2 {
+		return
+	}
+
+	// Smoothing only affects what is drawn, never the computed values
+	values = smoothSeries(values, first, style.Smooth)
+	r.markSeries(times, values, first, style)
+
+	if style.AboveColor == nil && style.BelowColor == nil {
+		if style.FillOpacity > 0 {
+			r.traceSeries(times, values, first, style, true)
+			r.dc.SetColor(r.withOpacity(style.Color, style.FillOpacity))
+			r.dc.Fill()
+		}
+		r.traceSeries(times, values, first, style, false)
+		r.dc.SetColor(style.Color)
+		r.dc.SetLineWidth(style.LineWidth)
+		r.setLineDash(style.Dash, style.LineWidth)
+		r.dc.Stroke()
+		r.dc.SetDash()
+		return
+	}
+
+	// Baseline mode: draw the series twice, clipped to each side of the
+	// baseline, so the line (and area) changes color where it crosses
+	baseY := r.layout.Y(style.Baseline)
+	regions := []struct {
+		top, bottom float64
+		color       color.Color
+	}{
+		{0, baseY, style.AboveColor},
+		{baseY, float64(r.Height), style.BelowColor},
+	}
+
+	for _, region := range regions {
+		regionColor := region.color
+		if regionColor == nil {
+			regionColor = style.Color
+		}
+
+		r.dc.ResetClip()
+		r.clipToChartArea()
+		r.dc.DrawRectangle(0, region.top, float64(r.Width), region.bottom-region.top)
+		r.dc.Clip()
+
+		if style.FillOpacity > 0 {
+			r.traceSeries(times, values, first, style, true)
+			r.dc.SetColor(r.withOpacity(regionColor, style.FillOpacity))
+			r.dc.Fill()
+		}
+		r.traceSeries(times, values, first, style, false)
+		r.dc.SetColor(regionColor)
+		r.dc.SetLineWidth(style.LineWidth)
+		r.setLineDash(style.Dash, style.LineWidth)
+		r.dc.Stroke()
+		r.dc.SetDash()
+	}
+}
+
+// markSeries records a series line in the current element region
+func (r *CMLRenderer) markSeries(times []time.Time, values []float64, first int, style SeriesStyle) {
+	if r.regionBox == nil {
+		return
+	}
+
+	r.newRegionPath()
+	pad := style.LineWidth/2 + 3
+	prevY := 0.0
+	for i := first; i < len(values); i++ {
+		x, y := r.timePriceToScreen(times[i], values[i])
+		if i > first && style.Render == "step" {
+			r.markRegion(x, prevY, pad)
+		}
+		r.markRegion(x, y, pad)
+		prevY = y
+	}
+}
+
+// traceSeries builds (but does not stroke or fill) the line path of a
+// series; with area set, the path is closed along the style's baseline
+func (r *CMLRenderer) traceSeries(times []time.Time, values []float64, first int, style SeriesStyle, area bool) {
+	r.dc.NewSubPath()
+
+	prevY := 0.0
+	firstX, lastX := 0.0, 0.0
+	for i := first; i < len(values); i++ {
+		x, y := r.timePriceToScreen(times[i], values[i])
+		if i == first {
+			r.dc.MoveTo(x, y)
+			firstX = x
+		} else if style.Render == "step" {
+			// Hold the previous value until this bar, then jump
+			r.dc.LineTo(x, prevY)
+			r.dc.LineTo(x, y)
+		} else {
+			r.dc.LineTo(x, y)
+		}
+		prevY = y
+		lastX = x
+	}
+
+	if area {
+		baseY := r.layout.Y(style.Baseline)
+		r.dc.LineTo(lastX, baseY)
+		r.dc.LineTo(firstX, baseY)
+		r.dc.ClosePath()
+	}
+}
+
+// drawHistogram draws a series as vertical columns from the style's baseline
+func (r *CMLRenderer) drawHistogram(times []time.Time, values []float64, first int, style SeriesStyle) {
+	// Columns are as wide as candle bodies
+	columnWidth := r.layout.Width() * 0.6
+	if len(r.bars) > 0 {
+		columnWidth = r.layout.barSlot(len(r.bars)) * 0.6
+	}
+
+	for i := first; i < len(values); i++ {
+		x, y := r.timePriceToScreen(times[i], values[i])
+		_, baseY := r.timePriceToScreen(times[i], style.Baseline)
+
+		columnColor := style.Color
+		if values[i] >= style.Baseline && style.AboveColor != nil {
+			columnColor = style.AboveColor
+		} else if values[i] < style.Baseline && style.BelowColor != nil {
+			columnColor = style.BelowColor
+		}
+
+		r.dc.SetColor(columnColor)
+		r.dc.DrawRectangle(x-columnWidth/2, math.Min(y, baseY), columnWidth, math.Abs(baseY-y))
+		r.dc.Fill()
+
+		// Each column is its own hit-test segment
+		r.newRegionPath()
+		r.markRegion(x, y, columnWidth/2)
+		r.markRegion(x, baseY, columnWidth/2)
+	}
+}
+
+// drawPoints draws a series as discrete markers at each value
+func (r *CMLRenderer) drawPoints(times []time.Time, values []float64, first int, style SeriesStyle) {
+	size := style.PointSize
+
+	r.dc.SetColor(style.Color)
+	r.dc.SetLineWidth(style.LineWidth)
+	for i := first; i < len(values); i++ {
+		x, y := r.timePriceToScreen(times[i], values[i])
+		r.newRegionPath()
+		r.markRegion(x, y, size+style.LineWidth/2)
+		switch style.Shape {
+		case "square":
+			r.dc.DrawRectangle(x-size, y-size, size*2, size*2)
+			r.dc.Fill()
+		case "diamond":
+			r.dc.DrawRegularPolygon(4, x, y, size*1.4, math.Pi/4)
+			r.dc.Fill()
+		case "triangle":
+			r.dc.DrawRegularPolygon(3, x, y, size*1.4, 0)
+			r.dc.Fill()
+		case "cross":
+			r.dc.DrawLine(x-size, y-size, x+size, y+size)
+			r.dc.DrawLine(x-size, y+size, x+size, y-size)
+			r.dc.Stroke()
+		default: // circle
+			r.dc.DrawCircle(x, y, size)
+			r.dc.Fill()
+		}
+	}
+}
+
+// drawErrorBars draws each bounded point of a series as a whisker from its
+// lower to its upper bound, capped at both ends, with a marker at the value
+func (r *CMLRenderer) drawErrorBars(points []cml.SeriesPoint, style SeriesStyle) {
+	capWidth := style.PointSize + 1
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	r.dc.SetColor(style.Color)
+	r.dc.SetLineWidth(style.LineWidth / 2)
+	for _, point := range points {
+		if !point.HasBounds {
+			continue
+		}
+		x, lowerY := r.timePriceToScreen(point.DateTime, point.Lower)
+		upperY := r.layout.Y(point.Upper)
+		r.newRegionPath()
+		r.markRegion(x, lowerY, capWidth)
+		r.markRegion(x, upperY, capWidth)
+		r.dc.DrawLine(x, lowerY, x, upperY)
+		r.dc.DrawLine(x-capWidth, lowerY, x+capWidth, lowerY)
+		r.dc.DrawLine(x-capWidth, upperY, x+capWidth, upperY)
+		r.dc.Stroke()
+	}
+
+	times := make([]time.Time, len(points))
+	values := make([]float64, len(points))
+	for i, point := range points {
+		times[i] = point.DateTime
+		values[i] = point.Value
+	}
+	r.drawPoints(times, values, 0, style)
+}
+
+// drawBoundsBand fills the band between a series' lower and upper bounds,
+// with a separate band for each run of consecutive bounded points
+func (r *CMLRenderer) drawBoundsBand(points []cml.SeriesPoint, style SeriesStyle) {
+	opacity := style.FillOpacity
+	if opacity == 0 {
+		opacity = 0.2
+	}
+
+	r.clipToChartArea()
+	defer r.dc.ResetClip()
+
+	start := -1
+	for i := 0; i <= len(points); i++ {
+		if i < len(points) && points[i].HasBounds {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start == -1 {
+			continue
+		}
+
+		// Upper bound left to right, then lower bound right to left
+		run := points[start:i]
+		for _, point := range run {
+			r.dc.LineTo(r.timePriceToScreen(point.DateTime, point.Upper))
+		}
+		for j := len(run) - 1; j >= 0; j-- {
+			r.dc.LineTo(r.timePriceToScreen(run[j].DateTime, run[j].Lower))
+		}
+		r.dc.ClosePath()
+		r.dc.SetColor(translucent(style.Color, opacity))
+		r.dc.Fill()
+		start = -1
+	}
+}
+
+// Helper methods
+
+// clipToChartArea restricts drawing to the plot area inside the margins
+func (r *CMLRenderer) clipToChartArea() {
+	r.dc.DrawRectangle(r.layout.Left, r.layout.Top, r.layout.Width(), r.layout.Height())
+	r.dc.Clip()
+}
+
+// timePriceToScreen converts time and price to screen coordinates
+func (r *CMLRenderer) timePriceToScreen(t time.Time, price float64) (float64, float64) {
+	return r.layout.X(t), r.layout.Y(price)
+}
+
+// drawArrow draws an arrow at the specified end of a line
+func (r *CMLRenderer) drawArrow(x1, y1, x2, y2 float64, color color.Color, direction string) {
+	// Calculate arrow direction
+	dx := x2 - x1
+	dy := y2 - y1
+	length := math.Sqrt(dx*dx + dy*dy)
+
+	if length == 0 {
+		return
+	}
+
+	// Normalize direction
+	dx /= length
+	dy /= length
+
+	// Arrow size
+	arrowSize := 10.0
+	arrowAngle := math.Pi / 6 // 30 degrees
+
+	var arrowX1, arrowY1, arrowX2, arrowY2 float64
+	var arrowX, arrowY float64
+
+	// Determine which end of the line to draw the arrow
+	if direction == "left" {
+		arrowX, arrowY = x1, y1
+		// Reverse direction for left arrow
+		dx = -dx
+		dy = -dy
+	} else { // right arrow
+		arrowX, arrowY = x2, y2
+	}
+
+	// Calculate arrow points
+	arrowX1 = arrowX - arrowSize*math.Cos(math.Atan2(dy, dx)-arrowAngle)
+	arrowY1 = arrowY - arrowSize*math.Sin(math.Atan2(dy, dx)-arrowAngle)
+	arrowX2 = arrowX - arrowSize*math.Cos(math.Atan2(dy, dx)+arrowAngle)
+	arrowY2 = arrowY - arrowSize*math.Sin(math.Atan2(dy, dx)+arrowAngle)
+
+	// Draw arrow
+	r.dc.SetColor(color)
+	r.dc.SetLineWidth(2)
+	r.dc.DrawLine(arrowX, arrowY, arrowX1, arrowY1)
+	r.dc.DrawLine(arrowX, arrowY, arrowX2, arrowY2)
+	r.dc.Stroke()
+}
+
+// withOpacity applies an opacity key like fill-opacity or line-opacity to a
+// color, which ends up with alpha × opacity and its hue unchanged; an opaque
+// color's alpha is 1
+func (r *CMLRenderer) withOpacity(c color.Color, opacity float64) color.Color {
+	return translucent(c, opacity)
+}
+
+// translucent returns a color at the given opacity (0.0-1.0) with its hue
+// unchanged, for fills that should read as a tint of the color; a color's
+// own alpha is multiplied in
+func translucent(c color.Color, opacity float64) color.NRGBA {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nrgba.A = uint8(float64(nrgba.A) * opacity)
+	return nrgba
+}
+
+// nextAutoColor returns the next palette color for an element whose styles
+// don't set a border color, or nil when the element has its own color
+func (r *CMLRenderer) nextAutoColor(styles map[string]interface{}) color.Color {
+	if _, ok := styles["border-color"]; ok {
+		return nil
+	}
+	return r.palette.Next()
+}
+
+// paramColor returns an indicator or series color: its color parameter if
+// set, otherwise the next auto color, otherwise the given default
+func (r *CMLRenderer) paramColor(params map[string]interface{}, defaultColor color.Color) color.Color {
+	if colorStr, ok := params["color"].(string); ok {
+		return r.parseColor(colorStr)
+	}
+	if r.palette != nil {
+		return r.palette.Next()
+	}
+	return defaultColor
+}
+
+// getStyleColor gets a color from styles with default
+func (r *CMLRenderer) getStyleColor(styles map[string]interface{}, key string, defaultColor color.Color) color.Color {
+	// Auto-assigned colors replace the built-in border color default
+	if key == "border-color" && r.autoColor != nil {
+		defaultColor = r.autoColor
+	}
+
+	if styles == nil {
+		return defaultColor
+	}
+
+	if val, ok := styles[key]; ok {
+		if colorStr, ok := val.(string); ok {
+			return r.parseColor(colorStr)
+		}
+	}
+
+	return defaultColor
+}
+
+// getStyleFloat gets a float from styles with default
+func (r *CMLRenderer) getStyleFloat(styles map[string]interface{}, key string, defaultValue float64) float64 {
+	if styles == nil {
+		return defaultValue
+	}
+
+	if val, ok := styles[key]; ok {
+		if floatVal, ok := val.(float64); ok {
+			return floatVal
+		}
+	}
+
+	return defaultValue
+}
+
+// getStyleString gets a string from styles with default
+func (r *CMLRenderer) getStyleString(styles map[string]interface{}, key string, defaultValue string) string {
+	if styles == nil {
+		return defaultValue
+	}
+
+	if val, ok := styles[key]; ok {
+		if strVal, ok := val.(string); ok {
+			return strVal
+		}
+	}
+	return defaultValue
+}
+
+// getMetaValue gets a meta value by key
+func (r *CMLRenderer) getMetaValue(meta []cml.MetaEntry, key string) string {
+	for _, entry := range meta {
+		if entry.Key == key {
+			if str, ok := entry.Value.(string); ok {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// parseColor parses a #RGB, #RGBA, #RRGGBB, or #RRGGBBAA hex color, falling
+// back to black. Opaque colors are RGBA; colors with alpha are NRGBA.
+func (r *CMLRenderer) parseColor(colorStr string) color.Color {
+	colorStr = strings.TrimPrefix(colorStr, "#")
+
+	// Expand the short forms to two digits per channel
+	if len(colorStr) == 3 || len(colorStr) == 4 {
+		var long strings.Builder
+		for _, digit := range colorStr {
+			long.WriteRune(digit)
+			long.WriteRune(digit)
+		}
+		colorStr = long.String()
+	}
+	if len(colorStr) != 6 && len(colorStr) != 8 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+
+	value, err := strconv.ParseUint(colorStr, 16, 32)
+	if err != nil {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	if len(colorStr) == 6 {
+		value = value<<8 | 0xff
+	}
+	c := color.NRGBA{uint8(value >> 24), uint8(value >> 16), uint8(value >> 8), uint8(value)}
+	if c.A == 255 {
+		return color.RGBA{c.R, c.G, c.B, 255}
+	}
+	return c
+}
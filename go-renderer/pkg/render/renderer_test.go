@@ -0,0 +1,58 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// weekdayChart returns a chart of daily bars on the weekdays of two weeks
+func weekdayChart() *cml.Chart {
+	chart := &cml.Chart{}
+	for day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC); day.Day() <= 17; day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		chart.Bars = append(chart.Bars, cml.Bar{DateTime: day, Open: 10, High: 11, Low: 9, Close: 10})
+	}
+	return chart
+}
+
+func TestBarsAheadSkipsGaps(t *testing.T) {
+	chart := weekdayChart()
+	r := NewCMLRenderer(800, 400)
+	defer r.Close()
+	r.setupChart(chart)
+
+	first, last := chart.Bars[0].DateTime, chart.Bars[len(chart.Bars)-1].DateTime
+	if ahead := r.barsAhead(first) - r.barsAhead(last); ahead != 9 {
+		t.Errorf("%v bars from the first bar to the last, want 9 with the weekend skipped", ahead)
+	}
+	padding := r.layout.MaxTime.Sub(last).Hours() / 24
+	if ahead := r.barsAhead(last); ahead != padding {
+		t.Errorf("%v bars ahead of the last bar, want the %v bars of padding", ahead, padding)
+	}
+}
+
+func TestOpacityKeepsColor(t *testing.T) {
+	r := NewCMLRenderer(10, 10)
+	defer r.Close()
+
+	// An opaque color and one with nearly opaque alpha digits fade alike
+	opaque := color.NRGBAModel.Convert(r.withOpacity(r.parseColor("#00ff00"), 0.5)).(color.NRGBA)
+	if want := (color.NRGBA{0, 255, 0, 127}); opaque != want {
+		t.Errorf("#00ff00 at 0.5 opacity is %v, want %v", opaque, want)
+	}
+	nearlyOpaque := color.NRGBAModel.Convert(r.withOpacity(r.parseColor("#00ff00fe"), 0.5)).(color.NRGBA)
+	if want := (color.NRGBA{0, 255, 0, 127}); nearlyOpaque != want {
+		t.Errorf("#00ff00fe at 0.5 opacity is %v, want %v", nearlyOpaque, want)
+	}
+
+	for _, c := range []color.Color{color.RGBA{0, 255, 0, 255}, color.NRGBA{0, 255, 0, 255}} {
+		if got := color.NRGBAModel.Convert(barOpacity(c, 128)).(color.NRGBA); got != (color.NRGBA{0, 255, 0, 128}) {
+			t.Errorf("bar opacity 128 on %v is %v, want {0 255 0 128}", c, got)
+		}
+	}
+}
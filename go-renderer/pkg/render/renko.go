@@ -0,0 +1,120 @@
+package render
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// renkoChart returns a copy of the chart with its bars replaced by renko
+// bricks when the bar-type is renko, or the chart itself otherwise. Bricks
+// are laid out one per slot, so the x-axis follows brick order rather than
+// time. Indicators are computed on the bricks; drawings, plotted series,
+// positions, the fan, ticks, and corporate action and roll markers are placed
+// by time, so they are left out.
+func renkoChart(chart *cml.Chart) *cml.Chart {
+	if chart.GetBarType() != "renko" || len(chart.Bars) == 0 {
+		return chart
+	}
+
+	renko := *chart
+	renko.Bars = renkoBricks(chart.Bars, renkoBrickSize(chart.Bars, chart.GetRenkoBrickSize()))
+	renko.Drawings = nil
+	renko.Positions = nil
+	renko.Fan = cml.Fan{}
+	renko.Ticks = nil
+	renko.CorporateActions = nil
+	renko.Contracts = nil
+
+	// Data-only series stay for the indicators that read them
+	dataSeries := chart.GetDataSeries()
+	renko.Series = nil
+	for _, series := range chart.Series {
+		if dataSeries[series.Name] {
+			renko.Series = append(renko.Series, series)
+		}
+	}
+	return &renko
+}
+
+// renkoBrickTime places brick i in its slot on the time axis. Slots are laid
+// out hourly from a fixed origin, like event study offsets, so the chart's
+// layout, grid, and indicators apply unchanged.
+func renkoBrickTime(i int) time.Time {
+	return time.Unix(0, 0).UTC().Add(time.Duration(i) * time.Hour)
+}
+
+// renkoTicks returns ticks at round brick numbers, about ten across the
+// bricks, labeled with the brick number counting from 1
+func renkoTicks(numBricks int) ([]time.Time, []string) {
+	step := 1
+	for _, s := range []int{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000} {
+		step = s
+		if numBricks/s <= 10 {
+			break
+		}
+	}
+
+	var ticks []time.Time
+	var labels []string
+	for brick := step; brick <= numBricks; brick += step {
+		ticks = append(ticks, renkoBrickTime(brick-1))
+		labels = append(labels, strconv.Itoa(brick))
+	}
+	return ticks, labels
+}
+
+// renkoBrickSize returns the fixed brick size, or the latest average true
+// range of the bars when the brick size is ATR-based
+func renkoBrickSize(bars []cml.Bar, config cml.RenkoBrickConfig) float64 {
+	if config.Size > 0 {
+		return config.Size
+	}
+	atr := computeATR(bars, min(config.ATRPeriod, len(bars)))
+	return atr[len(atr)-1]
+}
+
+// renkoBricks builds bricks of the given size from the bars' closes. A brick
+// is added each time the close moves a full brick beyond the last brick, so
+// continuing a trend takes one brick's move and reversing it two. Each brick
+// is dated at its slot. A brick carries the volume traded since the previous
+// brick.
+func renkoBricks(bars []cml.Bar, size float64) []cml.Bar {
+	if size <= 0 {
+		return nil
+	}
+
+	var bricks []cml.Bar
+	top, bottom := bars[0].Close, bars[0].Close
+	volume := 0.0
+	for _, bar := range bars {
+		volume += bar.Volume
+
+		// A bar's bricks all go one way: after rising a brick, falling one
+		// takes a close two bricks lower
+		var opens []float64
+		step := size
+		for bar.Close >= top+size {
+			opens = append(opens, top)
+			bottom, top = top, top+size
+		}
+		if len(opens) == 0 {
+			step = -size
+			for bar.Close <= bottom-size {
+				opens = append(opens, bottom)
+				top, bottom = bottom, bottom-size
+			}
+		}
+
+		for i, open := range opens {
+			brick := cml.Bar{DateTime: renkoBrickTime(len(bricks)), Open: open, Close: open + step}
+			brick.High, brick.Low = max(brick.Open, brick.Close), min(brick.Open, brick.Close)
+			if i == 0 {
+				brick.Volume, volume = volume, 0
+			}
+			bricks = append(bricks, brick)
+		}
+	}
+	return bricks
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+func TestRenkoBricksFillSlots(t *testing.T) {
+	// A flat month between the two moves leaves no gap between their bricks
+	closes := map[time.Time]float64{
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC): 100,
+		time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC): 103,
+		time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC): 103.5,
+		time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC): 99,
+		time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC): 100,
+	}
+	var bars []cml.Bar
+	for day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !day.After(time.Date(2025, 2, 5, 0, 0, 0, 0, time.UTC)); day = day.AddDate(0, 0, 1) {
+		if close, ok := closes[day]; ok {
+			bars = append(bars, cml.Bar{DateTime: day, Open: close, High: close, Low: close, Close: close})
+		}
+	}
+
+	bricks := renkoBricks(bars, 1)
+	var opens []float64
+	for i, brick := range bricks {
+		if !brick.DateTime.Equal(renkoBrickTime(i)) {
+			t.Errorf("brick %d at %v, want slot %v", i+1, brick.DateTime, renkoBrickTime(i))
+		}
+		opens = append(opens, brick.Open)
+	}
+	if want := []float64{100, 101, 102, 102, 101, 100}; !reflect.DeepEqual(opens, want) {
+		t.Errorf("brick opens %v, want %v", opens, want)
+	}
+}
+
+func TestRenkoTicks(t *testing.T) {
+	ticks, labels := renkoTicks(45)
+	if want := []string{"5", "10", "15", "20", "25", "30", "35", "40", "45"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("labels %v, want %v", labels, want)
+	}
+	if !ticks[0].Equal(renkoBrickTime(4)) {
+		t.Errorf("first tick at %v, want the fifth brick's slot", ticks[0])
+	}
+}
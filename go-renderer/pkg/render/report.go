@@ -0,0 +1,173 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Report page geometry: US Letter landscape in points, rasterized at
+// reportScale pixels per point
+const (
+	reportPageWidth  = 792.0
+	reportPageHeight = 612.0
+	reportScale      = 2
+	reportMargin     = 48.0 // Pixels
+	reportHeader     = 70.0 // Pixels above the grid for the report title
+	reportGap        = 24.0 // Pixels between charts
+	reportRowHeight  = 34.0 // Pixels per summary table row
+)
+
+// reportRuleColor outlines each chart and separates summary table rows
+var reportRuleColor = color.RGBA{200, 200, 200, 255}
+
+// ReportChart is one chart of a report, with the name its summary table row uses
+type ReportChart struct {
+	Name  string
+	Chart *cml.Chart
+}
+
+// ReportOptions lay out a report
+type ReportOptions struct {
+	Title   string // Shown at the top of every page
+	Columns int    // Charts across each page
+	Rows    int    // Charts down each page
+	Table   bool   // Add summary table pages from Analyze after the charts
+}
+
+// WriteReport writes a paginated PDF report: the charts as a grid of small
+// multiples, Columns by Rows per page, followed by an optional summary table
+// of each chart's statistics
+func WriteReport(w io.Writer, charts []ReportChart, options ReportOptions) error {
+	if options.Columns < 1 || options.Rows < 1 {
+		return fmt.Errorf("a report needs at least one column and one row")
+	}
+
+	pageWidth, pageHeight := int(reportPageWidth*reportScale), int(reportPageHeight*reportScale)
+	page := NewCMLRenderer(pageWidth, pageHeight)
+	defer page.Close()
+
+	perPage := options.Columns * options.Rows
+	chartPages := (len(charts) + perPage - 1) / perPage
+	tableRows := int((float64(pageHeight)-reportHeader-2*reportMargin)/reportRowHeight) - 1
+	tablePages := 0
+	if options.Table && len(charts) > 0 {
+		tablePages = (len(charts) + tableRows - 1) / tableRows
+	}
+	totalPages := chartPages + tablePages
+
+	pdf := newPDFWriter(w, reportPageWidth, reportPageHeight)
+	for i := 0; i < chartPages; i++ {
+		start := i * perPage
+		end := min(start+perPage, len(charts))
+		page.drawReportPage(options.Title, i+1, totalPages)
+		page.drawReportGrid(charts[start:end], options)
+		if err := pdf.AddPage(page.dc.Image()); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < tablePages; i++ {
+		start := i * tableRows
+		end := min(start+tableRows, len(charts))
+		page.drawReportPage(options.Title, chartPages+i+1, totalPages)
+		page.drawReportTable(charts[start:end])
+		if err := pdf.AddPage(page.dc.Image()); err != nil {
+			return err
+		}
+	}
+	return pdf.Close()
+}
+
+// drawReportPage clears the page and draws the report title and page number
+func (r *CMLRenderer) drawReportPage(title string, number, total int) {
+	r.dc.SetColor(color.White)
+	r.dc.Clear()
+	r.dc.SetColor(color.Black)
+	r.dc.SetFontFace(r.fontFace(28))
+	r.dc.DrawStringAnchored(title, reportMargin, reportMargin, 0, 0.5)
+	r.dc.SetFontFace(r.fontFace(18))
+	r.dc.DrawStringAnchored(fmt.Sprintf("Page %d of %d", number, total), float64(r.Width)-reportMargin, reportMargin, 1, 0.5)
+}
+
+// drawReportGrid renders each chart into its cell of the page grid
+func (r *CMLRenderer) drawReportGrid(charts []ReportChart, options ReportOptions) {
+	top := reportHeader + reportMargin/2
+	cellWidth := (float64(r.Width) - 2*reportMargin - float64(options.Columns-1)*reportGap) / float64(options.Columns)
+	cellHeight := (float64(r.Height) - top - reportMargin - float64(options.Rows-1)*reportGap) / float64(options.Rows)
+
+	for i, chart := range charts {
+		x := reportMargin + float64(i%options.Columns)*(cellWidth+reportGap)
+		y := top + float64(i/options.Columns)*(cellHeight+reportGap)
+
+		cell := NewCMLRenderer(int(cellWidth), int(cellHeight))
+		cell.draw(chart.Chart)
+		r.dc.DrawImage(cell.dc.Image(), int(x), int(y))
+		cell.Close()
+
+		r.dc.SetColor(reportRuleColor)
+		r.dc.SetLineWidth(1)
+		r.dc.DrawRectangle(math.Floor(x)+0.5, math.Floor(y)+0.5, math.Floor(cellWidth)-1, math.Floor(cellHeight)-1)
+		r.dc.Stroke()
+	}
+}
+
+// drawReportTable draws a summary table row of statistics for each chart
+func (r *CMLRenderer) drawReportTable(charts []ReportChart) {
+	headers := []string{"Chart", "Bars", "Start", "End", "Last close", "Return", "Volatility", "Max drawdown"}
+	columns := []float64{0, 0.34, 0.41, 0.52, 0.63, 0.73, 0.82, 0.91} // Fractions of the table width
+
+	width := float64(r.Width) - 2*reportMargin
+	y := reportHeader + reportMargin
+	r.dc.SetFontFace(r.fontFace(18))
+	drawRow := func(cells []string) {
+		r.dc.SetColor(color.Black)
+		for i, text := range cells {
+			r.dc.DrawStringAnchored(text, reportMargin+columns[i]*width, y+reportRowHeight/2, 0, 0.5)
+		}
+		y += reportRowHeight
+		r.dc.SetColor(reportRuleColor)
+		r.dc.SetLineWidth(1)
+		r.dc.DrawLine(reportMargin, y, reportMargin+width, y)
+		r.dc.Stroke()
+	}
+
+	drawRow(headers)
+	for _, chart := range charts {
+		name := r.fitText(chart.Name, (columns[1]-columns[0])*width-10)
+		stats := Analyze(chart.Chart)
+		if stats.Bars == 0 {
+			drawRow([]string{name, "0", "", "", "", "", "", ""})
+			continue
+		}
+		drawRow([]string{
+			name,
+			fmt.Sprintf("%d", stats.Bars),
+			stats.Start.Format("2006/01/02"),
+			stats.End.Format("2006/01/02"),
+			fmt.Sprintf("%.2f", stats.LastClose),
+			fmt.Sprintf("%+.2f%%", stats.Return*100),
+			fmt.Sprintf("%.2f%%", stats.Volatility*100),
+			fmt.Sprintf("%.2f%%", stats.MaxDrawdown*100),
+		})
+	}
+}
+
+// fitText shortens text with an ellipsis until it fits within width pixels
+// in the current font
+func (r *CMLRenderer) fitText(text string, width float64) string {
+	runes := []rune(text)
+	for len(runes) > 0 {
+		if w, _ := r.dc.MeasureString(string(runes)); w <= width {
+			return string(runes)
+		}
+		runes = runes[:len(runes)-1]
+		text = string(runes) + "..."
+		if w, _ := r.dc.MeasureString(text); w <= width {
+			return text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// rollMarkerColor is the color of contract roll badges
+var rollMarkerColor = color.RGBA{230, 81, 0, 255}
+
+// renderRollMarkers marks each contract roll in the visible range with an "R"
+// badge labeled with the contract rolled into
+func (r *CMLRenderer) renderRollMarkers(contracts []cml.Contract) {
+	if !r.chart.GetRollMarkers() || len(r.bars) == 0 {
+		return
+	}
+
+	for i, contract := range contracts[:max(len(contracts)-1, 0)] {
+		r.drawEventBadge(contract.Roll, "R", contracts[i+1].Name, rollMarkerColor, ImageRegion{
+			Kind:  "roll",
+			ID:    "roll-" + elementTime(contract.Roll),
+			Class: "roll",
+			Label: fmt.Sprintf("roll %s to %s", contract.Name, contracts[i+1].Name),
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"image"
+
+	"github.com/fogleman/gg"
+)
+
+// NewScaledCMLRenderer creates a renderer that lays a width x height chart
+// out as usual but draws it scale times as many pixels across and down, for
+// sharp output on high-density (2x, "retina") displays. Lines and text are
+// drawn at the higher resolution rather than enlarged afterwards. Layout,
+// image map regions, and custom layers all work in the unscaled pixels.
+// The scale must be positive.
+func NewScaledCMLRenderer(width, height int, scale float64) *CMLRenderer {
+	r := newRenderer(width, height)
+	r.scale = scale
+	r.useCanvas(acquireCanvas(r.deviceSize(width, height)))
+	return r
+}
+
+// deviceSize returns the size in image pixels of a canvas of the given size
+// in chart pixels
+func (r *CMLRenderer) deviceSize(width, height int) (int, int) {
+	size := Size{Width: width, Height: height}.Scaled(r.scale)
+	return size.Width, size.Height
+}
+
+// scaledContext is a drawing context whose coordinates are in chart pixels,
+// scale image pixels apart. gg scales paths by its transform but not line
+// widths, dashes, or text, so those are scaled here; font faces are already
+// built at the image size by fontFace, and their measurements are scaled
+// back down.
+type scaledContext struct {
+	*gg.Context
+	scale float64
+}
+
+// newScaledContext returns a context drawing onto canvas at the given scale
+func newScaledContext(canvas *image.RGBA, scale float64) *scaledContext {
+	dc := gg.NewContextForRGBA(canvas)
+	if scale != 1 {
+		dc.Scale(scale, scale)
+	}
+	return &scaledContext{Context: dc, scale: scale}
+}
+
+// SetLineWidth sets the line width in chart pixels
+func (c *scaledContext) SetLineWidth(width float64) {
+	c.Context.SetLineWidth(width * c.scale)
+}
+
+// SetDash sets the dash pattern in chart pixels
+func (c *scaledContext) SetDash(dashes ...float64) {
+	if c.scale != 1 {
+		scaled := make([]float64, len(dashes))
+		for i, dash := range dashes {
+			scaled[i] = dash * c.scale
+		}
+		dashes = scaled
+	}
+	c.Context.SetDash(dashes...)
+}
+
+// MeasureString returns the size of text in chart pixels
+func (c *scaledContext) MeasureString(s string) (float64, float64) {
+	w, h := c.Context.MeasureString(s)
+	return w / c.scale, h / c.scale
+}
+
+// DrawString draws text with its baseline starting at x, y
+func (c *scaledContext) DrawString(s string, x, y float64) {
+	c.DrawStringAnchored(s, x, y, 0, 0)
+}
+
+// DrawStringAnchored draws text anchored at x, y like gg's. At a scale other
+// than 1 the glyphs are drawn with the scale taken out of the transform, so
+// they come out at the face's own size instead of resampled from it.
+func (c *scaledContext) DrawStringAnchored(s string, x, y, ax, ay float64) {
+	if c.scale == 1 {
+		c.Context.DrawStringAnchored(s, x, y, ax, ay)
+		return
+	}
+	c.Push()
+	c.Scale(1/c.scale, 1/c.scale)
+	c.Context.DrawStringAnchored(s, x*c.scale, y*c.scale, ax, ay)
+	c.Pop()
+}
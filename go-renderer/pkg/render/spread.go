@@ -0,0 +1,75 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Spread band fill and edge colors
+var (
+	spreadFillColor = color.NRGBA{70, 130, 180, 60}
+	spreadEdgeColor = color.NRGBA{70, 130, 180, 160}
+)
+
+// quoteLevels returns every bid and ask price, so the chart's price range
+// can include quotes outside the bars' traded range
+func quoteLevels(bars []cml.Bar) []float64 {
+	var levels []float64
+	for _, bar := range bars {
+		if bar.HasQuote() {
+			levels = append(levels, bar.BidPrice, bar.AskPrice)
+		}
+	}
+	return levels
+}
+
+// renderSpreadBand shades the band between the best bid and ask behind the
+// bars, with a separate band for each run of consecutive quoted bars
+func (r *CMLRenderer) renderSpreadBand(bars []cml.Bar) {
+	start := -1
+	for i := 0; i <= len(bars); i++ {
+		if i < len(bars) && bars[i].HasQuote() {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			r.drawSpreadRun(bars[start:i])
+			start = -1
+		}
+	}
+}
+
+// drawSpreadRun fills and edges the band for consecutive quoted bars; a lone
+// bar's band spans its own width
+func (r *CMLRenderer) drawSpreadRun(bars []cml.Bar) {
+	l := r.layout
+	halfWidth := 0.0
+	if len(bars) == 1 {
+		halfWidth = l.barSlot(len(r.bars)) * 0.3
+	}
+
+	// Ask edge left to right, then bid edge right to left
+	for _, bar := range bars {
+		x := l.X(bar.DateTime)
+		r.dc.LineTo(x-halfWidth, l.Y(bar.AskPrice))
+		if halfWidth > 0 {
+			r.dc.LineTo(x+halfWidth, l.Y(bar.AskPrice))
+		}
+	}
+	for i := len(bars) - 1; i >= 0; i-- {
+		x := l.X(bars[i].DateTime)
+		if halfWidth > 0 {
+			r.dc.LineTo(x+halfWidth, l.Y(bars[i].BidPrice))
+		}
+		r.dc.LineTo(x-halfWidth, l.Y(bars[i].BidPrice))
+	}
+	r.dc.ClosePath()
+	r.dc.SetColor(spreadFillColor)
+	r.dc.FillPreserve()
+	r.dc.SetColor(spreadEdgeColor)
+	r.dc.SetLineWidth(1)
+	r.dc.Stroke()
+}
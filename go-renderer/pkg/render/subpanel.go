@@ -0,0 +1,150 @@
+package render
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Share of the plot height given to each unit of subpanel weight and to all
+// subpanels, unless the chart sets price-pane-height, and the gap above each
+// subpanel
+const (
+	subpanelShare    = 0.25
+	subpanelMaxShare = 0.5
+	subpanelGap      = 12.0
+)
+
+// subpanel is a pane below the price plot, with its height relative to the
+// other panes
+type subpanel struct {
+	name   string
+	weight float64
+}
+
+// subpanels returns the subpanels the chart needs, top to bottom: volume, then
+// indicators with their own scale in order, then the tick strip
+func subpanels(chart *cml.Chart) []subpanel {
+	var panes []subpanel
+	if chart.HasVolume() && chart.GetVolumePane() {
+		panes = append(panes, subpanel{volumePanel, 1})
+	}
+	for _, indicator := range chart.Indicators {
+		switch indicator.Name {
+		case "relative-strength":
+			panes = append(panes, subpanel{relativeStrengthPanel(indicator), cml.PaneHeight(indicator.Parameters)})
+		case "rsi", "macd":
+			panes = append(panes, subpanel{oscillatorPanel(indicator), cml.PaneHeight(indicator.Parameters)})
+		}
+	}
+	if chart.GetTicksDisplay() == "strip" {
+		panes = append(panes, subpanel{ticksPanel, 1})
+	}
+	return panes
+}
+
+// layoutPanels stacks the subpanels at the bottom of the space from top to
+// bottom, sized by their weights, and returns the price plot's new bottom and
+// the panels. With a price pane weight, the price plot and subpanels split
+// the space in proportion to their weights; without one, the subpanels get a
+// quarter of it per unit of weight, up to half.
+func layoutPanels(panes []subpanel, priceWeight, top, bottom float64) (float64, []Panel) {
+	if len(panes) == 0 {
+		return bottom, nil
+	}
+
+	total := 0.0
+	for _, pane := range panes {
+		total += pane.weight
+	}
+	share := math.Min(subpanelShare*total, subpanelMaxShare)
+	if priceWeight > 0 {
+		share = total / (total + priceWeight)
+	}
+
+	space := bottom - top
+	panels := make([]Panel, len(panes))
+	for i := len(panes) - 1; i >= 0; i-- {
+		height := space*share*panes[i].weight/total - subpanelGap
+		panels[i] = Panel{Name: panes[i].name, Top: bottom - height, Bottom: bottom}
+		bottom -= height + subpanelGap
+	}
+	return bottom, panels
+}
+
+// relativeStrengthPanel names a relative-strength indicator's subpanel
+func relativeStrengthPanel(indicator cml.Indicator) string {
+	name, _ := indicator.Parameters["benchmark"].(string)
+	return "relative-strength-" + name
+}
+
+// relativeStrength holds computed ratios for the compute cache
+type relativeStrength struct {
+	ratios []float64
+	first  int
+}
+
+// renderRelativeStrength draws the ratio of the bars' closes to a benchmark
+// series in the subpanel, scaled to the ratio's own range
+func (r *CMLRenderer) renderRelativeStrength(indicator cml.Indicator, style SeriesStyle) {
+	l := r.layout
+	name, _ := indicator.Parameters["benchmark"].(string)
+	benchmark, ok := r.chart.GetSeries(name)
+	panelArea, hasPanel := l.Panel(relativeStrengthPanel(indicator))
+	if !ok || !hasPanel {
+		return
+	}
+
+	strength := memo(r, "relative-strength:"+name, func() relativeStrength {
+		ratios, first := computeRelativeStrength(r.bars, benchmark.Points)
+		return relativeStrength{ratios, first}
+	})
+	ratios, first := strength.ratios, strength.first
+	if first >= len(ratios) {
+		return
+	}
+
+	// Scale the panel to the ratio range with some padding
+	minRatio, maxRatio := ratios[first], ratios[first]
+	for _, ratio := range ratios[first:] {
+		minRatio = math.Min(minRatio, ratio)
+		maxRatio = math.Max(maxRatio, ratio)
+	}
+	padding := (maxRatio - minRatio) * 0.05
+	if padding == 0 {
+		padding = math.Max(math.Abs(maxRatio)*0.01, 0.01)
+	}
+	panel := l.panelLayout(panelArea, minRatio-padding, maxRatio+padding)
+
+	r.drawPanelLabels(panel, "RS vs "+name, "%.4g")
+
+	// Draw the ratio with the subpanel's scale
+	r.clipToPanel(panel)
+	r.layout = panel
+	r.strokeSeries(ratios, first, style)
+	r.layout = l
+	r.dc.ResetClip()
+}
+
+// drawPanelLabels draws a subpanel's value labels at its top, middle, and
+// bottom, and its title in the top-left corner
+func (r *CMLRenderer) drawPanelLabels(panel Layout, title, format string) {
+	r.drawPanelValueLabels(panel, title, func(value float64) string { return fmt.Sprintf(format, value) })
+}
+
+// drawPanelValueLabels is drawPanelLabels with the values formatted by label
+func (r *CMLRenderer) drawPanelValueLabels(panel Layout, title string, label func(float64) string) {
+	r.dc.SetColor(r.colors.text)
+	r.dc.SetFontFace(r.fontFace(r.fontSizes().Axis))
+	for _, value := range panel.PriceTicks {
+		r.drawPriceLabel(label(value), panel.Left, panel.Right, panel.Y(value))
+	}
+	r.dc.DrawStringAnchored(title, panel.Left+4, panel.Top+4, 0, 1)
+}
+
+// clipToPanel restricts drawing to a subpanel's area
+func (r *CMLRenderer) clipToPanel(panel Layout) {
+	r.dc.DrawRectangle(panel.Left, panel.Top, panel.Width(), panel.Height())
+	r.dc.Clip()
+}
@@ -0,0 +1,59 @@
+package render
+
+import (
+	"image/color"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// themeColors are a theme's colors parsed for drawing. The frame, grid, and
+// wick colors come from the chart's getters, which fall back to the theme.
+type themeColors struct {
+	background color.Color
+	up, down   color.Color
+	wick       color.Color // Candle outlines; wicks use the wick-color setting
+	text       color.Color
+}
+
+// parseTheme parses a theme's colors
+func (r *CMLRenderer) parseTheme(theme cml.Theme) themeColors {
+	return themeColors{
+		background: r.parseColor(theme.Background),
+		up:         r.parseColor(theme.Up),
+		down:       r.parseColor(theme.Down),
+		wick:       r.parseColor(theme.Wick),
+		text:       r.parseColor(theme.Text),
+	}
+}
+
+// themedChart returns the chart with the renderer's theme in place of the
+// chart's own: the light theme in print mode, or Theme when set. Other
+// charts are returned as they are.
+func (r *CMLRenderer) themedChart(chart *cml.Chart) *cml.Chart {
+	var theme cml.Theme
+	switch {
+	case r.PrintMode || chart.GetPrintMode():
+		theme = cml.Themes["light"]
+	case r.Theme != nil:
+		theme = *r.Theme
+	default:
+		return chart
+	}
+
+	themed := *chart
+	themed.Settings = []cml.SettingsEntry{{Key: "theme", Value: theme}}
+	for _, entry := range chart.Settings {
+		if entry.Key != "theme" {
+			themed.Settings = append(themed.Settings, entry)
+		}
+	}
+	return &themed
+}
+
+// withAlpha returns a color with its alpha scaled by alpha/255, for bar
+// opacity and the translucent up and down colors of volume columns and ticks
+func withAlpha(c color.Color, alpha uint8) color.NRGBA {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nrgba.A = uint8(uint16(nrgba.A) * uint16(alpha) / 255)
+	return nrgba
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"io"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// thumbnailMargin is the padding around a thumbnail's close line, in pixels
+const thumbnailMargin = 4.0
+
+// RenderThumbnail renders a simplified small version of the chart: only the
+// closing prices as a thick line, with no grid, labels, drawings, or indicators.
+// The line is in the theme's up color when the chart closes at or above where
+// it opened, its down color otherwise, on the theme's background.
+func (r *CMLRenderer) RenderThumbnail(chart *cml.Chart, outputFile string) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return r.RenderThumbnailTo(chart, w)
+	})
+}
+
+// RenderThumbnailTo renders the thumbnail and encodes it to w as PNG
+func (r *CMLRenderer) RenderThumbnailTo(chart *cml.Chart, w io.Writer) error {
+	colors := r.parseTheme(r.themedChart(chart).GetTheme())
+	r.dc.SetColor(colors.background)
+	r.dc.Clear()
+
+	if len(chart.Bars) > 0 {
+		r.layout = computeLayout(chart.Bars, thumbnailMargin, thumbnailMargin, float64(r.Width)-thumbnailMargin, float64(r.Height)-thumbnailMargin)
+
+		first, last := chart.Bars[0], chart.Bars[len(chart.Bars)-1]
+		if last.Close >= first.Open {
+			r.dc.SetColor(colors.up)
+		} else {
+			r.dc.SetColor(colors.down)
+		}
+
+		for _, bar := range chart.Bars {
+			r.dc.LineTo(r.timePriceToScreen(bar.DateTime, bar.Close))
+		}
+		r.dc.SetLineWidth(2)
+		r.dc.Stroke()
+	}
+
+	options := chart.GetPNGOptions()
+	if r.PNGOptions != nil {
+		options = *r.PNGOptions
+	}
+	return encodePNG(w, r.dc.Image(), options)
+}
@@ -0,0 +1,63 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// ticksPanel names the time-and-sales strip's subpanel
+const ticksPanel = "ticks"
+
+// Tick strip colors by the tick rule: trades above the previous price are
+// buys in the theme's up color, below are sells in its down color, and
+// unchanged trades keep the last direction; ticks before any change are gray
+const tickAlpha = 160
+
+var tickFlatColor = color.NRGBA{120, 120, 120, tickAlpha}
+
+// renderTickStrip draws every tick in the chart's time range as a dot in the
+// ticks subpanel, sized by trade size and colored by the tick rule
+func (r *CMLRenderer) renderTickStrip(ticks []cml.Tick) {
+	l := r.layout
+	area, ok := l.Panel(ticksPanel)
+	if !ok || len(ticks) == 0 {
+		return
+	}
+
+	minPrice, maxPrice := ticks[0].Price, ticks[0].Price
+	maxSize := 0.0
+	for _, tick := range ticks {
+		minPrice = math.Min(minPrice, tick.Price)
+		maxPrice = math.Max(maxPrice, tick.Price)
+		maxSize = math.Max(maxSize, tick.Size)
+	}
+	padding := (maxPrice - minPrice) * 0.1
+	if padding == 0 {
+		padding = math.Max(math.Abs(maxPrice)*0.001, 0.01)
+	}
+	panel := l.panelLayout(area, minPrice-padding, maxPrice+padding)
+	r.drawPanelLabels(panel, "Time & sales", fmt.Sprintf("%%.%df", r.chart.GetYAxisConfig().Precision))
+
+	r.clipToPanel(panel)
+	defer r.dc.ResetClip()
+	tickColor := tickFlatColor
+	for i, tick := range ticks {
+		if i > 0 {
+			if tick.Price > ticks[i-1].Price {
+				tickColor = withAlpha(r.colors.up, tickAlpha)
+			} else if tick.Price < ticks[i-1].Price {
+				tickColor = withAlpha(r.colors.down, tickAlpha)
+			}
+		}
+		radius := 1.5
+		if maxSize > 0 {
+			radius += 3.5 * math.Sqrt(tick.Size/maxSize)
+		}
+		r.dc.SetColor(tickColor)
+		r.dc.DrawCircle(panel.X(tick.DateTime), panel.Y(tick.Price), radius)
+		r.dc.Fill()
+	}
+}
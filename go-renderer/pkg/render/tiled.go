@@ -0,0 +1,136 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// NewTiledCMLRenderer creates a renderer for very large canvases, such as
+// wall displays. Instead of allocating the whole image it renders horizontal
+// strips of tileHeight rows one at a time and streams each into the PNG, so
+// peak memory is bounded by a single strip.
+func NewTiledCMLRenderer(width, height, tileHeight int) *CMLRenderer {
+	r := newRenderer(width, height)
+	r.tileHeight = tileHeight
+	return r
+}
+
+// renderTiled draws the chart once per strip, each time translated so the
+// strip's rows land on a small canvas, and appends the rows to the PNG on w
+func (r *CMLRenderer) renderTiled(chart *cml.Chart, w io.Writer, options cml.PNGOptions) error {
+	if options.Colors > 0 || options.Interlace {
+		return fmt.Errorf("palette quantization and interlacing are not supported for tiled rendering")
+	}
+	level, err := pngCompressionLevel(options.Compression)
+	if err != nil {
+		return err
+	}
+
+	encoder, err := newPNGStreamEncoder(w, r.Width, r.Height, level)
+	if err != nil {
+		return err
+	}
+
+	for top := 0; top < r.Height; top += r.tileHeight {
+		rows := min(r.tileHeight, r.Height-top)
+
+		r.useCanvas(acquireCanvas(r.Width, rows))
+		r.dc.Translate(0, -float64(top))
+		r.draw(chart)
+
+		err := encoder.WriteRows(r.canvas)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// pngStreamEncoder writes an 8-bit RGBA PNG a few rows at a time, emitting
+// one IDAT chunk per batch so no more than a batch is held in memory
+type pngStreamEncoder struct {
+	w    io.Writer
+	data bytes.Buffer
+	zw   *zlib.Writer
+	row  []byte
+}
+
+// newPNGStreamEncoder writes the PNG header for a width x height image
+func newPNGStreamEncoder(w io.Writer, width, height int, level png.CompressionLevel) (*pngStreamEncoder, error) {
+	if _, err := w.Write(pngSignature); err != nil {
+		return nil, err
+	}
+
+	// IHDR: dimensions, bit depth 8, truecolor with alpha, no interlace
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:4], uint32(width))
+	binary.BigEndian.PutUint32(header[4:8], uint32(height))
+	header[8] = 8
+	header[9] = 6
+	if err := writePNGChunk(w, "IHDR", header); err != nil {
+		return nil, err
+	}
+	if err := writeSRGBChunks(w); err != nil {
+		return nil, err
+	}
+
+	e := &pngStreamEncoder{w: w, row: make([]byte, 1+width*4)}
+	zw, err := zlib.NewWriterLevel(&e.data, zlibLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	e.zw = zw
+	return e, nil
+}
+
+// WriteRows appends every row of the image to the PNG
+func (e *pngStreamEncoder) WriteRows(img *image.RGBA) error {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.RGBAAt(x, y)).(color.NRGBA)
+			i := 1 + (x-bounds.Min.X)*4
+			e.row[i], e.row[i+1], e.row[i+2], e.row[i+3] = c.R, c.G, c.B, c.A
+		}
+		subFilter(e.row, 4)
+		if _, err := e.zw.Write(e.row); err != nil {
+			return err
+		}
+	}
+
+	// Emit what the compressor has produced so far
+	if err := e.zw.Flush(); err != nil {
+		return err
+	}
+	return e.flushData()
+}
+
+// Close finishes the compressed stream and writes the trailing chunks
+func (e *pngStreamEncoder) Close() error {
+	if err := e.zw.Close(); err != nil {
+		return err
+	}
+	if err := e.flushData(); err != nil {
+		return err
+	}
+	return writePNGChunk(e.w, "IEND", nil)
+}
+
+// flushData writes any buffered compressed data as an IDAT chunk
+func (e *pngStreamEncoder) flushData() error {
+	if e.data.Len() == 0 {
+		return nil
+	}
+	err := writePNGChunk(e.w, "IDAT", e.data.Bytes())
+	e.data.Reset()
+	return err
+}
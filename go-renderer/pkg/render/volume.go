@@ -0,0 +1,82 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// volumePanel names the volume histogram's subpanel
+const volumePanel = "volume"
+
+// volumeAlpha is the alpha of volume columns in the theme's up and down colors
+const volumeAlpha = 140
+
+// Print mode grays for the volume columns of up and down bars
+var (
+	printVolumeUpColor   = color.RGBA{190, 190, 190, 255}
+	printVolumeDownColor = color.RGBA{60, 60, 60, 255}
+)
+
+// renderVolume draws each bar's volume as a column in the volume subpanel,
+// scaled from zero to the largest volume and colored by whether the bar
+// closed up or down
+func (r *CMLRenderer) renderVolume(bars []cml.Bar) {
+	l := r.layout
+	area, ok := l.Panel(volumePanel)
+	if !ok {
+		return
+	}
+
+	maxVolume := 0.0
+	for _, bar := range bars {
+		maxVolume = math.Max(maxVolume, bar.Volume)
+	}
+	if maxVolume == 0 {
+		return
+	}
+	panel := l.panelLayout(area, 0, maxVolume*1.05)
+	r.drawPanelValueLabels(panel, "Volume", formatVolume)
+
+	upColor, downColor := color.Color(withAlpha(r.colors.up, volumeAlpha)), color.Color(withAlpha(r.colors.down, volumeAlpha))
+	if r.printMode() {
+		upColor, downColor = printVolumeUpColor, printVolumeDownColor
+	}
+
+	// One fill per color, with columns as wide as candle bodies
+	r.clipToPanel(panel)
+	defer r.dc.ResetClip()
+	width := l.barSlot(len(bars)) * 0.6
+	baseY := panel.Y(0)
+	for _, up := range []bool{true, false} {
+		for _, bar := range bars {
+			if bar.Volume <= 0 || (bar.Close >= bar.Open) != up {
+				continue
+			}
+			y := panel.Y(bar.Volume)
+			r.dc.DrawRectangle(panel.X(bar.DateTime)-width/2, y, width, baseY-y)
+		}
+		if up {
+			r.dc.SetColor(upColor)
+		} else {
+			r.dc.SetColor(downColor)
+		}
+		r.dc.Fill()
+	}
+}
+
+// formatVolume abbreviates a volume for the axis to three significant
+// digits, like 950, 12.5K, or 3.2M
+func formatVolume(volume float64) string {
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		if math.Abs(volume) >= unit.size {
+			return fmt.Sprintf("%.3g%s", volume/unit.size, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%.3g", volume)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+	"github.com/md/chart-markup-language/go-renderer/pkg/render"
+)
+
+// API base URLs of the chat services publish posts to
+var (
+	slackAPI   = "https://slack.com/api/"
+	discordAPI = "https://discord.com/api/v10/"
+)
+
+// publishTimeout bounds each request publish makes
+const publishTimeout = 30 * time.Second
+
+// publishConfig holds the bot tokens publish posts with
+type publishConfig struct {
+	SlackToken   string `json:"slack_token"`
+	DiscordToken string `json:"discord_token"`
+}
+
+// loadPublishConfig reads the bot tokens from a JSON config file, or from
+// cml-renderer/config.json in the user config directory when path is empty,
+// where a missing file is fine. SLACK_BOT_TOKEN and DISCORD_BOT_TOKEN
+// override the file's tokens.
+func loadPublishConfig(path string) (publishConfig, error) {
+	var config publishConfig
+	explicit := path != ""
+	if !explicit {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "cml-renderer", "config.json")
+		}
+	}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(content, &config); err != nil {
+				return config, fmt.Errorf("invalid config %s: %v", path, err)
+			}
+		case explicit || !os.IsNotExist(err):
+			return config, err
+		}
+	}
+
+	if token := os.Getenv("SLACK_BOT_TOKEN"); token != "" {
+		config.SlackToken = token
+	}
+	if token := os.Getenv("DISCORD_BOT_TOKEN"); token != "" {
+		config.DiscordToken = token
+	}
+	return config, nil
+}
+
+// runPublish renders a chart and posts the image to a Slack or Discord
+// channel, with the chart title as the message
+func runPublish(args []string) {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	slackChannel := flags.String("slack-channel", "", "Slack channel to post to, as #name or a channel ID")
+	discordChannel := flags.String("discord-channel", "", "Discord channel ID to post to")
+	configFile := flags.String("config", "", "JSON config file with slack_token and discord_token (default: cml-renderer/config.json in the user config directory)")
+	message := flags.String("message", "", "message posted with the image (default: the chart title)")
+	lenient := flags.Bool("lenient", false, "skip malformed bar and drawing lines instead of failing")
+	width := flags.Int("width", 0, "canvas width in pixels (default: from the chart's size setting, or 800)")
+	height := flags.Int("height", 0, "canvas height in pixels (default: from the chart's size setting, or 600)")
+	scale := flags.Float64("scale", 1, "draw this many image pixels per canvas pixel, e.g. 2 for sharp output on high-density displays")
+	flags.Usage = func() {
+		fmt.Println("Usage: cml-renderer publish (--slack-channel CHANNEL | --discord-channel ID) [flags] <input.cml>")
+		fmt.Println("")
+		fmt.Println("Flags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitUsage)
+	}
+	if flags.NArg() != 1 || (*slackChannel == "") == (*discordChannel == "") {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	if *width < 0 || *height < 0 || *scale <= 0 {
+		fmt.Println("Error: --width, --height, and --scale must be positive")
+		os.Exit(exitUsage)
+	}
+
+	config, err := loadPublishConfig(*configFile)
+	if err != nil {
+		fmt.Printf("Error reading config: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if *slackChannel != "" && config.SlackToken == "" {
+		fmt.Println("Error: --slack-channel needs slack_token in the config file or SLACK_BOT_TOKEN")
+		os.Exit(exitUsage)
+	}
+	if *discordChannel != "" && config.DiscordToken == "" {
+		fmt.Println("Error: --discord-channel needs discord_token in the config file or DISCORD_BOT_TOKEN")
+		os.Exit(exitUsage)
+	}
+
+	inputFile := flags.Arg(0)
+	file, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Printf("Error reading file %s: %v\n", inputFile, err)
+		os.Exit(exitParse)
+	}
+	defer file.Close()
+
+	parser := cml.NewCMLParser()
+	parser.BaseDir = filepath.Dir(inputFile)
+	parser.Lenient = *lenient
+	chart, err := parser.ParseReader(file)
+	if err != nil {
+		fmt.Println(parseErrorMessage(err))
+		os.Exit(exitParse)
+	}
+	for _, warning := range chart.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+
+	size := canvasSize(chart, *width, *height)
+	var image bytes.Buffer
+	renderer := render.NewScaledCMLRenderer(size.Width, size.Height, *scale)
+	err = renderer.RenderTo(chart, &image, "png")
+	renderer.Close()
+	if err != nil {
+		fmt.Printf("Error rendering chart: %v\n", err)
+		os.Exit(exitRender)
+	}
+
+	title := chartTitle(chart, chartName(inputFile))
+	if *message == "" {
+		*message = title
+	}
+	post := publishedPost{
+		filename: chartName(inputFile) + ".png",
+		title:    title,
+		message:  *message,
+		image:    image.Bytes(),
+	}
+	client := &http.Client{Timeout: publishTimeout}
+	if *slackChannel != "" {
+		err = post.toSlack(client, config.SlackToken, *slackChannel)
+	} else {
+		err = post.toDiscord(client, config.DiscordToken, *discordChannel)
+	}
+	if err != nil {
+		fmt.Printf("Error publishing chart: %v\n", err)
+		os.Exit(exitRender)
+	}
+
+	if *slackChannel != "" {
+		fmt.Printf("Chart published to Slack channel %s\n", *slackChannel)
+	} else {
+		fmt.Printf("Chart published to Discord channel %s\n", *discordChannel)
+	}
+}
+
+// chartTitle returns the chart's title meta, or the fallback without one
+func chartTitle(chart *cml.Chart, fallback string) string {
+	for _, entry := range chart.Meta {
+		if title, ok := entry.Value.(string); ok && entry.Key == "title" && title != "" {
+			return title
+		}
+	}
+	return fallback
+}
+
+// publishedPost is a rendered chart and the message posted with it
+type publishedPost struct {
+	filename string
+	title    string
+	message  string
+	image    []byte
+}
+
+// toSlack uploads the image to a Slack channel, given as #name or an ID,
+// through Slack's external upload flow: get an upload URL, send the file
+// there, then share it to the channel with the message. The bot needs the
+// files:write scope, and channels:read (groups:read for private channels) to
+// look channels up by name.
+func (p publishedPost) toSlack(client *http.Client, token, channel string) error {
+	channelID, err := slackChannelID(client, token, channel)
+	if err != nil {
+		return err
+	}
+
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	form := url.Values{"filename": {p.filename}, "length": {strconv.Itoa(len(p.image))}}
+	if err := slackCall(client, token, "files.getUploadURLExternal", form, &upload); err != nil {
+		return err
+	}
+
+	resp, err := client.Post(upload.UploadURL, "image/png", bytes.NewReader(p.image))
+	if err != nil {
+		return fmt.Errorf("uploading to Slack: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("uploading to Slack: %s", resp.Status)
+	}
+
+	files, _ := json.Marshal([]map[string]string{{"id": upload.FileID, "title": p.title}})
+	form = url.Values{"files": {string(files)}, "channel_id": {channelID}, "initial_comment": {p.message}}
+	return slackCall(client, token, "files.completeUploadExternal", form, nil)
+}
+
+// slackChannelID returns the ID of a Slack channel given as #name, paging
+// through the channels the bot can see, or the channel itself otherwise
+func slackChannelID(client *http.Client, token, channel string) (string, error) {
+	name, ok := strings.CutPrefix(channel, "#")
+	if !ok {
+		return channel, nil
+	}
+
+	cursor := ""
+	for {
+		var page struct {
+			Channels []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"channels"`
+			Metadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		form := url.Values{
+			"types":            {"public_channel,private_channel"},
+			"exclude_archived": {"true"},
+			"limit":            {"1000"},
+			"cursor":           {cursor},
+		}
+		if err := slackCall(client, token, "conversations.list", form, &page); err != nil {
+			return "", err
+		}
+		for _, c := range page.Channels {
+			if c.Name == name {
+				return c.ID, nil
+			}
+		}
+		if cursor = page.Metadata.NextCursor; cursor == "" {
+			return "", fmt.Errorf("Slack channel %s not found, or the bot can't see it", channel)
+		}
+	}
+}
+
+// slackCall calls a Slack Web API method with form arguments and decodes the
+// response into result, if given. Slack reports failures as "ok": false
+// with an error code rather than through the HTTP status.
+func slackCall(client *http.Client, token, method string, form url.Values, result interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, slackAPI+method, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Slack %s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Slack %s: %v", method, err)
+	}
+
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("Slack %s: %s", method, resp.Status)
+	}
+	if !status.OK {
+		return fmt.Errorf("Slack %s: %s", method, status.Error)
+	}
+	if result != nil {
+		return json.Unmarshal(body, result)
+	}
+	return nil
+}
+
+// toDiscord posts the image as an attachment to a Discord channel, with the
+// message as its content. The bot needs the Send Messages and Attach Files
+// permissions in the channel.
+func (p publishedPost) toDiscord(client *http.Client, token, channelID string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"content":     p.message,
+		"attachments": []map[string]interface{}{{"id": 0, "filename": p.filename}},
+	})
+	if err := form.WriteField("payload_json", string(payload)); err != nil {
+		return err
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[0]"; filename=%q`, p.filename))
+	header.Set("Content-Type", "image/png")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(p.image); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discordAPI+"channels/"+url.PathEscape(channelID)+"/messages", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bot "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Discord: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(message, &failure) == nil && failure.Message != "" {
+			return fmt.Errorf("Discord: %s %s", resp.Status, failure.Message)
+		}
+		return fmt.Errorf("Discord: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+	"github.com/md/chart-markup-language/go-renderer/pkg/render"
+)
+
+// parseSize parses a WIDTHxHEIGHT size such as "320x180"
+func parseSize(size string) (int, int, error) {
+	parts := strings.Split(strings.ToLower(size), "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid size %q (want WIDTHxHEIGHT)", size)
+	}
+
+	width, err := strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid width in size %q", size)
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid height in size %q", size)
+	}
+	if width > cml.MaxCanvasSize || height > cml.MaxCanvasSize {
+		return 0, 0, fmt.Errorf("invalid size %q: width and height can be at most %d", size, cml.MaxCanvasSize)
+	}
+	return width, height, nil
+}
+
+// checkScaledSize reports an error when a canvas drawn at the given scale
+// would be wider or taller than cml.MaxCanvasSize image pixels
+func checkScaledSize(size render.Size, scale float64) error {
+	width, height := math.Round(float64(size.Width)*scale), math.Round(float64(size.Height)*scale)
+	if width > cml.MaxCanvasSize || height > cml.MaxCanvasSize {
+		return fmt.Errorf("%s at scale %g is %.0fx%.0f pixels; width and height can be at most %d", size, scale, width, height, cml.MaxCanvasSize)
+	}
+	return nil
+}
+
+// parseSizes parses a comma-separated list of sizes like "800x600,1600x1200"
+func parseSizes(sizes string) ([]render.Size, error) {
+	var parsed []render.Size
+	for _, size := range strings.Split(sizes, ",") {
+		width, height, err := parseSize(strings.TrimSpace(size))
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, render.Size{Width: width, Height: height})
+	}
+	return parsed, nil
+}
+
+// canvasSize returns the canvas size for a chart: the width and height given,
+// where not 0, then the chart's size setting, then 800x600
+func canvasSize(chart *cml.Chart, width, height int) render.Size {
+	size := render.Size{Width: 800, Height: 600}
+	if canvas, ok := chart.GetCanvasSize(); ok {
+		size = render.Size{Width: canvas.Width, Height: canvas.Height}
+	}
+	if width != 0 {
+		size.Width = width
+	}
+	if height != 0 {
+		size.Height = height
+	}
+	return size
+}
+
+// sizedPath returns the output file name for one of several sizes, e.g.
+// chart-1600x1200.png
+func sizedPath(outputFile string, size render.Size) string {
+	ext := filepath.Ext(outputFile)
+	return strings.TrimSuffix(outputFile, ext) + "-" + size.String() + ext
+}
+
+// chartName names a chart after its input file, e.g. chart for chart.cml or
+// charts/chart.cml.gz
+func chartName(inputFile string) string {
+	name := filepath.Base(inputFile)
+	for _, compressed := range []string{".gz", ".zst"} {
+		name = strings.TrimSuffix(name, compressed)
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// defaultOutputName names the output after the input, e.g. chart.png for
+// chart.cml or chart.cml.gz
+func defaultOutputName(inputFile string) string {
+	return chartName(inputFile) + ".png"
+}
+
+// thumbnailPath returns the thumbnail file name for an output file, e.g. chart.thumb.png
+func thumbnailPath(outputFile string) string {
+	if strings.HasSuffix(outputFile, ".png") {
+		return strings.TrimSuffix(outputFile, ".png") + ".thumb.png"
+	}
+	return outputFile + ".thumb.png"
+}
+
+// altTextPath returns the alt text file name for an output file, e.g. chart.txt
+func altTextPath(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + ".txt"
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/md/chart-markup-language/go-renderer/pkg/cml"
+)
+
+// Exit codes, so CI pipelines can tell failures apart
+const (
+	exitOK       = 0
+	exitUsage    = 1 // Bad flags or arguments
+	exitParse    = 2 // Unreadable or invalid input
+	exitWarnings = 3 // Rendered, but --strict and the parse produced warnings
+	exitRender   = 4 // Rendering or writing an output failed
+)
+
+// exitStatuses names each exit code in the run summary
+var exitStatuses = map[int]string{
+	exitOK:       "ok",
+	exitUsage:    "usage-error",
+	exitParse:    "parse-error",
+	exitWarnings: "warnings",
+	exitRender:   "render-error",
+}
+
+// RunSummary is the machine-readable result of a CLI run
+type RunSummary struct {
+	Status         string         `json:"status"`
+	ExitCode       int            `json:"exit_code"`
+	Input          string         `json:"input"`
+	Output         string         `json:"output,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Warnings       int            `json:"warnings"`
+	WarningsByType map[string]int `json:"warnings_by_type"`
+}
+
+// WarningCounts counts parse warnings by what was skipped ("bar", "drawing",
+// "tick"), "duplicate bars" for merged or dropped duplicates, or "other" for
+// warnings not in those forms
+func WarningCounts(warnings []string) map[string]int {
+	counts := map[string]int{}
+	for _, warning := range warnings {
+		kind := "other"
+		if _, rest, ok := strings.Cut(warning, ": skipped "); ok {
+			if k, _, ok := strings.Cut(rest, ":"); ok {
+				kind = k
+			}
+		} else if strings.HasPrefix(warning, "duplicate bars") {
+			kind = "duplicate bars"
+		}
+		counts[kind]++
+	}
+	return counts
+}
+
+// cliRun tracks a render run so every exit path can report its status
+type cliRun struct {
+	summaryFile string
+	summary     RunSummary
+
+	// Completion notification for --webhook
+	webhook   string
+	started   time.Time
+	published []string // Paths or URLs of the outputs, once written
+	warnings  []string
+}
+
+// setWarnings records the parse warnings of the run
+func (r *cliRun) setWarnings(warnings []string) {
+	r.warnings = warnings
+	r.summary.Warnings = len(warnings)
+	r.summary.WarningsByType = WarningCounts(warnings)
+}
+
+// printf prints a status message for people, on stderr when the summary is
+// written to stdout so that stdout stays valid JSON
+func (r *cliRun) printf(format string, args ...interface{}) {
+	out := os.Stdout
+	if r.summaryFile == "-" {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, format, args...)
+}
+
+// fail prints an error message and exits with the given code
+func (r *cliRun) fail(code int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	r.printf("%s\n", message)
+	r.summary.Error = message
+	r.exit(code)
+}
+
+// parseErrorMessage describes a parse failure, quoting the offending line
+// with a caret under the problem when the parser located it
+func parseErrorMessage(err error) string {
+	message := fmt.Sprintf("Error parsing CML: %v", err)
+	var parseErr *cml.ParseError
+	if errors.As(err, &parseErr) && parseErr.Snippet != "" {
+		message += "\n" + parseErr.Pointer()
+	}
+	return message
+}
+
+// exit notifies the webhook and writes the summary, when requested, and exits
+// with the given code
+func (r *cliRun) exit(code int) {
+	if r.webhook != "" {
+		err := notifyWebhook(r.webhook, WebhookPayload{
+			ID:         chartName(r.summary.Input),
+			Status:     exitStatuses[code],
+			ExitCode:   code,
+			Input:      r.summary.Input,
+			Outputs:    r.published,
+			DurationMS: float64(time.Since(r.started).Microseconds()) / 1000,
+			Warnings:   r.warnings,
+			Error:      r.summary.Error,
+		})
+		if err != nil {
+			message := fmt.Sprintf("Error notifying webhook: %v", err)
+			r.printf("%s\n", message)
+			if code == exitOK {
+				code = exitRender
+				r.summary.Error = message
+			}
+		}
+	}
+	r.summary.ExitCode = code
+	r.summary.Status = exitStatuses[code]
+	if r.summary.WarningsByType == nil {
+		r.summary.WarningsByType = map[string]int{}
+	}
+	if r.summaryFile != "" {
+		data, err := json.MarshalIndent(r.summary, "", "  ")
+		if err == nil {
+			if r.summaryFile == "-" {
+				_, err = os.Stdout.Write(append(data, '\n'))
+			} else {
+				err = os.WriteFile(r.summaryFile, append(data, '\n'), 0644)
+			}
+		}
+		if err != nil {
+			r.printf("Error writing summary: %v\n", err)
+			if code == exitOK {
+				code = exitRender
+			}
+		}
+	}
+	os.Exit(code)
+}
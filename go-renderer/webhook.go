@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// WebhookPayload is the completion notification POSTed to --webhook when a
+// run finishes, whether it succeeded or not
+type WebhookPayload struct {
+	ID         string   `json:"id"` // Chart name, from the input file name
+	Status     string   `json:"status"`
+	ExitCode   int      `json:"exit_code"`
+	Input      string   `json:"input"`
+	Outputs    []string `json:"outputs"` // Paths or URLs of the published outputs
+	DurationMS float64  `json:"duration_ms"`
+	Warnings   []string `json:"warnings"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Webhook delivery: attempts per notification, the wait before the first
+// retry (doubling after that), and the timeout of each attempt
+const (
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookSecretEnv names the environment variable holding the key webhook
+// payloads are signed with
+const webhookSecretEnv = "CML_WEBHOOK_SECRET"
+
+// notifyWebhook POSTs a payload to url as JSON, retrying network errors and
+// 5xx responses. When CML_WEBHOOK_SECRET is set, the X-CML-Signature header
+// carries "sha256=" and the hex HMAC-SHA256 of the body under it.
+func notifyWebhook(url string, payload WebhookPayload) error {
+	if payload.Outputs == nil {
+		payload.Outputs = []string{}
+	}
+	if payload.Warnings == nil {
+		payload.Warnings = []string{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	backoff := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(client, url, body)
+		if err == nil || attempt == webhookAttempts || !webhookRetryable(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// webhookError is a webhook response outside 2xx
+type webhookError struct {
+	status  int
+	message string
+}
+
+func (e *webhookError) Error() string {
+	return e.message
+}
+
+// webhookRetryable reports whether a failed delivery may succeed if tried
+// again: network errors and server errors, but not rejections like 400 or 404
+func webhookRetryable(err error) bool {
+	if failed, ok := err.(*webhookError); ok {
+		return failed.status >= 500
+	}
+	return true
+}
+
+// postWebhook makes one delivery attempt
+func postWebhook(client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := os.Getenv(webhookSecretEnv); secret != "" {
+		req.Header.Set("X-CML-Signature", "sha256="+hex.EncodeToString(hmacSHA256([]byte(secret), string(body))))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &webhookError{
+			status:  resp.StatusCode,
+			message: fmt.Sprintf("%s %s", resp.Status, strings.TrimSpace(string(message))),
+		}
+	}
+	return nil
+}